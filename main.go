@@ -2,14 +2,18 @@ package main
 
 import (
 	"flag"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/apiserver"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cost"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/costv2"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider/prometheusProvider"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/server"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/component-base/logs"
 	"k8s.io/klog/v2"
 	"log"
-	"net/http"
 	"os"
 )
 
@@ -24,8 +28,8 @@ func main() {
 		klog.Fatalf("unable to parse flags: %v", err)
 	}
 
-	stopCh := make(chan struct{})
-	defer close(stopCh)
+	// closed on SIGTERM or SIGINT, then both servers drain the in-flight requests
+	stopCh := genericapiserver.SetupSignalHandler()
 
 	providerManager, err := provider.NewProviderManager(opts, stopCh)
 	if err != nil {
@@ -37,19 +41,67 @@ func main() {
 	// register external metrics provider
 	opts.WithExternalMetrics(providerManager)
 
-	// export reload endpoint
-	http.HandleFunc("/reload", func(writer http.ResponseWriter, request *http.Request) {
-		os.Exit(0)
-	})
+	// register cost api to the metrics apiserver, it's served only if costv2 source is enabled
+	apiServer, err := opts.Server()
+	if err != nil {
+		klog.Fatalf("Failed to create alibaba-cloud-metrics-adapter server: %v", err)
+	}
+	costEnabled := func() bool {
+		return metrics.GetExternalMetricsManager().IsEnabled(metrics.SourceCostV2)
+	}
+	if err := apiserver.InstallCostAPI(apiServer.GenericAPIServer, costEnabled); err != nil {
+		klog.Fatalf("Failed to install cost api: %v", err)
+	}
+
+	sideServer, err := newSideServer(opts)
+	if err != nil {
+		klog.Fatalf("Failed to create side server,because of %v", err)
+	}
+	// export reload endpoint, the prometheus rules are reloaded in-process
+	if reloader, ok := providerManager.(provider.Reloader); ok {
+		sideServer.HandleFunc("/reload", provider.ReloadHandler(reloader))
+		if err := provider.WatchConfig(opts.AdapterConfigFile, reloader, stopCh); err != nil {
+			klog.Warningf("Failed to watch prometheus rules: %v", err)
+		}
+	}
 	// export cost metrics api
-	http.HandleFunc("/cost", cost.Handler)
-	http.HandleFunc("/v2/cost", costv2.ComputeEstimatedCostHandler)
-	http.HandleFunc("/v2/allocation", costv2.ComputeAllocationHandler)
+	sideServer.HandleFunc("/cost", cost.Handler)
+	sideServer.HandleFunc("/v2/cost", costv2.ComputeEstimatedCostHandler)
+	sideServer.HandleFunc("/v2/allocation", costv2.ComputeAllocationHandler)
+	// export the status of metric sources
+	sideServer.HandleFunc("/metric-sources", metrics.SourcesStatusHandler)
+	sideServerDone := make(chan struct{})
 	go func() {
-		http.ListenAndServe(":8080", nil)
+		defer close(sideServerDone)
+		if err := sideServer.RunUntil(stopCh); err != nil {
+			klog.Fatalf("Failed to run side server: %v", err)
+		}
 	}()
 
 	if err := opts.Run(stopCh); err != nil {
 		klog.Fatalf("Failed to run alibaba-cloud-metrics-adapter: %v", err)
 	}
+	// the metrics apiserver returns once stopped, wait for the side server to shutdown gracefully
+	<-sideServerDone
+}
+
+// the side server serves the apis beside the metrics apiserver
+func newSideServer(opts *prometheusProvider.AlibabaMetricsAdapterOptions) (*server.SideServer, error) {
+	var client kubernetes.Interface
+	if opts.SideServerAuthentication {
+		config, err := opts.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		if client, err = kubernetes.NewForConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	return server.NewSideServer(server.Options{
+		Address:        opts.SideServerAddress,
+		CertFile:       opts.SideServerCertFile,
+		KeyFile:        opts.SideServerKeyFile,
+		Authentication: opts.SideServerAuthentication,
+		RequestTimeout: opts.SideServerRequestTimeout,
+	}, client)
 }
@@ -0,0 +1,75 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GroupName = "cost.alibabacloud.com"
+	Version   = "v1alpha1"
+
+	ResourceAllocations    = "allocations"
+	ResourceEstimatedCosts = "estimatedcosts"
+)
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: Version}
+
+// AllocationList is the cost allocated to the aggregated objects, such as pods or namespaces, in the window.
+// It's listed with the params of window, aggregate, filter and so on.
+type AllocationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Allocation `json:"items"`
+}
+
+// Allocation is the cost of an aggregated object in a step of the window.
+type Allocation struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Properties *AllocationProperties `json:"properties,omitempty"`
+	CostStatus `json:",inline"`
+}
+
+// EstimatedCostList is the cost estimated by the usage of the aggregated objects in the window.
+type EstimatedCostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []EstimatedCost `json:"items"`
+}
+
+// EstimatedCost is the estimated cost of an aggregated object in a step of the window.
+type EstimatedCost struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Properties *AllocationProperties `json:"properties,omitempty"`
+	CostStatus `json:",inline"`
+}
+
+// AllocationProperties describes the pod which the cost is allocated to, only set if it's aggregated by pod.
+type AllocationProperties struct {
+	Cluster        string            `json:"cluster,omitempty"`
+	Node           string            `json:"node,omitempty"`
+	Controller     string            `json:"controller,omitempty"`
+	ControllerKind string            `json:"controllerKind,omitempty"`
+	Namespace      string            `json:"namespace,omitempty"`
+	Pod            string            `json:"pod,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	ProviderID     string            `json:"providerID,omitempty"`
+}
+
+// CostStatus is the cost and usage in a step of the window.
+type CostStatus struct {
+	Start metav1.Time `json:"start"`
+	End   metav1.Time `json:"end"`
+
+	CPUCoreRequestAverage  float64 `json:"cpuCoreRequestAverage"`
+	CPUCoreUsageAverage    float64 `json:"cpuCoreUsageAverage"`
+	RAMBytesRequestAverage float64 `json:"ramByteRequestAverage"`
+	RAMBytesUsageAverage   float64 `json:"ramByteUsageAverage"`
+	Cost                   float64 `json:"cost"`
+	CostRatio              float64 `json:"costRatio"`
+	CustomCost             float64 `json:"customCost"`
+}
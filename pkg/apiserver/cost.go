@@ -0,0 +1,252 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	costv1alpha1 "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/apis/cost/v1alpha1"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/costv2"
+	types "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/costv2/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apiserver/pkg/endpoints/discovery"
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	log "k8s.io/klog/v2"
+)
+
+// computes the allocations of the params, it's replaced in tests
+type computeFunc func(params costv2.AllocationParams) (*types.AllocationSetRange, error)
+
+func computeRangeAllocation(params costv2.AllocationParams) (*types.AllocationSetRange, error) {
+	return costv2.NewCostManager().GetRangeAllocation(params)
+}
+
+// InstallCostAPI registers the cost.alibabacloud.com group to the metrics apiserver, so the cost is
+// authenticated and authorized by kubernetes, such as
+//
+//	kubectl get --raw "/apis/cost.alibabacloud.com/v1alpha1/allocations?window=7d&aggregate=namespace"
+//
+// The group is always registered since the APIService is, otherwise the aggregated discovery fails.
+// The requests are rejected with 503 if enabled returns false, i.e. the costv2 source is disabled.
+func InstallCostAPI(server *genericapiserver.GenericAPIServer, enabled func() bool) error {
+	return installCostAPI(server, computeRangeAllocation, enabled)
+}
+
+func installCostAPI(server *genericapiserver.GenericAPIServer, compute computeFunc, enabled func() bool) error {
+	gv := costv1alpha1.SchemeGroupVersion
+	groupVersion := metav1.GroupVersionForDiscovery{
+		GroupVersion: gv.String(),
+		Version:      gv.Version,
+	}
+	apiGroup := metav1.APIGroup{
+		Name:             gv.Group,
+		Versions:         []metav1.GroupVersionForDiscovery{groupVersion},
+		PreferredVersion: groupVersion,
+	}
+	resources := []metav1.APIResource{
+		{Name: costv1alpha1.ResourceAllocations, Kind: "Allocation", Verbs: metav1.Verbs{"list"}},
+		{Name: costv1alpha1.ResourceEstimatedCosts, Kind: "EstimatedCost", Verbs: metav1.Verbs{"list"}},
+	}
+
+	mux := server.Handler.NonGoRestfulMux
+	prefix := genericapiserver.APIGroupPrefix + "/" + gv.Group
+	mux.Handle(prefix, discovery.NewAPIGroupHandler(server.Serializer, apiGroup))
+	mux.Handle(prefix+"/"+gv.Version, discovery.NewAPIVersionHandler(server.Serializer, gv, discovery.APIResourceListerFunc(func() []metav1.APIResource {
+		return resources
+	})))
+	mux.Handle(prefix+"/"+gv.Version+"/"+costv1alpha1.ResourceAllocations, &costHandler{
+		serializer: server.Serializer,
+		resource:   costv1alpha1.ResourceAllocations,
+		apiType:    costv2.TypeAllocation,
+		compute:    compute,
+		enabled:    enabled,
+	})
+	mux.Handle(prefix+"/"+gv.Version+"/"+costv1alpha1.ResourceEstimatedCosts, &costHandler{
+		serializer: server.Serializer,
+		resource:   costv1alpha1.ResourceEstimatedCosts,
+		apiType:    costv2.TypeCost,
+		compute:    compute,
+		enabled:    enabled,
+	})
+	server.DiscoveryGroupManager.AddGroup(apiGroup)
+	return nil
+}
+
+// costHandler lists the cost, the params of costv2 apis are accepted as query params or field selectors.
+type costHandler struct {
+	serializer runtime.NegotiatedSerializer
+	resource   string
+	apiType    costv2.APIType
+	compute    computeFunc
+	// whether the costv2 source is enabled
+	enabled func() bool
+}
+
+func (h *costHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gr := costv1alpha1.SchemeGroupVersion.WithResource(h.resource).GroupResource()
+	if r.Method != http.MethodGet {
+		h.writeError(w, r, apierrors.NewMethodNotSupported(gr, r.Method))
+		return
+	}
+	if !h.enabled() {
+		h.writeError(w, r, apierrors.NewServiceUnavailable(fmt.Sprintf("%s is not served since the costv2 metric source is disabled", gr.String())))
+		return
+	}
+
+	paramsMap, err := listParams(r)
+	if err != nil {
+		h.writeError(w, r, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	params, err := costv2.ParseAllocationParams(paramsMap, h.apiType)
+	if err != nil {
+		h.writeError(w, r, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+
+	asr, err := h.compute(params)
+	if err != nil {
+		if costv2.IsBadRequest(err) {
+			h.writeError(w, r, apierrors.NewBadRequest(err.Error()))
+		} else {
+			log.Errorf("Failed to compute %s,because of %v", h.resource, err)
+			h.writeError(w, r, apierrors.NewInternalError(err))
+		}
+		return
+	}
+
+	var list interface{}
+	switch h.apiType {
+	case costv2.TypeAllocation:
+		list = toAllocationList(asr)
+	default:
+		list = toEstimatedCostList(asr)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		log.Errorf("Failed to write %s,because of %v", h.resource, err)
+	}
+}
+
+func (h *costHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	responsewriters.ErrorNegotiated(err, h.serializer, costv1alpha1.SchemeGroupVersion, w, r)
+}
+
+// the first value of query params, and the field selector such as window=7d,aggregate=namespace
+func listParams(r *http.Request) (map[string]string, error) {
+	paramsMap := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		paramsMap[k] = v[0]
+	}
+
+	selector, err := fields.ParseSelector(paramsMap["fieldSelector"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid field selector: %v", err)
+	}
+	for _, requirement := range selector.Requirements() {
+		if requirement.Operator != selection.Equals && requirement.Operator != selection.DoubleEquals {
+			return nil, fmt.Errorf("field selector %s only supports equality", requirement.Field)
+		}
+		paramsMap[requirement.Field] = requirement.Value
+	}
+	return paramsMap, nil
+}
+
+func toAllocationList(asr *types.AllocationSetRange) *costv1alpha1.AllocationList {
+	list := &costv1alpha1.AllocationList{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: costv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "AllocationList",
+		},
+		Items: make([]costv1alpha1.Allocation, 0),
+	}
+	eachAllocation(asr, func(a *types.Allocation) {
+		list.Items = append(list.Items, costv1alpha1.Allocation{
+			ObjectMeta: metav1.ObjectMeta{Name: itemName(a)},
+			Properties: toProperties(a.Properties),
+			CostStatus: toCostStatus(a),
+		})
+	})
+	return list
+}
+
+func toEstimatedCostList(asr *types.AllocationSetRange) *costv1alpha1.EstimatedCostList {
+	list := &costv1alpha1.EstimatedCostList{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: costv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "EstimatedCostList",
+		},
+		Items: make([]costv1alpha1.EstimatedCost, 0),
+	}
+	eachAllocation(asr, func(a *types.Allocation) {
+		list.Items = append(list.Items, costv1alpha1.EstimatedCost{
+			ObjectMeta: metav1.ObjectMeta{Name: itemName(a)},
+			Properties: toProperties(a.Properties),
+			CostStatus: toCostStatus(a),
+		})
+	})
+	return list
+}
+
+// the allocations of different steps share the aggregated name, so the start of step is appended to it
+func itemName(a *types.Allocation) string {
+	return fmt.Sprintf("%s-%d", a.Name, a.Start.Unix())
+}
+
+// iterate the allocations ordered by step and name
+func eachAllocation(asr *types.AllocationSetRange, f func(a *types.Allocation)) {
+	if asr == nil {
+		return
+	}
+	for _, as := range asr.Allocations {
+		if as == nil {
+			continue
+		}
+		names := make([]string, 0, len(*as))
+		for name := range *as {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if a := (*as)[name]; a != nil {
+				f(a)
+			}
+		}
+	}
+}
+
+func toProperties(p *types.AllocationProperties) *costv1alpha1.AllocationProperties {
+	if p == nil {
+		return nil
+	}
+	return &costv1alpha1.AllocationProperties{
+		Cluster:        p.Cluster,
+		Node:           p.Node,
+		Controller:     p.Controller,
+		ControllerKind: p.ControllerKind,
+		Namespace:      p.Namespace,
+		Pod:            p.Pod,
+		Labels:         p.Labels,
+		ProviderID:     p.ProviderID,
+	}
+}
+
+func toCostStatus(a *types.Allocation) costv1alpha1.CostStatus {
+	return costv1alpha1.CostStatus{
+		Start:                  metav1.NewTime(a.Start),
+		End:                    metav1.NewTime(a.End),
+		CPUCoreRequestAverage:  a.CPUCoreRequestAverage,
+		CPUCoreUsageAverage:    a.CPUCoreUsageAverage,
+		RAMBytesRequestAverage: a.RAMBytesRequestAverage,
+		RAMBytesUsageAverage:   a.RAMBytesUsageAverage,
+		Cost:                   a.Cost,
+		CostRatio:              a.CostRatio,
+		CustomCost:             a.CustomCost,
+	}
+}
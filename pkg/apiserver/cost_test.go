@@ -0,0 +1,109 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	costv1alpha1 "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/apis/cost/v1alpha1"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/costv2"
+	types "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/costv2/types"
+	cmapiserver "sigs.k8s.io/custom-metrics-apiserver/pkg/apiserver"
+)
+
+func fakeHandler(apiType costv2.APIType) *costHandler {
+	return &costHandler{
+		serializer: cmapiserver.Codecs,
+		resource:   costv1alpha1.ResourceAllocations,
+		apiType:    apiType,
+		enabled:    func() bool { return true },
+		compute: func(params costv2.AllocationParams) (*types.AllocationSetRange, error) {
+			now := time.Now()
+			asr := types.NewAllocationSetRange()
+			asr.Append(&types.AllocationSet{
+				"kube-system": {Name: "kube-system", Start: now, End: now, Cost: 2},
+				"default":     {Name: "default", Start: now, End: now, Cost: 1},
+			})
+			return asr, nil
+		},
+	}
+}
+
+func TestListAllocations(t *testing.T) {
+	h := fakeHandler(costv2.TypeAllocation)
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/apis/cost.alibabacloud.com/v1alpha1/allocations?window=7d&fieldSelector=aggregate%3Dnamespace", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	list := &costv1alpha1.AllocationList{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), list); err != nil {
+		t.Fatalf("failed to unmarshal allocations: %v", err)
+	}
+	if list.Kind != "AllocationList" || len(list.Items) != 2 || !strings.HasPrefix(list.Items[0].Name, "default-") || list.Items[1].Cost != 2 {
+		t.Fatalf("unexpected allocations: %+v", list)
+	}
+}
+
+func TestAllocationNamesOfSteps(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	asr := types.NewAllocationSetRange()
+	for i := 0; i < 2; i++ {
+		s := start.Add(time.Duration(i) * 24 * time.Hour)
+		asr.Append(&types.AllocationSet{
+			"default": {Name: "default", Start: s, End: s.Add(24 * time.Hour)},
+		})
+	}
+	list := toEstimatedCostList(asr)
+	if len(list.Items) != 2 || list.Items[0].Name != "default-1704067200" || list.Items[1].Name != "default-1704153600" {
+		t.Fatalf("unexpected names of steps: %+v", list.Items)
+	}
+}
+
+func TestListAllocationsWithInvalidParams(t *testing.T) {
+	h := fakeHandler(costv2.TypeAllocation)
+
+	cases := []struct {
+		method string
+		url    string
+		code   int
+	}{
+		// the window of allocation must be at least 1 day
+		{http.MethodGet, "/apis/cost.alibabacloud.com/v1alpha1/allocations?window=1h", http.StatusBadRequest},
+		{http.MethodGet, "/apis/cost.alibabacloud.com/v1alpha1/allocations?window=7d&fieldSelector=aggregate!%3Dpod", http.StatusBadRequest},
+		{http.MethodPost, "/apis/cost.alibabacloud.com/v1alpha1/allocations?window=7d", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(c.method, c.url, nil))
+		if recorder.Code != c.code {
+			t.Fatalf("unexpected status of %s %s: %d", c.method, c.url, recorder.Code)
+		}
+	}
+}
+
+func TestListParams(t *testing.T) {
+	paramsMap, err := listParams(httptest.NewRequest(http.MethodGet, "/apis/cost.alibabacloud.com/v1alpha1/allocations?window=7d&fieldSelector=aggregate%3Dnamespace", nil))
+	if err != nil {
+		t.Fatalf("failed to get params: %v", err)
+	}
+	if paramsMap["window"] != "7d" || paramsMap["aggregate"] != "namespace" {
+		t.Fatalf("field selector is not used as params: %v", paramsMap)
+	}
+}
+
+func TestListAllocationsDisabled(t *testing.T) {
+	h := fakeHandler(costv2.TypeAllocation)
+	h.enabled = func() bool { return false }
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/apis/cost.alibabacloud.com/v1alpha1/allocations?window=7d", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status %d of disabled costv2: %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
 	ahas "github.com/aliyun/alibaba-cloud-sdk-go/services/ahas_openapi"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	log "k8s.io/klog/v2"
 	"k8s.io/metrics/pkg/apis/external_metrics"
 	"sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
@@ -23,17 +24,51 @@ const (
 	AHAS_SENTINEL_PASS_QPS  = "ahas_sentinel_pass_qps"
 	AHAS_SENTINEL_BLOCK_QPS = "ahas_sentinel_block_qps"
 	AHAS_SENTINEL_AVG_RT    = "ahas_sentinel_avg_rt"
+	// only available for the metrics of resources
+	AHAS_SENTINEL_EXCEPTION_QPS = "ahas_sentinel_exception_qps"
 
 	NamespaceKey             = "ahas.sentinel.namespace"
 	SentinelAppNameKey       = "ahas.sentinel.app"
 	SentinelQueryIntervalKey = "ahas.sentinel.interval"
 	SentinelQueryOffsetKey   = "ahas.sentinel.queryOffset"
+	SentinelResourceKey      = "ahas.sentinel.resource"
+	SentinelAggregationKey   = "ahas.sentinel.aggregation"
+	SentinelPortKey          = "ahas.sentinel.port"
+	SentinelWorkloadKey      = "ahas.sentinel.workload"
+	SentinelSourceKey        = "ahas.sentinel.source"
+
+	// the sources of metrics, AHAS OpenAPI by default. The transport of sentinel on pods is only
+	// scraped if it's selected explicitly since the adapter must be able to reach the pods.
+	SOURCE_AHAS      = "ahas"
+	SOURCE_TRANSPORT = "transport"
 
 	DefaultQueryInterval = 10
 	DefaultQueryOffset   = 10
+
+	// the default statistic interval of sentinel
+	DefaultCacheTTL = 10 * time.Second
 )
 
-type AHASSentinelMetricSource struct{}
+// units of the raw values of sentinel metrics
+var sentinelUnits = map[string]utils.Unit{
+	AHAS_SENTINEL_TOTAL_QPS:     {Name: utils.UNIT_COUNT_PER_SECOND},
+	AHAS_SENTINEL_PASS_QPS:      {Name: utils.UNIT_COUNT_PER_SECOND},
+	AHAS_SENTINEL_BLOCK_QPS:     {Name: utils.UNIT_COUNT_PER_SECOND},
+	AHAS_SENTINEL_AVG_RT:        {Name: utils.UNIT_MILLISECONDS},
+	AHAS_SENTINEL_EXCEPTION_QPS: {Name: utils.UNIT_COUNT_PER_SECOND},
+}
+
+type AHASSentinelMetricSource struct {
+	clients utils.ClientCache
+
+	lock sync.RWMutex
+	// finds the sentinel apps of workloads, started by RunUntil
+	discovery *workloadDiscovery
+	// list the transport addresses of app instances and fetch the metrics of resources from them,
+	// the discovery and fetchMetricNodes are used if nil.
+	instances func(namespace string, params *AHASSentinelParams) ([]string, error)
+	fetch     func(addr, resource string, start, end time.Time) ([]*metricNode, error)
+}
 
 func (s *AHASSentinelMetricSource) GetExternalMetricInfoList() []provider.ExternalMetricInfo {
 	metricInfoList := make([]p.ExternalMetricInfo, 0)
@@ -42,6 +77,7 @@ func (s *AHASSentinelMetricSource) GetExternalMetricInfoList() []provider.Extern
 		AHAS_SENTINEL_BLOCK_QPS,
 		AHAS_SENTINEL_TOTAL_QPS,
 		AHAS_SENTINEL_AVG_RT,
+		AHAS_SENTINEL_EXCEPTION_QPS,
 	}
 	for _, metric := range MetricArray {
 		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
@@ -52,12 +88,25 @@ func (s *AHASSentinelMetricSource) GetExternalMetricInfoList() []provider.Extern
 }
 
 func (s *AHASSentinelMetricSource) GetExternalMetric(info provider.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
-	params, err := getAhasSentinelParams(requirements, namespace)
+	params, err := getAhasSentinelParams(requirements, namespace, s.getDiscovery())
 	if err != nil {
 		return values, fmt.Errorf("failed to get AHAS Sentinel params, cause: %v", err)
 	}
 
-	client, err := s.createClient()
+	if params.Source == SOURCE_TRANSPORT {
+		v, err := s.getResourceMetric(info, namespace, params)
+		if err != nil {
+			log.Errorf("Failed to get AHAS Sentinel metrics of resource %s, err: %v", params.Resource, err)
+			return values, err
+		}
+		return s.toExternalMetricValues(info, v)
+	}
+	// the app sum metrics have neither resources nor exceptions
+	if len(params.Resource) > 0 || info.Metric == AHAS_SENTINEL_EXCEPTION_QPS {
+		return values, fmt.Errorf("%s and %s are only supported by source %s", SentinelResourceKey, AHAS_SENTINEL_EXCEPTION_QPS, SOURCE_TRANSPORT)
+	}
+
+	client, err := s.createClient(utils.GetAccessOptions(namespace, requirements))
 	if err != nil {
 		log.Errorf("Failed to create AHAS Sentinel client, because of %v", err)
 		return values, err
@@ -77,15 +126,46 @@ func (s *AHASSentinelMetricSource) GetExternalMetric(info provider.ExternalMetri
 		log.Errorf("Failed to get AHAS Sentinel response, err: %v", err)
 		return values, err
 	}
-	count := resolveMetric(info, metrics)
+	return s.toExternalMetricValues(info, resolveMetric(info, metrics))
+}
+
+func (s *AHASSentinelMetricSource) toExternalMetricValues(info provider.ExternalMetricInfo, v float64) (values []external_metrics.ExternalMetricValue, err error) {
+	value, err := utils.ConvertValue(v, sentinelUnits[info.Metric])
+	if err != nil {
+		return values, err
+	}
 	values = append(values, external_metrics.ExternalMetricValue{
 		MetricName: info.Metric,
-		Value:      *resource.NewQuantity(int64(count), resource.DecimalSI),
+		Value:      *value,
 		Timestamp:  metav1.Now(),
 	})
 	return values, nil
 }
 
+// RunUntil discovers the sentinel apps of workloads from the informer cache.
+func (s *AHASSentinelMetricSource) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	discovery := newWorkloadDiscovery(kubeClient, stopCh)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.discovery = discovery
+}
+
+func (s *AHASSentinelMetricSource) getDiscovery() *workloadDiscovery {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.discovery
+}
+
+// the sentinel QPS is counted by the statistic interval, so a request is not queried again in DefaultCacheTTL
+func (s *AHASSentinelMetricSource) CacheTTL() time.Duration {
+	return DefaultCacheTTL
+}
+
+// MetricUnits describes the units of metric values
+func (s *AHASSentinelMetricSource) MetricUnits() map[string]utils.Unit {
+	return sentinelUnits
+}
+
 func resolveMetric(info provider.ExternalMetricInfo, response *ahas.GetSentinelAppSumMetricResponse) float64 {
 	switch info.Metric {
 	case AHAS_SENTINEL_TOTAL_QPS:
@@ -110,27 +190,40 @@ func handleMetricAvg(count float64, response *ahas.GetSentinelAppSumMetricRespon
 	}
 }
 
-func (s *AHASSentinelMetricSource) createClient() (client *ahas.Client, err error) {
-	accessUserInfo, err := utils.GetAccessUserInfo()
+func (s *AHASSentinelMetricSource) createClient(options utils.AccessOptions) (client *ahas.Client, err error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(options)
 	if err != nil {
 		log.Errorf("Failed to get accessUserInfo, because of %v.", err)
 		return nil, err
 	}
 
-	if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
-		client, err = ahas.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
-	} else {
-		client, err = ahas.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
-
+	c, err := s.clients.Get(accessUserInfo.Region, accessUserInfo, func() (interface{}, error) {
+		if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
+			return ahas.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
+		}
+		return ahas.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return client, err
+	return c.(*ahas.Client), nil
 }
 
 type AHASSentinelParams struct {
 	SentinelGlobalParams
+	// the resource of sentinel, e.g. an interface or URL
+	Resource string
+	// avg or max of the values of machines
+	Aggregation string
+	// the port of sentinel transport
+	Port int
+	// the workload of the app, e.g. deployment.foo
+	Workload string
+	// ahas or transport
+	Source string
 }
 
-func getAhasSentinelParams(requirements labels.Requirements, k8sNamespace string) (params *AHASSentinelParams, err error) {
+func getAhasSentinelParams(requirements labels.Requirements, k8sNamespace string, discovery *workloadDiscovery) (params *AHASSentinelParams, err error) {
 	params = &AHASSentinelParams{}
 	for _, r := range requirements {
 
@@ -155,23 +248,58 @@ func getAhasSentinelParams(requirements labels.Requirements, k8sNamespace string
 				log.Errorf("Failed to parse AHAS Sentinel query start offset, cause: %v", err)
 				continue
 			}
+		case SentinelWorkloadKey:
+			params.Workload = value
+		case SentinelResourceKey:
+			params.Resource = value
+		case SentinelAggregationKey:
+			if value != AGGREGATION_AVG && value != AGGREGATION_MAX {
+				return params, fmt.Errorf("aggregation %s is not supported", value)
+			}
+			params.Aggregation = value
+		case SentinelSourceKey:
+			if value != SOURCE_AHAS && value != SOURCE_TRANSPORT {
+				return params, fmt.Errorf("source %s is not supported", value)
+			}
+			params.Source = value
+		case SentinelPortKey:
+			if params.Port, err = strconv.Atoi(value); err != nil {
+				log.Errorf("Failed to parse AHAS Sentinel transport port, cause: %v", err)
+				continue
+			}
 		}
 	}
 	if len(params.AppName) <= 0 {
-		// try to get Sentinel appName from pilot annotation.
-		pilotMetadata, err := getPilotAnnotationMetadata(k8sNamespace)
+		// try to get Sentinel appName from the pilot annotation of workloads.
+		appName, ahasNamespace, err := discovery.app(k8sNamespace, params.Workload)
 		if err != nil {
 			return params, err
 		}
-		if len(pilotMetadata.appName) <= 0 {
+		if len(appName) <= 0 {
 			return params, errors.New("appName in AHAS Sentinel is required")
 		}
 		// apply the configuration from pilot annotation.
-		params.AppName = pilotMetadata.appName
-		if len(params.AhasNamespace) <= 0 && len(pilotMetadata.namespace) > 0 {
-			params.AhasNamespace = pilotMetadata.namespace
+		params.AppName = appName
+		if len(params.AhasNamespace) <= 0 && len(ahasNamespace) > 0 {
+			params.AhasNamespace = ahasNamespace
 		}
 	}
+	if params.Source == "" {
+		params.Source = SOURCE_AHAS
+	}
+	if params.Aggregation == "" {
+		params.Aggregation = AGGREGATION_AVG
+	}
+	// only the transports return the values of each machine
+	if params.Aggregation == AGGREGATION_MAX && params.Source != SOURCE_TRANSPORT {
+		return params, fmt.Errorf("aggregation %s is only supported by source %s", AGGREGATION_MAX, SOURCE_TRANSPORT)
+	}
+	if params.Source == SOURCE_TRANSPORT && params.Resource == "" {
+		return params, fmt.Errorf("%s is required by source %s", SentinelResourceKey, SOURCE_TRANSPORT)
+	}
+	if params.Port <= 0 {
+		params.Port = DefaultTransportPort
+	}
 	if params.AhasNamespace == "" {
 		params.AhasNamespace = "default"
 	}
@@ -0,0 +1,285 @@
+package ahas
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	log "k8s.io/klog/v2"
+)
+
+const AHAS_APP_NAME_ANNOTATION_KEY = "ahasAppName"
+const AHAS_NAMESPACE_ANNOTATION_KEY = "ahasNamespace"
+
+const (
+	// the kinds of workloads in ahas.sentinel.workload, e.g. deployment.foo
+	WORKLOAD_DEPLOYMENT  = "deployment"
+	WORKLOAD_STATEFULSET = "statefulset"
+	WORKLOAD_CLONESET    = "cloneset"
+
+	resyncPeriod = 10 * time.Minute
+	// the first request of transports waits for the cache of pods at most
+	podsSyncTimeout = 10 * time.Second
+)
+
+// GVRs of the workloads which run sentinel apps
+var workloadResources = map[string]schema.GroupVersionResource{
+	WORKLOAD_DEPLOYMENT:  {Group: "apps", Version: "v1", Resource: "deployments"},
+	WORKLOAD_STATEFULSET: {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	WORKLOAD_CLONESET:    {Group: "apps.kruise.io", Version: "v1alpha1", Resource: "clonesets"},
+}
+
+// the workloads whose CRDs may be installed after the adapter
+var crdWorkloads = map[string]bool{
+	WORKLOAD_CLONESET: true,
+}
+
+var podResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// workload is a workload annotated with the sentinel app
+type workload struct {
+	Kind      string
+	Name      string
+	AppName   string
+	Namespace string
+	// nil if the workload has no selector
+	Selector labels.Selector
+}
+
+func (w *workload) String() string {
+	return fmt.Sprintf("%s.%s", w.Kind, w.Name)
+}
+
+// workloadDiscovery finds the sentinel apps of workloads and their pods from the informer cache
+type workloadDiscovery struct {
+	kubeClient dynamic.Interface
+	stopCh     <-chan struct{}
+
+	// the pods are only watched once the transports are scraped
+	podsOnce sync.Once
+	pods     cache.SharedIndexInformer
+
+	// the informers of CRD workloads are added once the CRDs are installed
+	lock      sync.RWMutex
+	informers map[string]cache.SharedIndexInformer
+}
+
+// newWorkloadDiscovery starts the informers of workloads, the informers of CRD workloads
+// are started once the CRDs are installed, e.g. OpenKruise is installed after the adapter.
+func newWorkloadDiscovery(kubeClient dynamic.Interface, stopCh <-chan struct{}) *workloadDiscovery {
+	d := &workloadDiscovery{
+		kubeClient: kubeClient,
+		stopCh:     stopCh,
+		informers:  make(map[string]cache.SharedIndexInformer),
+	}
+	for kind, gvr := range workloadResources {
+		kind, gvr := kind, gvr
+		if crdWorkloads[kind] {
+			utils.RunWhenResourceAvailable(kubeClient, gvr, stopCh, func() {
+				d.watch(kind, gvr)
+			})
+			continue
+		}
+		d.watch(kind, gvr)
+	}
+	return d
+}
+
+func (d *workloadDiscovery) watch(kind string, gvr schema.GroupVersionResource) {
+	informer := newStrippedInformer(d.kubeClient, gvr, stripWorkload)
+	d.lock.Lock()
+	d.informers[kind] = informer
+	d.lock.Unlock()
+	go informer.Run(d.stopCh)
+	log.Infof("Start discovering AHAS Sentinel apps of %s", gvr.Resource)
+}
+
+// podInformer starts watching pods on the first call, and waits for the cache in podsSyncTimeout
+func (d *workloadDiscovery) podInformer() (cache.SharedIndexInformer, error) {
+	d.podsOnce.Do(func() {
+		d.pods = newStrippedInformer(d.kubeClient, podResource, stripPod)
+		go d.pods.Run(d.stopCh)
+		log.Infof("Start watching pods of AHAS Sentinel apps")
+	})
+	if !d.pods.HasSynced() {
+		timeout := make(chan struct{})
+		timer := time.AfterFunc(podsSyncTimeout, func() { close(timeout) })
+		defer timer.Stop()
+		if !cache.WaitForCacheSync(timeout, d.pods.HasSynced) {
+			return nil, fmt.Errorf("%s are not synced yet", podResource.Resource)
+		}
+	}
+	return d.pods, nil
+}
+
+// workloads returns the workloads annotated with sentinel apps in namespace,
+// the workload is selected by ahas.sentinel.workload if it's not empty.
+func (d *workloadDiscovery) workloads(namespace, selected string) ([]*workload, error) {
+	if d == nil {
+		return nil, fmt.Errorf("discovery of AHAS Sentinel apps is not started")
+	}
+	kind, name := parseWorkload(selected)
+	if kind != "" && crdWorkloads[kind] && !d.watching(kind) {
+		return nil, fmt.Errorf("%s are not installed yet", workloadResources[kind].Resource)
+	}
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	workloads := make([]*workload, 0)
+	for k, informer := range d.informers {
+		if kind != "" && k != kind {
+			continue
+		}
+		if !informer.HasSynced() {
+			return nil, fmt.Errorf("%s are not synced yet", workloadResources[k].Resource)
+		}
+		objs, err := informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok || (name != "" && u.GetName() != name) {
+				continue
+			}
+			w, err := toWorkload(k, u)
+			if err != nil {
+				log.Warningf("Skip %s %s/%s,because of %v", k, namespace, u.GetName(), err)
+				continue
+			}
+			if w.AppName != "" {
+				workloads = append(workloads, w)
+			}
+		}
+	}
+	// the order of informers is random
+	sort.Slice(workloads, func(i, j int) bool {
+		return workloads[i].String() < workloads[j].String()
+	})
+	if selected != "" && len(workloads) == 0 {
+		return nil, fmt.Errorf("workload %s annotated with %s is not found in namespace %s", selected, AHAS_APP_NAME_ANNOTATION_KEY, namespace)
+	}
+	return workloads, nil
+}
+
+func (d *workloadDiscovery) watching(kind string) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	_, ok := d.informers[kind]
+	return ok
+}
+
+// app returns the sentinel app and its namespace of the workloads in namespace,
+// the app must be unique if the workload is not selected.
+func (d *workloadDiscovery) app(namespace, selected string) (appName, ahasNamespace string, err error) {
+	workloads, err := d.workloads(namespace, selected)
+	if err != nil {
+		return "", "", err
+	}
+	for _, w := range workloads {
+		if appName != "" && w.AppName != appName {
+			return "", "", fmt.Errorf("workloads in namespace %s run several AHAS Sentinel apps, use %s to select one", namespace, SentinelWorkloadKey)
+		}
+		appName = w.AppName
+		if ahasNamespace == "" {
+			ahasNamespace = w.Namespace
+		}
+	}
+	return appName, ahasNamespace, nil
+}
+
+// instances returns the transport addresses of the running pods of the sentinel app
+func (d *workloadDiscovery) instances(namespace string, params *AHASSentinelParams) ([]string, error) {
+	workloads, err := d.workloads(namespace, params.Workload)
+	if err != nil {
+		return nil, err
+	}
+	podInformer, err := d.podInformer()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := podInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, w := range workloads {
+		// never select all pods of the namespace
+		if w.AppName != params.AppName || w.Selector == nil || w.Selector.Empty() {
+			continue
+		}
+		for _, obj := range pods {
+			pod, ok := obj.(*unstructured.Unstructured)
+			if !ok || !w.Selector.Matches(labels.Set(pod.GetLabels())) {
+				continue
+			}
+			phase, _, _ := unstructured.NestedString(pod.Object, "status", "phase")
+			podIP, _, _ := unstructured.NestedString(pod.Object, "status", "podIP")
+			// the pods may be selected by several workloads
+			if phase != "Running" || podIP == "" || seen[podIP] {
+				continue
+			}
+			seen[podIP] = true
+			addrs = append(addrs, net.JoinHostPort(podIP, strconv.Itoa(params.Port)))
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no running instance of sentinel app %s is found in namespace %s", params.AppName, namespace)
+	}
+	return addrs, nil
+}
+
+// parseWorkload parses ahas.sentinel.workload, the kind is optional and the name is matched with all kinds without it.
+func parseWorkload(value string) (kind, name string) {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) == 2 {
+		switch parts[0] {
+		case WORKLOAD_DEPLOYMENT, WORKLOAD_STATEFULSET, WORKLOAD_CLONESET:
+			return parts[0], parts[1]
+		}
+	}
+	return "", value
+}
+
+// toWorkload reads the sentinel app from the annotations of pod template, the ones of workload are used
+// if the pod template isn't annotated.
+func toWorkload(kind string, u *unstructured.Unstructured) (*workload, error) {
+	annotations, _, err := unstructured.NestedStringMap(u.Object, "spec", "template", "metadata", "annotations")
+	if err != nil {
+		return nil, err
+	}
+	if annotations[AHAS_APP_NAME_ANNOTATION_KEY] == "" {
+		annotations = u.GetAnnotations()
+	}
+	w := &workload{
+		Kind:      kind,
+		Name:      u.GetName(),
+		AppName:   annotations[AHAS_APP_NAME_ANNOTATION_KEY],
+		Namespace: annotations[AHAS_NAMESPACE_ANNOTATION_KEY],
+	}
+	selector, found, err := unstructured.NestedMap(u.Object, "spec", "selector")
+	if err != nil || !found {
+		return w, err
+	}
+	labelSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, labelSelector); err != nil {
+		return nil, err
+	}
+	if w.Selector, err = metav1.LabelSelectorAsSelector(labelSelector); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -0,0 +1,188 @@
+package ahas
+
+import (
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedyn "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newWorkload(apiVersion, kind, name string, annotations, templateAnnotations map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   "default",
+			"annotations": annotations,
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": name},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"annotations": templateAnnotations},
+			},
+		},
+	}}
+	return u
+}
+
+func newPod(name, app, phase, podIP string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": app},
+		},
+		"status": map[string]interface{}{"phase": phase, "podIP": podIP},
+	}}
+}
+
+func TestWorkloadDiscovery(t *testing.T) {
+	kubeClient := fakedyn.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		workloadResources[WORKLOAD_DEPLOYMENT]:  "DeploymentList",
+		workloadResources[WORKLOAD_STATEFULSET]: "StatefulSetList",
+		workloadResources[WORKLOAD_CLONESET]:    "CloneSetList",
+		podResource:                             "PodList",
+	},
+		newWorkload("apps/v1", "Deployment", "orders", nil, map[string]interface{}{AHAS_APP_NAME_ANNOTATION_KEY: "orders-app", AHAS_NAMESPACE_ANNOTATION_KEY: "prod"}),
+		newWorkload("apps/v1", "StatefulSet", "users", map[string]interface{}{AHAS_APP_NAME_ANNOTATION_KEY: "users-app"}, nil),
+		newWorkload("apps.kruise.io/v1alpha1", "CloneSet", "users", nil, map[string]interface{}{AHAS_APP_NAME_ANNOTATION_KEY: "users-app"}),
+		newWorkload("apps/v1", "Deployment", "web", nil, nil),
+		newPod("orders-0", "orders", "Running", "10.0.0.1"),
+		newPod("orders-1", "orders", "Pending", ""),
+		newPod("users-0", "users", "Running", "10.0.0.2"),
+	)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	d := newWorkloadDiscovery(kubeClient, stopCh)
+	// the informer of clonesets is added once the CRD is probed
+	synced := func() bool {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		if len(d.informers) != len(workloadResources) {
+			return false
+		}
+		for _, informer := range d.informers {
+			if !informer.HasSynced() {
+				return false
+			}
+		}
+		return true
+	}
+	if !cache.WaitForCacheSync(stopCh, synced) {
+		t.Fatalf("failed to sync informers")
+	}
+
+	// the namespace hosts several apps
+	if _, _, err := d.app("default", ""); err == nil {
+		t.Fatalf("expected error for several apps")
+	}
+	appName, ahasNamespace, err := d.app("default", "deployment.orders")
+	if err != nil || appName != "orders-app" || ahasNamespace != "prod" {
+		t.Fatalf("unexpected app %s/%s of deployment.orders: %v", ahasNamespace, appName, err)
+	}
+	// the statefulset and cloneset named users run the same app
+	if appName, _, err := d.app("default", "users"); err != nil || appName != "users-app" {
+		t.Fatalf("unexpected app %s of users: %v", appName, err)
+	}
+	if appName, _, err := d.app("default", "cloneset.users"); err != nil || appName != "users-app" {
+		t.Fatalf("unexpected app %s of cloneset.users: %v", appName, err)
+	}
+	for _, selected := range []string{"deployment.web", "statefulset.orders", "unknown"} {
+		if _, _, err := d.app("default", selected); err == nil {
+			t.Fatalf("expected error for workload %s", selected)
+		}
+	}
+	if appName, _, err := d.app("kube-system", ""); err != nil || appName != "" {
+		t.Fatalf("unexpected app %s of kube-system: %v", appName, err)
+	}
+
+	// the pods are watched by the first request of transports
+	if d.pods != nil {
+		t.Fatalf("pods should not be watched before transports are scraped")
+	}
+	addrs, err := d.instances("default", &AHASSentinelParams{SentinelGlobalParams: SentinelGlobalParams{AppName: "orders-app"}, Port: DefaultTransportPort})
+	if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1:8719" {
+		t.Fatalf("unexpected instances %v: %v", addrs, err)
+	}
+	addrs, err = d.instances("default", &AHASSentinelParams{SentinelGlobalParams: SentinelGlobalParams{AppName: "users-app"}, Port: 8720})
+	if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.2:8720" {
+		t.Fatalf("unexpected instances %v: %v", addrs, err)
+	}
+
+	var nilDiscovery *workloadDiscovery
+	if _, _, err := nilDiscovery.app("default", ""); err == nil {
+		t.Fatalf("expected error for discovery not started")
+	}
+}
+
+func TestWorkloadDiscoveryWithoutCRD(t *testing.T) {
+	kubeClient := fakedyn.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		workloadResources[WORKLOAD_DEPLOYMENT]:  "DeploymentList",
+		workloadResources[WORKLOAD_STATEFULSET]: "StatefulSetList",
+		workloadResources[WORKLOAD_CLONESET]:    "CloneSetList",
+		podResource:                             "PodList",
+	},
+		newWorkload("apps/v1", "Deployment", "orders", nil, map[string]interface{}{AHAS_APP_NAME_ANNOTATION_KEY: "orders-app"}),
+	)
+	// OpenKruise is not installed
+	kubeClient.PrependReactor("list", "clonesets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(workloadResources[WORKLOAD_CLONESET].GroupResource(), "")
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	d := newWorkloadDiscovery(kubeClient, stopCh)
+	if !cache.WaitForCacheSync(stopCh, d.informers[WORKLOAD_DEPLOYMENT].HasSynced, d.informers[WORKLOAD_STATEFULSET].HasSynced) {
+		t.Fatalf("failed to sync informers")
+	}
+
+	if appName, _, err := d.app("default", ""); err != nil || appName != "orders-app" {
+		t.Fatalf("unexpected app %s without clonesets: %v", appName, err)
+	}
+	if _, _, err := d.app("default", "cloneset.orders"); err == nil {
+		t.Fatalf("expected error for clonesets not installed")
+	}
+	// no pod of the app is running
+	if _, err := d.instances("default", &AHASSentinelParams{SentinelGlobalParams: SentinelGlobalParams{AppName: "orders-app"}, Port: DefaultTransportPort}); err == nil {
+		t.Fatalf("expected error without running pods")
+	}
+}
+
+func TestStripObjects(t *testing.T) {
+	u := newWorkload("apps/v1", "Deployment", "orders",
+		map[string]interface{}{AHAS_APP_NAME_ANNOTATION_KEY: "orders-app", "kubectl.kubernetes.io/last-applied-configuration": "{}"},
+		map[string]interface{}{AHAS_NAMESPACE_ANNOTATION_KEY: "prod", "prometheus.io/scrape": "true"})
+	u.Object["status"] = map[string]interface{}{"replicas": int64(2)}
+	u.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+
+	s := stripWorkload(u)
+	if _, found := s.Object["status"]; found || len(s.GetManagedFields()) != 0 {
+		t.Fatalf("status and managedFields should be stripped: %v", s.Object)
+	}
+	if annotations := s.GetAnnotations(); len(annotations) != 1 || annotations[AHAS_APP_NAME_ANNOTATION_KEY] != "orders-app" {
+		t.Fatalf("unexpected annotations of workload: %v", annotations)
+	}
+	w, err := toWorkload(WORKLOAD_DEPLOYMENT, s)
+	if err != nil || w.AppName != "orders-app" || w.Selector.String() != "app=orders" {
+		t.Fatalf("unexpected workload %+v: %v", w, err)
+	}
+	templateAnnotations, _, _ := unstructured.NestedStringMap(s.Object, "spec", "template", "metadata", "annotations")
+	if len(templateAnnotations) != 1 || templateAnnotations[AHAS_NAMESPACE_ANNOTATION_KEY] != "prod" {
+		t.Fatalf("unexpected annotations of pod template: %v", templateAnnotations)
+	}
+
+	pod := stripPod(newPod("orders-0", "orders", "Running", "10.0.0.1"))
+	if pod.GetLabels()["app"] != "orders" || pod.Object["status"].(map[string]interface{})["podIP"] != "10.0.0.1" {
+		t.Fatalf("unexpected pod: %v", pod.Object)
+	}
+}
@@ -0,0 +1,96 @@
+package ahas
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the annotations of sentinel apps, the other annotations such as last-applied-configuration are not cached
+var appAnnotations = []string{AHAS_APP_NAME_ANNOTATION_KEY, AHAS_NAMESPACE_ANNOTATION_KEY}
+
+// newStrippedInformer watches the resource in all namespaces and only caches the objects stripped by strip,
+// so the specs, status and managedFields of workloads and pods are not kept in memory.
+func newStrippedInformer(kubeClient dynamic.Interface, gvr schema.GroupVersionResource, strip func(u *unstructured.Unstructured) *unstructured.Unstructured) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list, err := kubeClient.Resource(gvr).List(context.Background(), options)
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				list.Items[i] = *strip(&list.Items[i])
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := kubeClient.Resource(gvr).Watch(context.Background(), options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+				if u, ok := e.Object.(*unstructured.Unstructured); ok {
+					e.Object = strip(u)
+				}
+				return e, true
+			}), nil
+		},
+	}, &unstructured.Unstructured{}, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// stripWorkload keeps the selector and the annotations of sentinel apps of workload and its pod template
+func stripWorkload(u *unstructured.Unstructured) *unstructured.Unstructured {
+	s := stripMetadata(u)
+	s.SetAnnotations(filterAnnotations(u.GetAnnotations()))
+	copyField(u, s, "spec", "selector")
+	templateAnnotations, _, _ := unstructured.NestedStringMap(u.Object, "spec", "template", "metadata", "annotations")
+	if annotations := filterAnnotations(templateAnnotations); annotations != nil {
+		unstructured.SetNestedStringMap(s.Object, annotations, "spec", "template", "metadata", "annotations")
+	}
+	return s
+}
+
+// stripPod keeps the labels, phase and IP of pod
+func stripPod(u *unstructured.Unstructured) *unstructured.Unstructured {
+	s := stripMetadata(u)
+	s.SetLabels(u.GetLabels())
+	copyField(u, s, "status", "phase")
+	copyField(u, s, "status", "podIP")
+	return s
+}
+
+func stripMetadata(u *unstructured.Unstructured) *unstructured.Unstructured {
+	s := &unstructured.Unstructured{Object: make(map[string]interface{})}
+	s.SetAPIVersion(u.GetAPIVersion())
+	s.SetKind(u.GetKind())
+	s.SetNamespace(u.GetNamespace())
+	s.SetName(u.GetName())
+	s.SetUID(u.GetUID())
+	s.SetResourceVersion(u.GetResourceVersion())
+	return s
+}
+
+func filterAnnotations(annotations map[string]string) map[string]string {
+	var filtered map[string]string
+	for _, key := range appAnnotations {
+		if value, ok := annotations[key]; ok {
+			if filtered == nil {
+				filtered = make(map[string]string)
+			}
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+func copyField(from, to *unstructured.Unstructured, fields ...string) {
+	if value, found, _ := unstructured.NestedFieldNoCopy(from.Object, fields...); found {
+		unstructured.SetNestedField(to.Object, runtime.DeepCopyJSONValue(value), fields...)
+	}
+}
@@ -0,0 +1,110 @@
+package ahas
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "k8s.io/klog/v2"
+	"sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	// the aggregations of the values of machines
+	AGGREGATION_AVG = "avg"
+	AGGREGATION_MAX = "max"
+)
+
+// getResourceMetric gets the metric of a sentinel resource from the transports of app instances,
+// the values of machines are aggregated by params.Aggregation.
+func (s *AHASSentinelMetricSource) getResourceMetric(info provider.ExternalMetricInfo, namespace string, params *AHASSentinelParams) (float64, error) {
+	instances := s.instances
+	if instances == nil {
+		instances = s.getDiscovery().instances
+	}
+	fetch := s.fetch
+	if fetch == nil {
+		fetch = fetchMetricNodes
+	}
+
+	addrs, err := instances(namespace, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list instances of sentinel app %s,because of %v", params.AppName, err)
+	}
+	end := time.Now().Add(-1 * time.Duration(params.QueryStartOffset) * time.Second)
+	start := end.Add(-1 * time.Duration(params.Interval) * time.Second)
+
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		machines = make([]float64, 0, len(addrs))
+		lastErr  error
+	)
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			nodes, err := fetch(addr, params.Resource, start, end)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				log.Warningf("Failed to fetch metrics of resource %s from %s,because of %v", params.Resource, addr, err)
+				lastErr = err
+				return
+			}
+			machines = append(machines, resolveNodes(info.Metric, nodes, params.Interval))
+		}(addr)
+	}
+	wg.Wait()
+	if len(machines) == 0 {
+		return 0, fmt.Errorf("failed to fetch metrics of resource %s from any instance,because of %v", params.Resource, lastErr)
+	}
+	return aggregate(machines, params.Aggregation), nil
+}
+
+// resolveNodes returns the metric of a machine from its statistics per second
+func resolveNodes(metric string, nodes []*metricNode, interval int) float64 {
+	var pass, block, exception, success, rt float64
+	for _, node := range nodes {
+		pass += node.PassQps
+		block += node.BlockQps
+		exception += node.ExceptionQps
+		success += node.SuccessQps
+		rt += node.Rt * node.SuccessQps
+	}
+	switch metric {
+	case AHAS_SENTINEL_TOTAL_QPS:
+		return (pass + block) / float64(interval)
+	case AHAS_SENTINEL_PASS_QPS:
+		return pass / float64(interval)
+	case AHAS_SENTINEL_BLOCK_QPS:
+		return block / float64(interval)
+	case AHAS_SENTINEL_EXCEPTION_QPS:
+		return exception / float64(interval)
+	case AHAS_SENTINEL_AVG_RT:
+		if success <= 0 {
+			return 0
+		}
+		return rt / success
+	default:
+		return 0
+	}
+}
+
+func aggregate(values []float64, aggregation string) float64 {
+	var result float64
+	for _, v := range values {
+		switch aggregation {
+		case AGGREGATION_MAX:
+			if v > result {
+				result = v
+			}
+		default:
+			result += v
+		}
+	}
+	if aggregation == AGGREGATION_MAX {
+		return result
+	}
+	return result / float64(len(values))
+}
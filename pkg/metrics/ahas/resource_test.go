@@ -0,0 +1,131 @@
+package ahas
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+func TestParseMetricNode(t *testing.T) {
+	node, err := parseMetricNode("1700000000000|/api/orders|20|5|18|2|12.5|0|3|1")
+	if err != nil {
+		t.Fatalf("failed to parse metric node: %v", err)
+	}
+	if node.Resource != "/api/orders" || node.PassQps != 20 || node.BlockQps != 5 || node.ExceptionQps != 2 || node.Rt != 12.5 {
+		t.Fatalf("unexpected metric node: %+v", node)
+	}
+	for _, line := range []string{"1700000000000|/api/orders|20", "now|/api/orders|20|5|18|2|12.5", "1700000000000|/api/orders|x|5|18|2|12.5"} {
+		if _, err := parseMetricNode(line); err == nil {
+			t.Fatalf("expected error for line %q", line)
+		}
+	}
+}
+
+func TestResourceLabelValue(t *testing.T) {
+	cases := map[string]string{
+		"/api/orders":          "api_orders",
+		"GET:/api/orders/{id}": "GET__api_orders__id",
+		"com.demo.OrderService:get(java.lang.String)": "com.demo.OrderService_get_java.lang.String",
+		"orders-api": "orders-api",
+	}
+	for resource, expected := range cases {
+		if v := resourceLabelValue(resource); v != expected {
+			t.Fatalf("unexpected label value %s of %s, expected %s", v, resource, expected)
+		}
+	}
+}
+
+func TestFetchMetricNodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metric" || r.FormValue("startTime") == "" || r.FormValue("endTime") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("1700000000000|/api/orders|20|5|18|2|12.5|0|3|1\n\n1700000000000|/api/users|1|0|1|0|1|0|0|1\n"))
+	}))
+	defer server.Close()
+
+	addr := strings.TrimPrefix(server.URL, "http://")
+	nodes, err := fetchMetricNodes(addr, "api_orders", time.Now().Add(-time.Minute), time.Now())
+	if err != nil {
+		t.Fatalf("failed to fetch metric nodes: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].PassQps != 20 {
+		t.Fatalf("unexpected metric nodes: %v", nodes)
+	}
+	if nodes, err := fetchMetricNodes(addr, "api_unknown", time.Now().Add(-time.Minute), time.Now()); err != nil || len(nodes) != 0 {
+		t.Fatalf("unexpected metric nodes of unknown resource: %v, %v", nodes, err)
+	}
+	if _, err := fetchMetricNodes(strings.TrimPrefix(server.URL, "http://")+"0", "api_orders", time.Now(), time.Now()); err == nil {
+		t.Fatalf("expected error for unreachable instance")
+	}
+}
+
+func TestGetResourceMetric(t *testing.T) {
+	machines := map[string][]*metricNode{
+		"10.0.0.1:8719": {
+			{Resource: "/api/orders", PassQps: 10, BlockQps: 2, SuccessQps: 10, ExceptionQps: 1, Rt: 10},
+			{Resource: "/api/orders", PassQps: 30, BlockQps: 0, SuccessQps: 30, ExceptionQps: 3, Rt: 30},
+		},
+		"10.0.0.2:8719": {
+			{Resource: "/api/orders", PassQps: 20, BlockQps: 0, SuccessQps: 20, ExceptionQps: 0, Rt: 5},
+		},
+	}
+	s := &AHASSentinelMetricSource{
+		instances: func(namespace string, params *AHASSentinelParams) ([]string, error) {
+			if namespace != "default" || params.AppName != "orders" || params.Port != DefaultTransportPort {
+				return nil, errors.New("unexpected app")
+			}
+			return []string{"10.0.0.1:8719", "10.0.0.2:8719", "10.0.0.3:8719"}, nil
+		},
+		fetch: func(addr, resource string, start, end time.Time) ([]*metricNode, error) {
+			if end.Sub(start) != 2*time.Second {
+				return nil, errors.New("unexpected interval")
+			}
+			nodes, ok := machines[addr]
+			if !ok {
+				return nil, errors.New("connection refused")
+			}
+			return nodes, nil
+		},
+	}
+
+	cases := []struct {
+		metric      string
+		aggregation string
+		expected    string
+	}{
+		{AHAS_SENTINEL_PASS_QPS, AGGREGATION_AVG, "15"},
+		{AHAS_SENTINEL_PASS_QPS, AGGREGATION_MAX, "20"},
+		{AHAS_SENTINEL_TOTAL_QPS, AGGREGATION_MAX, "21"},
+		{AHAS_SENTINEL_EXCEPTION_QPS, AGGREGATION_AVG, "1"},
+		{AHAS_SENTINEL_AVG_RT, AGGREGATION_MAX, "25"},
+	}
+	for _, c := range cases {
+		selector, _ := labels.Parse("ahas.sentinel.app=orders,ahas.sentinel.source=transport,ahas.sentinel.interval=2,ahas.sentinel.resource=orders-api,ahas.sentinel.aggregation=" + c.aggregation)
+		requirements, _ := selector.Requirements()
+		values, err := s.GetExternalMetric(p.ExternalMetricInfo{Metric: c.metric}, "default", requirements)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", c.metric, err)
+		}
+		if len(values) != 1 || values[0].Value.String() != c.expected {
+			t.Fatalf("unexpected %s with %s: %v, expected %s", c.metric, c.aggregation, values, c.expected)
+		}
+	}
+
+	// exceptions and resources are not available in the app sum metrics of AHAS OpenAPI,
+	// and the transports are only scraped for resources
+	for _, selector := range []string{"ahas.sentinel.app=orders", "ahas.sentinel.app=orders,ahas.sentinel.resource=orders-api", "ahas.sentinel.app=orders,ahas.sentinel.source=transport"} {
+		selector, _ := labels.Parse(selector)
+		requirements, _ := selector.Requirements()
+		if _, err := s.GetExternalMetric(p.ExternalMetricInfo{Metric: AHAS_SENTINEL_EXCEPTION_QPS}, "default", requirements); err == nil {
+			t.Fatalf("expected error for exceptions of %s", selector)
+		}
+	}
+}
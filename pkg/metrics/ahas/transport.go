@@ -0,0 +1,95 @@
+package ahas
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the default port of sentinel transport, see csp.sentinel.api.port
+	DefaultTransportPort = 8719
+
+	// the timeout to fetch metrics from an instance
+	transportTimeout = 3 * time.Second
+)
+
+var transportClient = &http.Client{Timeout: transportTimeout}
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// resourceLabelValue returns the name of resource in the form of label value, e.g. /api/orders is api_orders,
+// since the resources of interfaces or URLs can't be used as the value of ahas.sentinel.resource.
+func resourceLabelValue(resource string) string {
+	return strings.Trim(invalidLabelChars.ReplaceAllString(resource, "_"), "_.-")
+}
+
+// metricNode is a line of the metric command of sentinel transport, which is the statistic of a resource in a second:
+// timestamp|resource|passQps|blockQps|successQps|exceptionQps|rt|occupiedPassQps|concurrency|classification
+type metricNode struct {
+	Timestamp    int64
+	Resource     string
+	PassQps      float64
+	BlockQps     float64
+	SuccessQps   float64
+	ExceptionQps float64
+	// the average rt of the second in ms
+	Rt float64
+}
+
+func parseMetricNode(line string) (*metricNode, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) < 7 {
+		return nil, fmt.Errorf("invalid metric line %q", line)
+	}
+	node := &metricNode{Resource: parts[1]}
+	var err error
+	if node.Timestamp, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid timestamp of metric line %q", line)
+	}
+	values := []*float64{&node.PassQps, &node.BlockQps, &node.SuccessQps, &node.ExceptionQps, &node.Rt}
+	for i, v := range values {
+		if *v, err = strconv.ParseFloat(parts[i+2], 64); err != nil {
+			return nil, fmt.Errorf("invalid value of metric line %q", line)
+		}
+	}
+	return node, nil
+}
+
+// fetchMetricNodes gets the metrics of the resource in [start, end] from the transport of an instance,
+// the resource is matched by the form of label value.
+func fetchMetricNodes(addr, resource string, start, end time.Time) ([]*metricNode, error) {
+	query := url.Values{}
+	query.Set("startTime", strconv.FormatInt(start.UnixNano()/int64(time.Millisecond), 10))
+	query.Set("endTime", strconv.FormatInt(end.UnixNano()/int64(time.Millisecond), 10))
+	resp, err := transportClient.Get(fmt.Sprintf("http://%s/metric?%s", addr, query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d of sentinel transport %s", resp.StatusCode, addr)
+	}
+
+	nodes := make([]*metricNode, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		node, err := parseMetricNode(line)
+		if err != nil {
+			return nil, err
+		}
+		if resourceLabelValue(node.Resource) == resource {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, scanner.Err()
+}
@@ -0,0 +1,270 @@
+package alb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	// listener metrics
+	ALB_LISTENER_QPS               = "alb_listener_qps"
+	ALB_LISTENER_RT                = "alb_listener_rt"
+	ALB_LISTENER_STATUS_2XX        = "alb_listener_status_2xx"
+	ALB_LISTENER_STATUS_4XX        = "alb_listener_status_4xx"
+	ALB_LISTENER_STATUS_5XX        = "alb_listener_status_5xx"
+	ALB_LISTENER_ACTIVE_CONNECTION = "alb_listener_active_connection"
+	ALB_LISTENER_UPSTREAM_4XX      = "alb_listener_upstream_4xx"
+	ALB_LISTENER_UPSTREAM_5XX      = "alb_listener_upstream_5xx"
+
+	// server group metrics
+	ALB_SERVER_GROUP_QPS          = "alb_server_group_qps"
+	ALB_SERVER_GROUP_RT           = "alb_server_group_rt"
+	ALB_SERVER_GROUP_STATUS_2XX   = "alb_server_group_status_2xx"
+	ALB_SERVER_GROUP_STATUS_4XX   = "alb_server_group_status_4xx"
+	ALB_SERVER_GROUP_STATUS_5XX   = "alb_server_group_status_5xx"
+	ALB_SERVER_GROUP_UPSTREAM_4XX = "alb_server_group_upstream_4xx"
+	ALB_SERVER_GROUP_UPSTREAM_5XX = "alb_server_group_upstream_5xx"
+
+	//Global Params
+	ALB_INSTANCE_ID       = "alb.instance.id"
+	ALB_LISTENER_PORT     = "alb.listener.port"
+	ALB_LISTENER_PROTOCOL = "alb.listener.protocol"
+	ALB_SERVER_GROUP_ID   = "alb.server.group.id"
+	ALB_INGRESS_NAME      = "alb.ingress.name"
+	ALB_INGRESS_NAMESPACE = "alb.ingress.namespace"
+	// the backend of ALB Ingress to resolve the server group
+	ALB_INGRESS_SERVICE      = "alb.ingress.service"
+	ALB_INGRESS_SERVICE_PORT = "alb.ingress.service.port"
+	ALB_PERIOD               = "alb.period"
+
+	ALB_NAMESPACE = "acs_alb"
+
+	DEFAULT_LISTENER_PROTOCOL = "HTTP"
+	DEFAULT_LISTENER_PORT     = "80"
+)
+
+// albMetric is the cms metric of acs_alb namespace
+type albMetric struct {
+	name string
+	// the metric is aggregated by server group instead of listener
+	serverGroup bool
+	unit        utils.Unit
+}
+
+var albMetrics = map[string]albMetric{
+	ALB_LISTENER_QPS:               {name: "ListenerQPS", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_LISTENER_RT:                {name: "ListenerRT", unit: utils.Unit{Name: utils.UNIT_MILLISECONDS}},
+	ALB_LISTENER_STATUS_2XX:        {name: "ListenerHTTPCode2XX", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_LISTENER_STATUS_4XX:        {name: "ListenerHTTPCode4XX", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_LISTENER_STATUS_5XX:        {name: "ListenerHTTPCode5XX", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_LISTENER_ACTIVE_CONNECTION: {name: "ListenerActiveConnection", unit: utils.Unit{Name: utils.UNIT_COUNT}},
+	ALB_LISTENER_UPSTREAM_4XX:      {name: "ListenerHTTPCodeUpstream4XX", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_LISTENER_UPSTREAM_5XX:      {name: "ListenerHTTPCodeUpstream5XX", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_SERVER_GROUP_QPS:           {name: "ServerGroupQPS", serverGroup: true, unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_SERVER_GROUP_RT:            {name: "ServerGroupRT", serverGroup: true, unit: utils.Unit{Name: utils.UNIT_MILLISECONDS}},
+	ALB_SERVER_GROUP_STATUS_2XX:    {name: "ServerGroupHTTPCode2XX", serverGroup: true, unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_SERVER_GROUP_STATUS_4XX:    {name: "ServerGroupHTTPCode4XX", serverGroup: true, unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_SERVER_GROUP_STATUS_5XX:    {name: "ServerGroupHTTPCode5XX", serverGroup: true, unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_SERVER_GROUP_UPSTREAM_4XX:  {name: "ServerGroupHTTPCodeUpstream4XX", serverGroup: true, unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	ALB_SERVER_GROUP_UPSTREAM_5XX:  {name: "ServerGroupHTTPCodeUpstream5XX", serverGroup: true, unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+}
+
+// ALBMetricSource serves the metrics of ALB listeners and server groups from cms.
+type ALBMetricSource struct {
+	clients utils.ClientCache
+	// the clients of ALB OpenAPI to resolve server groups
+	albClients utils.ClientCache
+	// listServerGroupsByTags is used if nil
+	listServerGroups func(options utils.AccessOptions, tags map[string]string) ([]serverGroup, error)
+}
+
+func NewALBMetricSource() *ALBMetricSource {
+	return &ALBMetricSource{}
+}
+
+// list all external metric
+func (as *ALBMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	for metric := range albMetrics {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: metric,
+		})
+	}
+	return metricInfoList
+}
+
+// according to the incoming label, get the metric..
+func (as *ALBMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	metric, ok := albMetrics[info.Metric]
+	if !ok {
+		return values, fmt.Errorf("metric %s is not supported by alb", info.Metric)
+	}
+	values, err = as.getALBMetrics(namespace, info.Metric, metric, requirements)
+	if err != nil {
+		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
+	}
+	return values, err
+}
+
+// the listener and server group datapoints of acs_alb are aggregated per minute,
+// so the HPAs scaled by the same ingress share one query in cmsutil.CACHE_TTL
+func (as *ALBMetricSource) CacheTTL() time.Duration {
+	return cmsutil.CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (as *ALBMetricSource) MetricUnits() map[string]utils.Unit {
+	units := make(map[string]utils.Unit)
+	for name, metric := range albMetrics {
+		units[name] = metric.unit
+	}
+	return units
+}
+
+// RunUntil starts watching ingresses to resolve ALB instances, listeners and server groups.
+func (as *ALBMetricSource) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	utils.GetIngressLister().Run(kubeClient, stopCh)
+}
+
+type ALBParams struct {
+	InstanceId       string
+	ListenerProtocol string
+	ListenerPort     string
+	ServerGroupId    string
+	IngressName      string
+	IngressNamespace string
+	// the backend service of ingress
+	IngressService     string
+	IngressServicePort string
+	Period             int
+}
+
+// cms dimensions of the metric
+func (params *ALBParams) dimensions(metric albMetric) map[string]string {
+	if metric.serverGroup {
+		return map[string]string{
+			"instanceId":    params.InstanceId,
+			"serverGroupId": params.ServerGroupId,
+		}
+	}
+	return map[string]string{
+		"instanceId":       params.InstanceId,
+		"listenerProtocol": params.ListenerProtocol,
+		"listenerPort":     params.ListenerPort,
+	}
+}
+
+// get the alb specific metric values
+func (as *ALBMetricSource) getALBMetrics(namespace, externalMetric string, metric albMetric, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	params, err := getALBParams(requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get alb params,because of %v", err)
+	}
+
+	if params.IngressName != "" {
+		if params.IngressNamespace == "" {
+			params.IngressNamespace = namespace
+		}
+		if err := as.resolveIngress(params, metric, utils.GetAccessOptions(namespace, requirements)); err != nil {
+			return values, err
+		}
+	}
+
+	if err := validateALBParams(params, metric); err != nil {
+		return values, err
+	}
+
+	client, err := cmsutil.NewClient(&as.clients, utils.GetAccessOptions(namespace, requirements))
+	if err != nil {
+		return values, err
+	}
+
+	points, err := cmsutil.DescribeMetricList(client, cmsutil.NewMetricListParams(ALB_NAMESPACE, metric.name, params.dimensions(metric), params.Period))
+	if err != nil {
+		return values, err
+	}
+
+	statistic, err := cmsutil.Aggregate(points, cmsutil.STATISTIC_AVERAGE, cmsutil.AGGREGATION_LATEST)
+	if err != nil {
+		return values, err
+	}
+	value, err := utils.ConvertValue(statistic, metric.unit)
+	if err != nil {
+		return values, err
+	}
+
+	values = append(values, external_metrics.ExternalMetricValue{
+		MetricName: externalMetric,
+		Value:      *value,
+		Timestamp:  metav1.Now(),
+	})
+	return values, nil
+}
+
+// get the alb Params
+func getALBParams(requirements labels.Requirements) (params *ALBParams, err error) {
+	params = &ALBParams{
+		Period: cmsutil.MIN_PERIOD,
+	}
+	for _, r := range requirements {
+
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+
+		value := r.Values().List()[0]
+
+		switch r.Key() {
+		case ALB_INSTANCE_ID:
+			params.InstanceId = value
+		case ALB_LISTENER_PORT:
+			params.ListenerPort = value
+		case ALB_LISTENER_PROTOCOL:
+			params.ListenerProtocol = strings.ToUpper(value)
+		case ALB_SERVER_GROUP_ID:
+			params.ServerGroupId = value
+		case ALB_INGRESS_NAME:
+			params.IngressName = value
+		case ALB_INGRESS_NAMESPACE:
+			params.IngressNamespace = value
+		case ALB_INGRESS_SERVICE:
+			params.IngressService = value
+		case ALB_INGRESS_SERVICE_PORT:
+			params.IngressServicePort = value
+		case ALB_PERIOD:
+			params.Period = cmsutil.ParsePeriod(value)
+		}
+	}
+
+	return params, nil
+}
+
+// check the params after resolving ingress
+func validateALBParams(params *ALBParams, metric albMetric) error {
+	if params.InstanceId == "" {
+		return fmt.Errorf("%s or %s must be provided", ALB_INSTANCE_ID, ALB_INGRESS_NAME)
+	}
+	if metric.serverGroup {
+		if params.ServerGroupId == "" {
+			return fmt.Errorf("%s or %s must be provided", ALB_SERVER_GROUP_ID, ALB_INGRESS_NAME)
+		}
+		return nil
+	}
+	if params.ListenerProtocol == "" {
+		params.ListenerProtocol = DEFAULT_LISTENER_PROTOCOL
+	}
+	if params.ListenerPort == "" {
+		params.ListenerPort = DEFAULT_LISTENER_PORT
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package alb
+
+import (
+	"testing"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetALBParams(t *testing.T) {
+	params, err := getALBParams(testutil.ParseRequirements(t, "alb.instance.id=alb-1,alb.listener.port=443,alb.listener.protocol=https,alb.period=30"))
+	if err != nil {
+		t.Fatalf("failed to get alb params: %v", err)
+	}
+	if params.InstanceId != "alb-1" || params.ListenerPort != "443" || params.ListenerProtocol != "HTTPS" || params.Period != cmsutil.MIN_PERIOD {
+		t.Fatalf("unexpected alb params: %+v", params)
+	}
+
+	if err := validateALBParams(&ALBParams{}, albMetrics[ALB_LISTENER_QPS]); err == nil {
+		t.Fatalf("expect error without instance id")
+	}
+	if err := validateALBParams(&ALBParams{InstanceId: "alb-1"}, albMetrics[ALB_SERVER_GROUP_QPS]); err == nil {
+		t.Fatalf("expect error without server group id")
+	}
+
+	params = &ALBParams{InstanceId: "alb-1"}
+	if err := validateALBParams(params, albMetrics[ALB_LISTENER_QPS]); err != nil {
+		t.Fatalf("failed to validate alb params: %v", err)
+	}
+	dimensions := params.dimensions(albMetrics[ALB_LISTENER_QPS])
+	if dimensions["listenerProtocol"] != DEFAULT_LISTENER_PROTOCOL || dimensions["listenerPort"] != DEFAULT_LISTENER_PORT {
+		t.Fatalf("unexpected dimensions: %v", dimensions)
+	}
+}
+
+func newIngress(listenPorts string) *unstructured.Unstructured {
+	ingress := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"loadBalancer": map[string]interface{}{
+				"ingress": []interface{}{
+					map[string]interface{}{"hostname": "alb-abc123.cn-hangzhou.alb.aliyuncs.com"},
+				},
+			},
+		},
+	}}
+	ingress.SetNamespace("default")
+	ingress.SetName("web")
+	if listenPorts != "" {
+		ingress.SetAnnotations(map[string]string{LISTEN_PORTS_ANNOTATION: listenPorts})
+	}
+	return ingress
+}
+
+func TestResolveALBParams(t *testing.T) {
+	params := &ALBParams{}
+	if err := resolveALBParams(newIngress(`[{"HTTPS": 443}]`), params); err != nil {
+		t.Fatalf("failed to resolve ingress: %v", err)
+	}
+	if params.InstanceId != "alb-abc123" || params.ListenerProtocol != "HTTPS" || params.ListenerPort != "443" {
+		t.Fatalf("unexpected params resolved from ingress: %+v", params)
+	}
+
+	params = &ALBParams{ListenerPort: "8080"}
+	if err := resolveALBParams(newIngress(`[{"HTTP": 80},{"HTTP": 8080}]`), params); err != nil {
+		t.Fatalf("failed to resolve ingress: %v", err)
+	}
+	if params.ListenerProtocol != "HTTP" || params.ListenerPort != "8080" {
+		t.Fatalf("listener port provided should not be overridden: %+v", params)
+	}
+
+	params = &ALBParams{}
+	if err := resolveALBParams(newIngress(""), params); err != nil {
+		t.Fatalf("failed to resolve ingress: %v", err)
+	}
+	if params.ListenerPort != "" {
+		t.Fatalf("listener should be defaulted later: %+v", params)
+	}
+
+	ingress := newIngress("")
+	unstructured.RemoveNestedField(ingress.Object, "status")
+	if err := resolveALBParams(ingress, &ALBParams{}); err == nil {
+		t.Fatalf("expect error for ingress without ALB address")
+	}
+}
+
+func TestGetBackends(t *testing.T) {
+	ingress := newIngress("")
+	ingress.Object["spec"] = map[string]interface{}{
+		"defaultBackend": map[string]interface{}{
+			"service": map[string]interface{}{"name": "web", "port": map[string]interface{}{"number": int64(80)}},
+		},
+		"rules": []interface{}{
+			map[string]interface{}{"http": map[string]interface{}{"paths": []interface{}{
+				map[string]interface{}{"backend": map[string]interface{}{
+					"service": map[string]interface{}{"name": "web", "port": map[string]interface{}{"number": int64(80)}},
+				}},
+				map[string]interface{}{"backend": map[string]interface{}{
+					"service": map[string]interface{}{"name": "api", "port": map[string]interface{}{"name": "http"}},
+				}},
+			}}},
+		},
+	}
+	backends, err := getBackends(ingress)
+	if err != nil {
+		t.Fatalf("failed to get backends: %v", err)
+	}
+	if len(backends) != 2 || backends[0] != (backend{name: "web", port: "80"}) || backends[1] != (backend{name: "api", portName: "http"}) {
+		t.Fatalf("unexpected backends: %v", backends)
+	}
+}
+
+func TestResolveServerGroup(t *testing.T) {
+	newGroup := func(id, namespace, name, port string) serverGroup {
+		g := serverGroup{ServerGroupId: id}
+		for key, value := range map[string]string{SERVICE_NAMESPACE_TAG: namespace, SERVICE_NAME_TAG: name, SERVICE_PORT_TAG: port} {
+			g.Tags = append(g.Tags, serverGroupTag{Key: key, Value: value})
+		}
+		return g
+	}
+	as := &ALBMetricSource{
+		listServerGroups: func(options utils.AccessOptions, tags map[string]string) ([]serverGroup, error) {
+			if tags[SERVICE_NAMESPACE_TAG] != "default" {
+				return nil, nil
+			}
+			return []serverGroup{
+				newGroup("sgp-web-80", "default", "web", "80"),
+				newGroup("sgp-web-8080", "default", "web", "8080"),
+				newGroup("sgp-api-80", "default", "api", "80"),
+				newGroup("sgp-other", "other", "web", "80"),
+			}, nil
+		},
+	}
+	backends := []backend{{name: "web", port: "80"}, {name: "api"}}
+
+	params := &ALBParams{IngressName: "web", IngressNamespace: "default"}
+	if err := as.resolveServerGroup(backends, params, utils.AccessOptions{}); err == nil {
+		t.Fatalf("expected error for several backends")
+	}
+	params.IngressService = "web"
+	if err := as.resolveServerGroup(backends, params, utils.AccessOptions{}); err != nil || params.ServerGroupId != "sgp-web-80" {
+		t.Fatalf("unexpected server group %s: %v", params.ServerGroupId, err)
+	}
+	params = &ALBParams{IngressName: "web", IngressNamespace: "default", IngressService: "api"}
+	if err := as.resolveServerGroup(backends, params, utils.AccessOptions{}); err != nil || params.ServerGroupId != "sgp-api-80" {
+		t.Fatalf("unexpected server group %s: %v", params.ServerGroupId, err)
+	}
+	// the backend referencing the port by name is selected by the name
+	params = &ALBParams{IngressName: "web", IngressNamespace: "default", IngressServicePort: "http"}
+	if err := as.resolveServerGroup([]backend{{name: "web", port: "80"}, {name: "api", portName: "http"}}, params, utils.AccessOptions{}); err != nil || params.ServerGroupId != "sgp-api-80" {
+		t.Fatalf("unexpected server group %s of named port: %v", params.ServerGroupId, err)
+	}
+	// the named port can't be matched when the service has several server groups
+	if err := as.resolveServerGroup([]backend{{name: "web"}}, &ALBParams{IngressNamespace: "default"}, utils.AccessOptions{}); err == nil {
+		t.Fatalf("expected error for several server groups")
+	}
+	if err := as.resolveServerGroup(backends, &ALBParams{IngressNamespace: "default", IngressService: "unknown"}, utils.AccessOptions{}); err == nil {
+		t.Fatalf("expected error for unknown backend")
+	}
+}
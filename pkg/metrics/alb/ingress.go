@@ -0,0 +1,157 @@
+package alb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// listeners of ALB Ingress, such as [{"HTTP": 80},{"HTTPS": 443}]
+	LISTEN_PORTS_ANNOTATION = "alb.ingress.kubernetes.io/listen-ports"
+
+	// the address of ALB instance, such as alb-xxx.cn-hangzhou.alb.aliyuncs.com
+	ALB_INSTANCE_PREFIX = "alb-"
+)
+
+// fill the ALB instance, listener and server group of params with the ALB Ingress from cache
+func (as *ALBMetricSource) resolveIngress(params *ALBParams, metric albMetric, options utils.AccessOptions) error {
+	ingress, err := utils.GetIngressLister().Get(params.IngressNamespace, params.IngressName)
+	if err != nil {
+		return err
+	}
+	if err := resolveALBParams(ingress, params); err != nil {
+		return err
+	}
+	if !metric.serverGroup || params.ServerGroupId != "" {
+		return nil
+	}
+	backends, err := getBackends(ingress)
+	if err != nil {
+		return err
+	}
+	return as.resolveServerGroup(backends, params, options)
+}
+
+// the ALB instance is resolved from the address of ingress, and the listener from annotation.
+// the params provided explicitly are not overridden.
+func resolveALBParams(ingress *unstructured.Unstructured, params *ALBParams) error {
+	if params.InstanceId == "" {
+		instanceId, err := getInstanceId(ingress)
+		if err != nil {
+			return err
+		}
+		params.InstanceId = instanceId
+	}
+
+	listeners, err := getListeners(ingress)
+	if err != nil {
+		return err
+	}
+	if params.ListenerPort == "" && len(listeners) > 0 {
+		params.ListenerProtocol, params.ListenerPort = listeners[0].protocol, listeners[0].port
+		return nil
+	}
+	if params.ListenerProtocol == "" {
+		for _, l := range listeners {
+			if l.port == params.ListenerPort {
+				params.ListenerProtocol = l.protocol
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func getInstanceId(ingress *unstructured.Unstructured) (string, error) {
+	addresses, _, err := unstructured.NestedSlice(ingress.Object, "status", "loadBalancer", "ingress")
+	if err != nil {
+		return "", fmt.Errorf("invalid status of ingress %s/%s,because of %v", ingress.GetNamespace(), ingress.GetName(), err)
+	}
+	for _, address := range addresses {
+		a, ok := address.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hostname, _ := a["hostname"].(string)
+		if strings.HasPrefix(hostname, ALB_INSTANCE_PREFIX) {
+			return strings.SplitN(hostname, ".", 2)[0], nil
+		}
+	}
+	return "", fmt.Errorf("ALB instance of ingress %s/%s is not found", ingress.GetNamespace(), ingress.GetName())
+}
+
+type listener struct {
+	protocol string
+	port     string
+}
+
+func getListeners(ingress *unstructured.Unstructured) ([]listener, error) {
+	annotation, ok := ingress.GetAnnotations()[LISTEN_PORTS_ANNOTATION]
+	if !ok || annotation == "" {
+		return nil, nil
+	}
+	ports := make([]map[string]int, 0)
+	if err := json.Unmarshal([]byte(annotation), &ports); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of ingress %s/%s,because of %v", LISTEN_PORTS_ANNOTATION, ingress.GetNamespace(), ingress.GetName(), err)
+	}
+	listeners := make([]listener, 0)
+	for _, port := range ports {
+		for protocol, p := range port {
+			listeners = append(listeners, listener{
+				protocol: strings.ToUpper(protocol),
+				port:     strconv.Itoa(p),
+			})
+		}
+	}
+	return listeners, nil
+}
+
+// getBackends returns the distinct backend services of ingress rules and default backend
+func getBackends(ingress *unstructured.Unstructured) ([]backend, error) {
+	services := make([]map[string]interface{}, 0)
+	if service, found, _ := unstructured.NestedMap(ingress.Object, "spec", "defaultBackend", "service"); found {
+		services = append(services, service)
+	}
+	rules, _, err := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules of ingress %s/%s,because of %v", ingress.GetNamespace(), ingress.GetName(), err)
+	}
+	for _, rule := range rules {
+		r, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paths, _, _ := unstructured.NestedSlice(r, "http", "paths")
+		for _, path := range paths {
+			p, ok := path.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if service, found, _ := unstructured.NestedMap(p, "backend", "service"); found {
+				services = append(services, service)
+			}
+		}
+	}
+
+	backends := make([]backend, 0)
+	seen := make(map[backend]bool)
+	for _, service := range services {
+		b := backend{}
+		b.name, _, _ = unstructured.NestedString(service, "name")
+		if number, found, _ := unstructured.NestedInt64(service, "port", "number"); found {
+			b.port = strconv.FormatInt(number, 10)
+		}
+		b.portName, _, _ = unstructured.NestedString(service, "port", "name")
+		if b.name == "" || seen[b] {
+			continue
+		}
+		seen[b] = true
+		backends = append(backends, b)
+	}
+	return backends, nil
+}
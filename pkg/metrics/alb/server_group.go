@@ -0,0 +1,170 @@
+package alb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// the tags added by ALB Ingress controller to the server groups of Ingress backends
+	SERVICE_NAMESPACE_TAG = "service_ns"
+	SERVICE_NAME_TAG      = "service_name"
+	SERVICE_PORT_TAG      = "service_port"
+
+	ALB_API_VERSION = "2020-06-16"
+
+	listServerGroupsPageSize = 100
+)
+
+// serverGroup is the item of ListServerGroups
+type serverGroup struct {
+	ServerGroupId   string
+	ServerGroupName string
+	Tags            []serverGroupTag
+}
+
+type serverGroupTag struct {
+	Key   string
+	Value string
+}
+
+func (sg *serverGroup) tag(key string) string {
+	for _, t := range sg.Tags {
+		if t.Key == key {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+type listServerGroupsResponse struct {
+	NextToken    string
+	ServerGroups []serverGroup
+}
+
+// backend is the Service referenced by Ingress rules
+type backend struct {
+	name string
+	// the number of service port, empty if the port is referenced by name
+	port string
+	// the name of service port, empty if the port is referenced by number
+	portName string
+}
+
+// the port is selected by the number or name referenced by Ingress
+func (b *backend) matchPort(port string) bool {
+	return port == b.port || port == b.portName
+}
+
+// resolveServerGroup finds the server group of the Ingress backend by the tags of ALB Ingress controller,
+// the backend must be selected by alb.ingress.service if the Ingress has several ones.
+func (as *ALBMetricSource) resolveServerGroup(backends []backend, params *ALBParams, options utils.AccessOptions) error {
+	selected := make([]backend, 0)
+	for _, b := range backends {
+		if params.IngressService != "" && b.name != params.IngressService {
+			continue
+		}
+		if params.IngressServicePort != "" && !b.matchPort(params.IngressServicePort) {
+			continue
+		}
+		selected = append(selected, b)
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("backend service of ingress %s/%s is not found", params.IngressNamespace, params.IngressName)
+	}
+	if len(selected) > 1 {
+		return fmt.Errorf("ingress %s/%s has several backend services, use %s and %s to select one", params.IngressNamespace, params.IngressName, ALB_INGRESS_SERVICE, ALB_INGRESS_SERVICE_PORT)
+	}
+
+	listServerGroups := as.listServerGroups
+	if listServerGroups == nil {
+		listServerGroups = as.listServerGroupsByTags
+	}
+	tags := map[string]string{
+		SERVICE_NAMESPACE_TAG: params.IngressNamespace,
+		SERVICE_NAME_TAG:      selected[0].name,
+	}
+	groups, err := listServerGroups(options, tags)
+	if err != nil {
+		return fmt.Errorf("failed to list server groups of service %s/%s,because of %v", params.IngressNamespace, selected[0].name, err)
+	}
+	ids := make([]string, 0)
+	for _, g := range groups {
+		// the filter of tags is checked again, and the port is matched if it's known
+		if g.tag(SERVICE_NAMESPACE_TAG) != params.IngressNamespace || g.tag(SERVICE_NAME_TAG) != selected[0].name {
+			continue
+		}
+		if selected[0].port != "" && g.tag(SERVICE_PORT_TAG) != selected[0].port {
+			continue
+		}
+		ids = append(ids, g.ServerGroupId)
+	}
+	if len(ids) != 1 {
+		return fmt.Errorf("expected one server group of service %s/%s but found %v, use %s instead", params.IngressNamespace, selected[0].name, ids, ALB_SERVER_GROUP_ID)
+	}
+	params.ServerGroupId = ids[0]
+	return nil
+}
+
+// listServerGroupsByTags calls ListServerGroups of ALB OpenAPI with the tags
+func (as *ALBMetricSource) listServerGroupsByTags(options utils.AccessOptions, tags map[string]string) ([]serverGroup, error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	c, err := as.albClients.Get(accessUserInfo.Region, accessUserInfo, func() (interface{}, error) {
+		if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
+			return sdk.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
+		}
+		return sdk.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := c.(*sdk.Client)
+
+	groups := make([]serverGroup, 0)
+	nextToken := ""
+	for {
+		request := requests.NewCommonRequest()
+		request.Method = "POST"
+		request.Scheme = "https"
+		request.Product = "Alb"
+		request.Domain = fmt.Sprintf("alb.%s.aliyuncs.com", accessUserInfo.Region)
+		request.Version = ALB_API_VERSION
+		request.ApiName = "ListServerGroups"
+		request.QueryParams["MaxResults"] = strconv.Itoa(listServerGroupsPageSize)
+		if nextToken != "" {
+			request.QueryParams["NextToken"] = nextToken
+		}
+		i := 1
+		for key, value := range tags {
+			request.QueryParams[fmt.Sprintf("Tag.%d.Key", i)] = key
+			request.QueryParams[fmt.Sprintf("Tag.%d.Value", i)] = value
+			i++
+		}
+
+		response, err := client.ProcessCommonRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		page := &listServerGroupsResponse{}
+		if err := json.Unmarshal(response.GetHttpContentBytes(), page); err != nil {
+			return nil, fmt.Errorf("failed to parse response of ListServerGroups,because of %v", err)
+		}
+		groups = append(groups, page.ServerGroups...)
+		if page.NextToken == "" {
+			break
+		}
+		nextToken = page.NextToken
+	}
+	log.V(4).Infof("Server groups with tags %v: %d", tags, len(groups))
+	return groups, nil
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	cacheResultHit       = "hit"
+	cacheResultMiss      = "miss"
+	cacheResultCoalesced = "coalesced"
+)
+
+var (
+	// cacheRequests counts the external metric requests by cache result,
+	// hit ratio = hit / (hit + miss + coalesced)
+	cacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alibaba_cloud_metrics_adapter_external_metrics_cache_requests_total",
+			Help: "External metrics requests served by the cache of alibaba cloud metric sources. Broken down by metric and result (hit, miss, coalesced)",
+		},
+		[]string{"metric", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+}
+
+// CachedMetricSource could be implemented by metric source whose values could be
+// reused by identical requests in a short period to avoid throttling of cloud OpenAPI.
+type CachedMetricSource interface {
+	CacheTTL() time.Duration
+}
+
+type cacheItem struct {
+	values   []external_metrics.ExternalMetricValue
+	expireAt time.Time
+}
+
+// metricsCache caches the values of external metrics and coalesces the concurrent identical requests.
+type metricsCache struct {
+	lock  sync.RWMutex
+	items map[string]*cacheItem
+	group singleflight.Group
+	now   func() time.Time
+}
+
+func newMetricsCache() *metricsCache {
+	return &metricsCache{
+		items: make(map[string]*cacheItem),
+		now:   time.Now,
+	}
+}
+
+// get the values from cache or load them once for all concurrent identical requests.
+// values are only cached when ttl is positive and no error returned.
+func (mc *metricsCache) getOrLoad(key, metric string, ttl time.Duration, load func() ([]external_metrics.ExternalMetricValue, error)) ([]external_metrics.ExternalMetricValue, error) {
+	if values, ok := mc.get(key); ok {
+		cacheRequests.WithLabelValues(metric, cacheResultHit).Inc()
+		return values, nil
+	}
+
+	loaded := false
+	v, err, _ := mc.group.Do(key, func() (interface{}, error) {
+		loaded = true
+		values, err := load()
+		if err == nil && ttl > 0 {
+			mc.set(key, values, ttl)
+		}
+		return values, err
+	})
+	if loaded {
+		cacheRequests.WithLabelValues(metric, cacheResultMiss).Inc()
+	} else {
+		cacheRequests.WithLabelValues(metric, cacheResultCoalesced).Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return copyValues(v.([]external_metrics.ExternalMetricValue)), nil
+}
+
+func (mc *metricsCache) get(key string) ([]external_metrics.ExternalMetricValue, bool) {
+	mc.lock.RLock()
+	defer mc.lock.RUnlock()
+	item, ok := mc.items[key]
+	if !ok || mc.now().After(item.expireAt) {
+		return nil, false
+	}
+	return copyValues(item.values), true
+}
+
+func (mc *metricsCache) set(key string, values []external_metrics.ExternalMetricValue, ttl time.Duration) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	now := mc.now()
+	// drop expired items to keep the cache small
+	for k, item := range mc.items {
+		if now.After(item.expireAt) {
+			delete(mc.items, k)
+		}
+	}
+	mc.items[key] = &cacheItem{
+		values:   copyValues(values),
+		expireAt: now.Add(ttl),
+	}
+}
+
+// values are shared by requests, make sure that callers never modify the cached ones.
+func copyValues(values []external_metrics.ExternalMetricValue) []external_metrics.ExternalMetricValue {
+	if values == nil {
+		return nil
+	}
+	out := make([]external_metrics.ExternalMetricValue, 0, len(values))
+	for _, v := range values {
+		out = append(out, *v.DeepCopy())
+	}
+	return out
+}
+
+// cacheKey is made up of metric name, namespace and the normalized label requirements,
+// so the order of requirements and values doesn't matter.
+func cacheKey(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) string {
+	reqs := make([]string, 0, len(requirements))
+	for _, r := range requirements {
+		reqs = append(reqs, r.Key()+string(r.Operator())+strings.Join(r.Values().List(), ","))
+	}
+	sort.Strings(reqs)
+	return info.Metric + "/" + namespace + "/" + strings.Join(reqs, ";")
+}
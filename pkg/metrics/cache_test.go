@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+func TestCacheKeyNormalized(t *testing.T) {
+	info := p.ExternalMetricInfo{Metric: "slb_l7_qps"}
+	k1 := cacheKey(info, "default", testutil.ParseRequirements(t, "slb.instance.id=lb-1,slb.instance.port=80,a in (y,x)"))
+	k2 := cacheKey(info, "default", testutil.ParseRequirements(t, "a in (x,y),slb.instance.port=80,slb.instance.id=lb-1"))
+	if k1 != k2 {
+		t.Fatalf("cache key should be normalized, got %s and %s", k1, k2)
+	}
+	k3 := cacheKey(info, "kube-system", testutil.ParseRequirements(t, "slb.instance.id=lb-1,slb.instance.port=80,a in (y,x)"))
+	if k1 == k3 {
+		t.Fatalf("cache key should contain namespace")
+	}
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	mc := newMetricsCache()
+	now := time.Now()
+	mc.now = func() time.Time { return now }
+
+	var calls int32
+	load := func() ([]external_metrics.ExternalMetricValue, error) {
+		atomic.AddInt32(&calls, 1)
+		return []external_metrics.ExternalMetricValue{{MetricName: "m", Value: *resource.NewQuantity(1, resource.DecimalSI)}}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		values, err := mc.getOrLoad("key", "m", time.Minute, load)
+		if err != nil || len(values) != 1 {
+			t.Fatalf("unexpected result: %v, %v", values, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected values to be cached, loaded %d times", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := mc.getOrLoad("key", "m", time.Minute, load); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected values to be expired, loaded %d times", calls)
+	}
+}
+
+func TestCacheSkipError(t *testing.T) {
+	mc := newMetricsCache()
+	var calls int32
+	load := func() ([]external_metrics.ExternalMetricValue, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("throttling")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := mc.getOrLoad("key", "m", time.Minute, load); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("errors should not be cached, loaded %d times", calls)
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	mc := newMetricsCache()
+	var calls int32
+	release := make(chan struct{})
+	load := func() ([]external_metrics.ExternalMetricValue, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []external_metrics.ExternalMetricValue{{MetricName: "m"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := mc.getOrLoad("key", "m", time.Minute, load); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected concurrent requests to be coalesced, loaded %d times", calls)
+	}
+}
@@ -0,0 +1,184 @@
+package cloudmonitor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+// CloudMonitorMetricSource serves the external metrics defined by CloudMonitorMetric.
+type CloudMonitorMetricSource struct {
+	clients utils.ClientCache
+
+	lock sync.RWMutex
+	// external metric name -> definition
+	metrics map[string]*CloudMonitorMetric
+	// CloudMonitorMetric name -> external metric name
+	names    map[string]string
+	onChange func()
+}
+
+func NewCloudMonitorMetricSource() *CloudMonitorMetricSource {
+	return &CloudMonitorMetricSource{
+		metrics: make(map[string]*CloudMonitorMetric),
+		names:   make(map[string]string),
+	}
+}
+
+func (cs *CloudMonitorMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	for m := range cs.metrics {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: m,
+		})
+	}
+	return metricInfoList
+}
+
+func (cs *CloudMonitorMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	cs.lock.RLock()
+	metric, ok := cs.metrics[info.Metric]
+	cs.lock.RUnlock()
+	if !ok {
+		return values, fmt.Errorf("CloudMonitorMetric of %s is not found", info.Metric)
+	}
+
+	values, err = cs.getCloudMonitorMetrics(namespace, metric, requirements)
+	if err != nil {
+		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
+	}
+	return values, err
+}
+
+// the CloudMonitorMetric is queried by its period of at least 60s, so the responses are reused in cmsutil.CACHE_TTL
+func (cs *CloudMonitorMetricSource) CacheTTL() time.Duration {
+	return cmsutil.CACHE_TTL
+}
+
+func (cs *CloudMonitorMetricSource) SetMetricsChangedHandler(handler func()) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.onChange = handler
+}
+
+// add or update the definition of external metric
+func (cs *CloudMonitorMetricSource) setMetric(metric *CloudMonitorMetric) error {
+	if err := metric.Default(); err != nil {
+		return err
+	}
+	name := metric.ExternalMetricName()
+
+	cs.lock.Lock()
+	if m, ok := cs.metrics[name]; ok && m.Name != metric.Name {
+		cs.lock.Unlock()
+		return fmt.Errorf("metric %s is already defined by CloudMonitorMetric %s", name, m.Name)
+	}
+	// metric name of the CloudMonitorMetric may be changed
+	if old, ok := cs.names[metric.Name]; ok && old != name {
+		delete(cs.metrics, old)
+	}
+	cs.metrics[name] = metric
+	cs.names[metric.Name] = name
+	onChange := cs.onChange
+	cs.lock.Unlock()
+
+	if onChange != nil {
+		onChange()
+	}
+	return nil
+}
+
+// remove the external metric defined by CloudMonitorMetric
+func (cs *CloudMonitorMetricSource) removeMetric(name string) {
+	cs.lock.Lock()
+	if m, ok := cs.names[name]; ok {
+		delete(cs.metrics, m)
+		delete(cs.names, name)
+	}
+	onChange := cs.onChange
+	cs.lock.Unlock()
+
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// map the labels of metric selector to cms dimensions
+func getDimensions(metric *CloudMonitorMetric, requirements labels.Requirements) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, r := range requirements {
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+		values[r.Key()] = r.Values().List()[0]
+	}
+
+	dimensions := make(map[string]string)
+	for _, d := range metric.Spec.Dimensions {
+		value, ok := values[d.Label]
+		if !ok {
+			if d.Required {
+				return nil, fmt.Errorf("%s must be provided", d.Label)
+			}
+			continue
+		}
+		dimensions[d.Name] = value
+	}
+	return dimensions, nil
+}
+
+func (cs *CloudMonitorMetricSource) getCloudMonitorMetrics(namespace string, metric *CloudMonitorMetric, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	dimensions, err := getDimensions(metric, requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get dimensions,because of %v", err)
+	}
+
+	client, err := cmsutil.NewClient(&cs.clients, utils.GetAccessOptions(namespace, requirements))
+	if err != nil {
+		return values, err
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-5 * time.Duration(metric.Spec.Period) * time.Second)
+	points, err := cmsutil.DescribeMetricList(client, &cmsutil.MetricListParams{
+		Namespace:  metric.Spec.Namespace,
+		MetricName: metric.Spec.CMSMetricName,
+		Dimensions: dimensions,
+		Period:     metric.Spec.Period,
+		StartTime:  startTime,
+		EndTime:    endTime,
+	})
+	if err != nil {
+		return values, err
+	}
+	if len(points) == 0 {
+		return values, errors.New("NoMetricData")
+	}
+
+	statistic, err := cmsutil.GetStatistic(points[len(points)-1], metric.Spec.Statistic)
+	if err != nil {
+		return values, err
+	}
+	value, err := utils.ConvertValue(statistic, utils.Unit{})
+	if err != nil {
+		return values, err
+	}
+
+	values = append(values, external_metrics.ExternalMetricValue{
+		MetricName: metric.ExternalMetricName(),
+		Value:      *value,
+		Timestamp:  metav1.Now(),
+	})
+	return values, nil
+}
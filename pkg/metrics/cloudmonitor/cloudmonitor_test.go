@@ -0,0 +1,83 @@
+package cloudmonitor
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func newCloudMonitorMetric(name, metricName string) *CloudMonitorMetric {
+	return &CloudMonitorMetric{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: CloudMonitorMetricSpec{
+			MetricName:    metricName,
+			Namespace:     "acs_rds_dashboard",
+			CMSMetricName: "CpuUsage",
+			Dimensions: []DimensionMapping{
+				{Name: "instanceId", Label: "rds.instance.id", Required: true},
+				{Name: "nodeId", Label: "rds.node.id"},
+			},
+		},
+	}
+}
+
+func TestDefault(t *testing.T) {
+	m := newCloudMonitorMetric("rds-cpu-usage", "")
+	if err := m.Default(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Spec.Statistic != "Average" || m.Spec.Period != MIN_PERIOD || m.ExternalMetricName() != "rds-cpu-usage" {
+		t.Fatalf("unexpected defaults: %+v", m.Spec)
+	}
+
+	m.Spec.Statistic = "p99"
+	if err := m.Default(); err == nil {
+		t.Fatalf("expected error for unsupported statistic")
+	}
+}
+
+func TestGetDimensions(t *testing.T) {
+	m := newCloudMonitorMetric("rds-cpu-usage", "rds_cpu_usage")
+	selector, _ := labels.Parse("rds.instance.id=rm-1,other=x")
+	r, _ := selector.Requirements()
+	dimensions, err := getDimensions(m, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dimensions) != 1 || dimensions["instanceId"] != "rm-1" {
+		t.Fatalf("unexpected dimensions: %v", dimensions)
+	}
+
+	selector, _ = labels.Parse("rds.node.id=n-1")
+	r, _ = selector.Requirements()
+	if _, err := getDimensions(m, r); err == nil {
+		t.Fatalf("expected error when required label is missing")
+	}
+}
+
+func TestSetAndRemoveMetric(t *testing.T) {
+	cs := NewCloudMonitorMetricSource()
+	changed := 0
+	cs.SetMetricsChangedHandler(func() { changed++ })
+
+	if err := cs.setMetric(newCloudMonitorMetric("a", "rds_cpu_usage")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cs.setMetric(newCloudMonitorMetric("b", "rds_cpu_usage")); err == nil {
+		t.Fatalf("expected error for duplicated metric name")
+	}
+	// rename the metric
+	if err := cs.setMetric(newCloudMonitorMetric("a", "rds_cpu")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list := cs.GetExternalMetricInfoList()
+	if len(list) != 1 || list[0].Metric != "rds_cpu" {
+		t.Fatalf("unexpected metric list: %v", list)
+	}
+
+	cs.removeMetric("a")
+	if len(cs.GetExternalMetricInfoList()) != 0 || changed != 3 {
+		t.Fatalf("unexpected metric list after removing, changed %d times", changed)
+	}
+}
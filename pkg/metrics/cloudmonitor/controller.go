@@ -0,0 +1,62 @@
+package cloudmonitor
+
+import (
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	log "k8s.io/klog/v2"
+)
+
+const resyncPeriod = 10 * time.Minute
+
+// RunUntil watches CloudMonitorMetric and registers the metrics dynamically,
+// the watching starts once the CRD is installed.
+func (cs *CloudMonitorMetricSource) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	utils.RunWhenResourceAvailable(kubeClient, CloudMonitorMetricResource, stopCh, func() {
+		factory := dynamicinformer.NewDynamicSharedInformerFactory(kubeClient, resyncPeriod)
+		informer := factory.ForResource(CloudMonitorMetricResource).Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: cs.onUpdate,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				cs.onUpdate(newObj)
+			},
+			DeleteFunc: cs.onDelete,
+		})
+		factory.Start(stopCh)
+		log.Infof("Start watching CloudMonitorMetric")
+	})
+}
+
+func (cs *CloudMonitorMetricSource) onUpdate(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	metric := &CloudMonitorMetric{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, metric); err != nil {
+		log.Errorf("Failed to convert CloudMonitorMetric %s,because of %v", u.GetName(), err)
+		return
+	}
+	if err := cs.setMetric(metric); err != nil {
+		log.Errorf("Failed to register CloudMonitorMetric %s,because of %v", metric.Name, err)
+		return
+	}
+	log.Infof("Registered CloudMonitorMetric %s as external metric %s", metric.Name, metric.ExternalMetricName())
+}
+
+func (cs *CloudMonitorMetricSource) onDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	cs.removeMetric(u.GetName())
+	log.Infof("Unregistered CloudMonitorMetric %s", u.GetName())
+}
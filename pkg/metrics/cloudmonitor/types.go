@@ -0,0 +1,88 @@
+package cloudmonitor
+
+import (
+	"errors"
+	"fmt"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GROUP   = "metrics.alibabacloud.com"
+	VERSION = "v1alpha1"
+	KIND    = "CloudMonitorMetric"
+
+	MIN_PERIOD = 60
+)
+
+// GVR of CloudMonitorMetric
+var CloudMonitorMetricResource = schema.GroupVersionResource{
+	Group:    GROUP,
+	Version:  VERSION,
+	Resource: "cloudmonitormetrics",
+}
+
+// CloudMonitorMetric exposes a CloudMonitor(CMS) metric as an external metric.
+type CloudMonitorMetric struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CloudMonitorMetricSpec `json:"spec"`
+}
+
+type CloudMonitorMetricSpec struct {
+	// name of the external metric, metadata.name is used by default.
+	MetricName string `json:"metricName,omitempty"`
+	// cms namespace, e.g. acs_rds_dashboard
+	Namespace string `json:"namespace"`
+	// cms metric name, e.g. CpuUsage
+	CMSMetricName string `json:"cmsMetricName"`
+	// map the labels of metric selector to cms dimensions
+	Dimensions []DimensionMapping `json:"dimensions,omitempty"`
+	// Average, Maximum, Minimum or Sum. Average by default.
+	Statistic string `json:"statistic,omitempty"`
+	// period of cms metric in seconds, 60 by default.
+	Period int `json:"period,omitempty"`
+}
+
+type DimensionMapping struct {
+	// dimension key of cms, e.g. instanceId
+	Name string `json:"name"`
+	// label key of the metric selector, e.g. rds.instance.id
+	Label string `json:"label"`
+	// the label must be provided by the metric selector
+	Required bool `json:"required,omitempty"`
+}
+
+// ExternalMetricName returns the name of the external metric
+func (c *CloudMonitorMetric) ExternalMetricName() string {
+	if c.Spec.MetricName != "" {
+		return c.Spec.MetricName
+	}
+	return c.Name
+}
+
+// Default fills the optional fields and validates the spec
+func (c *CloudMonitorMetric) Default() error {
+	if c.Spec.Namespace == "" || c.Spec.CMSMetricName == "" {
+		return errors.New("namespace and cmsMetricName must be provided")
+	}
+	switch c.Spec.Statistic {
+	case "":
+		c.Spec.Statistic = cmsutil.STATISTIC_AVERAGE
+	case cmsutil.STATISTIC_AVERAGE, cmsutil.STATISTIC_MAXIMUM, cmsutil.STATISTIC_MINIMUM, cmsutil.STATISTIC_SUM:
+	default:
+		return fmt.Errorf("statistic %s is not supported", c.Spec.Statistic)
+	}
+	if c.Spec.Period < MIN_PERIOD {
+		c.Spec.Period = MIN_PERIOD
+	}
+	for _, d := range c.Spec.Dimensions {
+		if d.Name == "" || d.Label == "" {
+			return errors.New("name and label of dimension must be provided")
+		}
+	}
+	return nil
+}
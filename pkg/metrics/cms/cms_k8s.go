@@ -1,10 +1,19 @@
 package cms
 
 import (
-	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+	"fmt"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/custom_metrics"
 	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
 )
 
 const (
@@ -24,25 +33,54 @@ const (
 	K8S_WORKLOAD_NETWORKRXERRORS  = "k8s_workload_network_rx_errors"
 )
 
-type CMSMetricSource struct{}
+// external metric name -> cms metric name of acs_kubernetes
+var workloadMetrics = map[string]string{
+	K8S_WORKLOAD_CPUUTIL:          "group.cpu.usage_rate",
+	K8S_WORKLOAD_CPULIMIT:         "group.cpu.limit",
+	K8S_WORKLOAD_CPUREQUEST:       "group.cpu.request",
+	K8S_WORKLOAD_MEMORYUSAGE:      "group.memory.usage",
+	K8S_WORKLOAD_MEMORYREQUEST:    "group.memory.request",
+	K8S_WORKLOAD_MEMORYLIMIT:      "group.memory.limit",
+	K8S_WORKLOAD_MEMORYWORKINGSET: "group.memory.working_set",
+	K8S_WORKLOAD_MEMORYRSS:        "group.memory.rss",
+	K8S_WORKLOAD_MEMORYCACHE:      "group.memory.cache",
+	K8S_WORKLOAD_NETWORKTXRATE:    "group.network.tx_rate",
+	K8S_WORKLOAD_NETWORKRXRATE:    "group.network.rx_rate",
+	K8S_WORKLOAD_NETWORKTXERRORS:  "group.network.tx_errors",
+	K8S_WORKLOAD_NETWORKRXERRORS:  "group.network.rx_errors",
+}
+
+// units of the raw values of cms workload metrics
+var workloadUnits = map[string]utils.Unit{
+	"group.cpu.usage_rate":     {Name: utils.UNIT_PERCENT},
+	"group.cpu.limit":          {Name: utils.UNIT_CORES},
+	"group.cpu.request":        {Name: utils.UNIT_CORES},
+	"group.memory.usage":       {Name: utils.UNIT_BYTES},
+	"group.memory.request":     {Name: utils.UNIT_BYTES},
+	"group.memory.limit":       {Name: utils.UNIT_BYTES},
+	"group.memory.working_set": {Name: utils.UNIT_BYTES},
+	"group.memory.rss":         {Name: utils.UNIT_BYTES},
+	"group.memory.cache":       {Name: utils.UNIT_BYTES},
+	"group.network.tx_rate":    {Name: utils.UNIT_BYTES_PER_SECOND},
+	"group.network.rx_rate":    {Name: utils.UNIT_BYTES_PER_SECOND},
+	"group.network.tx_errors":  {Name: utils.UNIT_COUNT},
+	"group.network.rx_errors":  {Name: utils.UNIT_COUNT},
+}
+
+// workload resources which could describe the custom metrics -> cms workload type
+var workloadResources = map[schema.GroupResource]string{
+	{Group: "apps", Resource: "deployments"}:  "Deployment",
+	{Group: "apps", Resource: "statefulsets"}: "StatefulSet",
+	{Group: "apps", Resource: "daemonsets"}:   "DaemonSet",
+}
+
+type CMSMetricSource struct {
+	clients utils.ClientCache
+}
 
 func (cs *CMSMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
 	metricInfoList := make([]p.ExternalMetricInfo, 0)
-	var metricInfo = []string{
-		K8S_WORKLOAD_CPUUTIL,
-		K8S_WORKLOAD_CPULIMIT,
-		K8S_WORKLOAD_CPUREQUEST,
-		K8S_WORKLOAD_MEMORYUSAGE,
-		K8S_WORKLOAD_MEMORYREQUEST,
-		K8S_WORKLOAD_MEMORYLIMIT,
-		K8S_WORKLOAD_MEMORYWORKINGSET,
-		K8S_WORKLOAD_MEMORYRSS,
-		K8S_WORKLOAD_MEMORYCACHE,
-		K8S_WORKLOAD_NETWORKTXRATE,
-		K8S_WORKLOAD_NETWORKRXRATE,
-		K8S_WORKLOAD_NETWORKTXERRORS,
-		K8S_WORKLOAD_NETWORKRXERRORS}
-	for _, m := range metricInfo {
+	for m := range workloadMetrics {
 		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
 			Metric: m,
 		})
@@ -51,61 +89,14 @@ func (cs *CMSMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
 }
 
 func (cs *CMSMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
-	switch info.Metric {
-	case K8S_WORKLOAD_CPUUTIL:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.cpu.usage_rate",
-		})
-	case K8S_WORKLOAD_CPULIMIT:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.cpu.limit",
-		})
-	case K8S_WORKLOAD_CPUREQUEST:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.cpu.request",
-		})
-	case K8S_WORKLOAD_MEMORYUSAGE:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.memory.usage",
-		})
-	case K8S_WORKLOAD_MEMORYREQUEST:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.memory.request",
-		})
-	case K8S_WORKLOAD_MEMORYLIMIT:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.memory.limit",
-		})
-	case K8S_WORKLOAD_MEMORYWORKINGSET:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.memory.working_set",
-		})
-	case K8S_WORKLOAD_MEMORYRSS:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.memory.rss",
-		})
-	case K8S_WORKLOAD_MEMORYCACHE:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.memory.cache",
-		})
-	case K8S_WORKLOAD_NETWORKTXRATE:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.network.tx_rate",
-		})
-	case K8S_WORKLOAD_NETWORKRXRATE:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.network.rx_rate",
-		})
-	case K8S_WORKLOAD_NETWORKTXERRORS:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.network.tx_errors",
-		})
-	case K8S_WORKLOAD_NETWORKRXERRORS:
-		values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
-			Metric: "group.network.rx_errors",
-		})
+	metric, ok := workloadMetrics[info.Metric]
+	if !ok {
+		return values, fmt.Errorf("metric %s is not supported by cms", info.Metric)
 	}
 
+	values, err = cs.getCMSWorkLoadMetrics(namespace, requirements, p.ExternalMetricInfo{
+		Metric: metric,
+	})
 	if err != nil {
 		log.Warningf("Failed to GetMetricBySelector %s,because of %v", info.Metric, err)
 	}
@@ -113,6 +104,67 @@ func (cs *CMSMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespac
 	return values, err
 }
 
+// the workload metrics are aggregated per minute, so the HPAs of a workload share one query in CACHE_TTL
+func (cs *CMSMetricSource) CacheTTL() time.Duration {
+	return CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (cs *CMSMetricSource) MetricUnits() map[string]utils.Unit {
+	return workloadUnits
+}
+
+// list all workload metrics which could be described by the workload object
+func (cs *CMSMetricSource) GetCustomMetricInfoList() []p.CustomMetricInfo {
+	metricInfoList := make([]p.CustomMetricInfo, 0)
+	for gr := range workloadResources {
+		for m := range workloadMetrics {
+			metricInfoList = append(metricInfoList, p.CustomMetricInfo{
+				GroupResource: gr,
+				Namespaced:    true,
+				Metric:        m,
+			})
+		}
+	}
+	return metricInfoList
+}
+
+// get the workload metric of the specific object. k8s.cluster.id could be omitted when ClusterId env is provided.
+func (cs *CMSMetricSource) GetCustomMetric(info p.CustomMetricInfo, name types.NamespacedName, metricSelector labels.Selector) (*custom_metrics.MetricValue, error) {
+	metric, ok := workloadMetrics[info.Metric]
+	if !ok {
+		return nil, fmt.Errorf("metric %s is not supported by cms", info.Metric)
+	}
+
+	workloadType, ok := workloadResources[info.GroupResource]
+	if !ok {
+		return nil, fmt.Errorf("resource %s is not supported by cms", info.GroupResource.String())
+	}
+
+	requirements, _ := metricSelector.Requirements()
+	params, err := getCMSObjectParams(name, workloadType, requirements)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get CMS params, because of %v", err)
+	}
+
+	value, err := cs.getCMSWorkloadValue(params, metric)
+	if err != nil {
+		log.Warningf("Failed to GetMetricByName %s of %s %s,because of %v", info.Metric, workloadType, name.String(), err)
+		return nil, err
+	}
+	if value == nil {
+		return nil, p.NewMetricNotFoundForError(info.GroupResource, info.Metric, name.Name)
+	}
+
+	return &custom_metrics.MetricValue{
+		Metric: custom_metrics.MetricIdentifier{
+			Name: info.Metric,
+		},
+		Timestamp: metav1.Now(),
+		Value:     *value,
+	}, nil
+}
+
 // register cms metric source to provider
 func NewCMSMetricSource() *CMSMetricSource {
 	return &CMSMetricSource{}
@@ -0,0 +1,226 @@
+package cms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
+	log "k8s.io/klog/v2"
+)
+
+// statistics of cms datapoints
+const (
+	STATISTIC_AVERAGE = "Average"
+	STATISTIC_MAXIMUM = "Maximum"
+	STATISTIC_MINIMUM = "Minimum"
+	STATISTIC_SUM     = "Sum"
+	STATISTIC_VALUE   = "Value"
+)
+
+// aggregations of the statistic over the datapoints in the period
+const (
+	// the statistic of the latest datapoint
+	AGGREGATION_LATEST = "latest"
+	AGGREGATION_MEAN   = "mean"
+	AGGREGATION_MAX    = "max"
+	AGGREGATION_MIN    = "min"
+	AGGREGATION_SUM    = "sum"
+)
+
+// the datapoints of cms are aggregated by period(at least 60s),
+// so identical requests of the cms backed sources share the response in CACHE_TTL
+const CACHE_TTL = 30 * time.Second
+
+// the datapoints of the latest minutes are not complete yet, so the window of query ends METRIC_DELAY ago
+const METRIC_DELAY = 2 * time.Minute
+
+var statistics = []string{STATISTIC_AVERAGE, STATISTIC_MAXIMUM, STATISTIC_MINIMUM, STATISTIC_SUM, STATISTIC_VALUE}
+
+var aggregations = []string{AGGREGATION_LATEST, AGGREGATION_MEAN, AGGREGATION_MAX, AGGREGATION_MIN, AGGREGATION_SUM}
+
+// params of DescribeMetricList
+type MetricListParams struct {
+	Namespace  string
+	MetricName string
+	Dimensions map[string]string
+	Period     int
+	StartTime  time.Time
+	EndTime    time.Time
+}
+
+// NewMetricListParams returns the params to describe the datapoints of MIN_PERIOD in the latest period seconds,
+// which are aggregated by the statistic and aggregation of request.
+func NewMetricListParams(namespace, metricName string, dimensions map[string]string, period int) *MetricListParams {
+	endTime := time.Now().Add(-METRIC_DELAY)
+	return &MetricListParams{
+		Namespace:  namespace,
+		MetricName: metricName,
+		Dimensions: dimensions,
+		Period:     MIN_PERIOD,
+		StartTime:  endTime.Add(-1 * time.Duration(period) * time.Second),
+		EndTime:    endTime,
+	}
+}
+
+// NewClient returns the cms client of the region and profile shared by the requests of a metric source.
+func NewClient(clients *utils.ClientCache, options utils.AccessOptions) (client *cms.Client, err error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(options)
+	if err != nil {
+		log.Errorf("Failed to create cms client,because of %v", err)
+		return nil, err
+	}
+
+	c, err := clients.Get(accessUserInfo.Region, accessUserInfo, func() (interface{}, error) {
+		if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
+			return cms.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
+		}
+		return cms.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*cms.Client), nil
+}
+
+// DescribeMetricList returns the datapoints of cms metric. The keys of datapoint differ from cms namespaces,
+// use GetStatistic to read the values.
+func DescribeMetricList(client *cms.Client, params *MetricListParams) (points []map[string]interface{}, err error) {
+	request := cms.CreateDescribeMetricListRequest()
+	request.Scheme = "https"
+	request.Namespace = params.Namespace
+	request.MetricName = params.MetricName
+	if params.Period > 0 {
+		request.Period = strconv.Itoa(params.Period)
+	}
+	request.StartTime = params.StartTime.Format(utils.DEFAULT_TIME_FORMAT)
+	request.EndTime = params.EndTime.Format(utils.DEFAULT_TIME_FORMAT)
+
+	if len(params.Dimensions) > 0 {
+		dimensions, err := json.Marshal([]map[string]string{params.Dimensions})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal dimensions,because of %v", err)
+		}
+		request.Dimensions = string(dimensions)
+	}
+
+	response, err := client.DescribeMetricList(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe metric list of %s/%s,because of %v", params.Namespace, params.MetricName, err)
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("failed to describe metric list of %s/%s, code: %s, message: %s", params.Namespace, params.MetricName, response.Code, response.Message)
+	}
+
+	return ParseDatapoints(response.Datapoints)
+}
+
+// ParseDatapoints parses the datapoints json of DescribeMetricList response.
+func ParseDatapoints(datapoints string) (points []map[string]interface{}, err error) {
+	points = make([]map[string]interface{}, 0)
+	if datapoints == "" {
+		return points, nil
+	}
+	if err := json.Unmarshal([]byte(datapoints), &points); err != nil {
+		return nil, fmt.Errorf("json unmarshal datapoint exception %v", err)
+	}
+	return points, nil
+}
+
+// GetStatistic returns the statistic of the datapoint, the key is case-insensitive
+// because some namespaces use Average while the others use average.
+func GetStatistic(point map[string]interface{}, statistic string) (float64, error) {
+	for k, v := range point {
+		if !strings.EqualFold(k, statistic) {
+			continue
+		}
+		switch value := v.(type) {
+		case float64:
+			return value, nil
+		case string:
+			return strconv.ParseFloat(value, 64)
+		default:
+			return 0, fmt.Errorf("invalid value of statistic %s: %v", statistic, v)
+		}
+	}
+	return 0, fmt.Errorf("statistic %s is not found in datapoint", statistic)
+}
+
+// ParsePeriod returns the period in seconds to aggregate the datapoints, MIN_PERIOD is used
+// if the period is invalid or lower than it.
+func ParsePeriod(period string) int {
+	value, err := strconv.Atoi(period)
+	if err != nil {
+		log.Errorf("Failed to parse period and use MIN_PERIOD(%d) as default,because of %v", MIN_PERIOD, err)
+		return MIN_PERIOD
+	}
+	if value < MIN_PERIOD {
+		log.Warningf("The period you specific is too low and use MIN_PERIOD(%d) as default", MIN_PERIOD)
+		return MIN_PERIOD
+	}
+	return value
+}
+
+// ParseStatistic returns the canonical name of the statistic, the name is case-insensitive.
+func ParseStatistic(statistic string) (string, error) {
+	for _, s := range statistics {
+		if strings.EqualFold(s, statistic) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("statistic %s is not supported, valid statistics are %v", statistic, statistics)
+}
+
+// ParseAggregation returns the canonical name of the aggregation, the name is case-insensitive.
+func ParseAggregation(aggregation string) (string, error) {
+	for _, a := range aggregations {
+		if strings.EqualFold(a, aggregation) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("aggregation %s is not supported, valid aggregations are %v", aggregation, aggregations)
+}
+
+// Aggregate returns the statistic of the latest datapoint or aggregates the statistic
+// of all datapoints in the period. The datapoints are ordered by timestamp.
+func Aggregate(points []map[string]interface{}, statistic, aggregation string) (float64, error) {
+	if len(points) == 0 {
+		return 0, errors.New("NoMetricData")
+	}
+	switch aggregation {
+	case "", AGGREGATION_LATEST:
+		return GetStatistic(points[len(points)-1], statistic)
+	case AGGREGATION_MEAN, AGGREGATION_MAX, AGGREGATION_MIN, AGGREGATION_SUM:
+	default:
+		return 0, fmt.Errorf("aggregation %s is not supported", aggregation)
+	}
+
+	var result float64
+	for i, point := range points {
+		value, err := GetStatistic(point, statistic)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			result = value
+			continue
+		}
+		switch aggregation {
+		case AGGREGATION_MAX:
+			result = math.Max(result, value)
+		case AGGREGATION_MIN:
+			result = math.Min(result, value)
+		case AGGREGATION_MEAN, AGGREGATION_SUM:
+			result += value
+		}
+	}
+	if aggregation == AGGREGATION_MEAN {
+		result = result / float64(len(points))
+	}
+	return result, nil
+}
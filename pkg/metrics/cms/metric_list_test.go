@@ -0,0 +1,82 @@
+package cms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetStatistic(t *testing.T) {
+	points, err := ParseDatapoints(`[{"timestamp":1,"Average":1.5,"Maximum":3},{"timestamp":2,"average":"2.5","Sum":10}]`)
+	if err != nil || len(points) != 2 {
+		t.Fatalf("failed to parse datapoints: %v, %v", points, err)
+	}
+
+	if v, err := GetStatistic(points[0], STATISTIC_AVERAGE); err != nil || v != 1.5 {
+		t.Fatalf("unexpected average: %v, %v", v, err)
+	}
+	if v, err := GetStatistic(points[1], STATISTIC_AVERAGE); err != nil || v != 2.5 {
+		t.Fatalf("unexpected average of lower case key: %v, %v", v, err)
+	}
+	if _, err := GetStatistic(points[1], STATISTIC_MINIMUM); err == nil {
+		t.Fatalf("expected error for missing statistic")
+	}
+}
+
+func TestParseEmptyDatapoints(t *testing.T) {
+	points, err := ParseDatapoints("")
+	if err != nil || len(points) != 0 {
+		t.Fatalf("unexpected datapoints: %v, %v", points, err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	points, err := ParseDatapoints(`[{"Average":1,"Maximum":4},{"Average":3,"Maximum":2}]`)
+	if err != nil {
+		t.Fatalf("failed to parse datapoints: %v", err)
+	}
+
+	cases := []struct {
+		statistic   string
+		aggregation string
+		expected    float64
+	}{
+		{STATISTIC_AVERAGE, AGGREGATION_LATEST, 3},
+		{STATISTIC_AVERAGE, AGGREGATION_MEAN, 2},
+		{STATISTIC_MAXIMUM, AGGREGATION_MAX, 4},
+		{STATISTIC_MAXIMUM, AGGREGATION_MIN, 2},
+		{STATISTIC_AVERAGE, AGGREGATION_SUM, 4},
+	}
+	for _, c := range cases {
+		if v, err := Aggregate(points, c.statistic, c.aggregation); err != nil || v != c.expected {
+			t.Fatalf("unexpected %s of %s: %v, %v", c.aggregation, c.statistic, v, err)
+		}
+	}
+
+	if _, err := Aggregate(points, STATISTIC_AVERAGE, "p99"); err == nil {
+		t.Fatalf("expected error for unsupported aggregation")
+	}
+	if _, err := Aggregate(nil, STATISTIC_AVERAGE, AGGREGATION_LATEST); err == nil {
+		t.Fatalf("expected error for empty datapoints")
+	}
+	if _, err := ParseStatistic("p-latest"); err == nil {
+		t.Fatalf("expected error for unsupported statistic")
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for period, expected := range map[string]int{"300": 300, "30": MIN_PERIOD, "5m": MIN_PERIOD} {
+		if p := ParsePeriod(period); p != expected {
+			t.Fatalf("expected period %d of %s, got %d", expected, period, p)
+		}
+	}
+}
+
+func TestNewMetricListParams(t *testing.T) {
+	params := NewMetricListParams("acs_kafka", "message_accumulation", map[string]string{"instanceId": "i-1"}, 300)
+	if params.Period != MIN_PERIOD || params.EndTime.Sub(params.StartTime) != 300*time.Second {
+		t.Fatalf("unexpected window of params: %+v", params)
+	}
+	if delay := time.Since(params.EndTime); delay < METRIC_DELAY {
+		t.Fatalf("expected the window to end %v ago, got %v", METRIC_DELAY, delay)
+	}
+}
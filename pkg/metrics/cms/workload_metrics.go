@@ -1,11 +1,9 @@
 package cms
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
@@ -15,6 +13,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	log "k8s.io/klog/v2"
 	"k8s.io/metrics/pkg/apis/external_metrics"
 )
@@ -30,23 +30,15 @@ const (
 	K8S_WORKLOAD_NAME = "k8s.workload.name"
 	K8S_CLUSTER_ID    = "k8s.cluster.id"
 	K8S_PERIOD        = "k8s.period"
+	K8S_STATISTIC     = "k8s.statistic"
+	K8S_AGGREGATION   = "k8s.aggregation"
 	//
 	MIN_PERIOD = 60
 )
 
-type DataPoint struct {
-	Timestamp int64   `json:"timestamp"`
-	UserId    string  `json:"userId"`
-	GroupId   string  `json:"groupId"`
-	Value     float64 `json:"value"`
-	Sum       float64 `json:"Sum"`
-	Average   float64 `json:"average"`
-	Maximum   float64 `json:"maximum"`
-	Minimum   float64 `json:"minimum"`
-}
-
 type CMSMetricParams struct {
 	CMSGlobalParams
+	utils.AccessOptions
 	Namespace    string
 	ClusterId    string
 	WorkloadType string
@@ -55,6 +47,10 @@ type CMSMetricParams struct {
 
 type CMSGlobalParams struct {
 	Period int
+	// the statistic of datapoint, Sum is used as default
+	Statistic string
+	// the aggregation of the statistic over the period, the latest datapoint is used as default
+	Aggregation string
 }
 
 // get cms workload metrics
@@ -67,32 +63,73 @@ func (cs *CMSMetricSource) getCMSWorkLoadMetrics(namespace string, requires labe
 		return values, fmt.Errorf("Failed to get CMS params, because of %v", err)
 	}
 
+	value, err := cs.getCMSWorkloadValue(params, info.Metric)
+	if err != nil || value == nil {
+		return values, err
+	}
+
+	values = append(values, external_metrics.ExternalMetricValue{
+		MetricName: info.Metric,
+		Timestamp:  metav1.Now(),
+		Value:      *value,
+	})
+	return values, nil
+}
+
+// get the value of cms workload metric, nil is returned when there is no data of the workload.
+func (cs *CMSMetricSource) getCMSWorkloadValue(params *CMSMetricParams, metricName string) (value *resource.Quantity, err error) {
 	// get cluster id from group
 	groupId, err := cs.getGroupIdByName(params)
 
 	if err != nil || groupId <= 0 {
-		return values, err
+		return nil, err
 	}
 
-	dataPoints, err := cs.getMetricListByGroupId(params, groupId, info.Metric)
+	dataPoints, err := cs.getMetricListByGroupId(params, groupId, metricName)
 	if err != nil {
-		return values, err
+		return nil, err
+	}
+
+	if len(dataPoints) == 0 {
+		return nil, nil
+	}
+	metricValue, err := Aggregate(dataPoints, params.Statistic, params.Aggregation)
+	if err != nil {
+		return nil, err
 	}
+	return utils.ConvertValue(metricValue, workloadUnits[metricName])
+}
+
+// get cms params of the workload object which describes the custom metric
+func getCMSObjectParams(name types.NamespacedName, workloadType string, requirements labels.Requirements) (params *CMSMetricParams, err error) {
+	return getCMSParams(name.Namespace, append(requirements, workloadRequirements(workloadType, name.Name)...))
+}
 
-	if len(dataPoints) > 0 {
-		values = append(values, external_metrics.ExternalMetricValue{
-			MetricName: info.Metric,
-			Timestamp:  metav1.Now(),
-			Value:      *resource.NewQuantity(int64(dataPoints[len(dataPoints)-1].Sum), resource.DecimalSI),
-		})
+// the workload type and name is determined by the described object and can't be overridden by metric selector
+func workloadRequirements(workloadType, workloadName string) labels.Requirements {
+	requirements := make(labels.Requirements, 0)
+	if r, err := labels.NewRequirement(K8S_WORKLOAD_TYPE, selection.Equals, []string{workloadType}); err == nil {
+		requirements = append(requirements, *r)
+	}
+	if r, err := labels.NewRequirement(K8S_WORKLOAD_NAME, selection.Equals, []string{workloadName}); err == nil {
+		requirements = append(requirements, *r)
 	}
-	return values, err
+	return requirements
 }
 
 func getCMSParams(namespace string, requirements labels.Requirements) (params *CMSMetricParams, err error) {
 	params = &CMSMetricParams{
-		Namespace:    namespace,
-		WorkloadType: K8S_DEFAULT_WORKLOAD_TYPE,
+		CMSGlobalParams: CMSGlobalParams{
+			Statistic:   STATISTIC_SUM,
+			Aggregation: AGGREGATION_LATEST,
+		},
+		AccessOptions: utils.GetAccessOptions(namespace, requirements),
+		Namespace:     namespace,
+		WorkloadType:  K8S_DEFAULT_WORKLOAD_TYPE,
+	}
+	// cluster id of the adapter is used as default
+	if clusterId, err := utils.GetClusterIdFromEnv(); err == nil {
+		params.ClusterId = clusterId
 	}
 	for _, r := range requirements {
 
@@ -109,6 +146,14 @@ func getCMSParams(namespace string, requirements labels.Requirements) (params *C
 				log.Warningf("Failed to parse period and use MIN_PERIOD(%d) as default", MIN_PERIOD)
 				continue
 			}
+		case K8S_STATISTIC:
+			if params.Statistic, err = ParseStatistic(value); err != nil {
+				return params, err
+			}
+		case K8S_AGGREGATION:
+			if params.Aggregation, err = ParseAggregation(value); err != nil {
+				return params, err
+			}
 		case K8S_CLUSTER_ID:
 			params.ClusterId = value
 		case K8S_NAMESPACE:
@@ -144,7 +189,7 @@ func (cs *CMSMetricSource) getGroupIdByName(params *CMSMetricParams) (groupId in
 	request.GroupName = groupName
 	request.SelectContactGroups = requests.NewBoolean(false)
 
-	client, err := cs.Client()
+	client, err := cs.Client(params.AccessOptions)
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to create cms client,because of %v", err)
@@ -164,67 +209,24 @@ func (cs *CMSMetricSource) getGroupIdByName(params *CMSMetricParams) (groupId in
 	return 0, err
 }
 
-func (cs *CMSMetricSource) getMetricListByGroupId(params *CMSMetricParams, groupId int64, metricName string) (values []DataPoint, err error) {
-	request := cms.CreateDescribeMetricListRequest()
-	request.Scheme = "https"
-
-	// cms namespace not k8s namespace
-	request.Namespace = DEFAULT_ACS_KUBERNETES
-	request.MetricName = metricName
-
-	// create dimensions
-	dimensions := fmt.Sprintf("[{\"groupId\":\"%d\"}]", groupId)
-	request.Dimensions = dimensions
-
-	// time range
-	startTime := time.Now().Add(-5 * time.Duration(params.Period) * time.Second).Format(utils.DEFAULT_TIME_FORMAT)
-	endTime := time.Now().Format(utils.DEFAULT_TIME_FORMAT)
-
-	request.StartTime = startTime
-	request.EndTime = endTime
-
-	client, err := cs.Client()
-
+func (cs *CMSMetricSource) getMetricListByGroupId(params *CMSMetricParams, groupId int64, metricName string) (values []map[string]interface{}, err error) {
+	client, err := cs.Client(params.AccessOptions)
 	if err != nil {
 		log.Errorf("Failed to create cms client,because of %v", err)
 		return
 	}
 
-	response, err := client.DescribeMetricList(request)
-
-	if err != nil {
-		log.Errorf("Failed to describe metric list,because of %v", err)
-		return
-	}
-	if response.Success {
-		dataPoint := response.Datapoints
-		if dataPoint == "[]" {
-			return values, fmt.Errorf("datapoint is empty %v", err)
-		}
-
-		var res []DataPoint
-
-		err := json.Unmarshal([]byte(dataPoint), &res)
-		if err != nil {
-			return values, fmt.Errorf("json unmarshal datapoint exception %v", err)
-		}
-		return res, nil
-	}
-	return values, err
+	// cms namespace not k8s namespace
+	endTime := time.Now()
+	return DescribeMetricList(client, &MetricListParams{
+		Namespace:  DEFAULT_ACS_KUBERNETES,
+		MetricName: metricName,
+		Dimensions: map[string]string{"groupId": strconv.FormatInt(groupId, 10)},
+		StartTime:  endTime.Add(-5 * time.Duration(params.Period) * time.Second),
+		EndTime:    endTime,
+	})
 }
 
-func (cs *CMSMetricSource) Client() (client *cms.Client, err error) {
-	accessUserInfo, err := utils.GetAccessUserInfo()
-	if err != nil {
-		log.Errorf("Failed to create cms client,because of %v", err)
-		return nil, err
-	}
-
-	if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
-		client, err = cms.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
-	} else {
-		client, err = cms.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
-
-	}
-	return client, err
+func (cs *CMSMetricSource) Client(options utils.AccessOptions) (client *cms.Client, err error) {
+	return NewClient(&cs.clients, options)
 }
@@ -0,0 +1,48 @@
+package cms
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestInvalidGetCMSParams(t *testing.T) {
+	r := make([]labels.Requirement, 0)
+	_, e := getCMSParams("default", r)
+	if e != nil {
+		t.Log("pass TestInvalidGetCMSParams")
+		return
+	}
+	t.Fatalf("Failed to pass TestInvalidGetCMSParams")
+}
+
+func TestGetCMSObjectParams(t *testing.T) {
+	r := make([]labels.Requirement, 0)
+	for k, v := range map[string]string{
+		K8S_CLUSTER_ID:    "c123",
+		K8S_WORKLOAD_NAME: "other",
+	} {
+		requirement, e := labels.NewRequirement(k, "=", []string{v})
+		if e != nil {
+			t.Fatalf("new requirement err: %v", e)
+		}
+		r = append(r, *requirement)
+	}
+
+	params, e := getCMSObjectParams(types.NamespacedName{Namespace: "ns", Name: "nginx"}, "StatefulSet", r)
+	if e != nil {
+		t.Fatalf("Failed to getCMSObjectParams: %v", e)
+	}
+	if params.ClusterId != "c123" || params.Namespace != "ns" || params.WorkloadType != "StatefulSet" || params.WorkloadName != "nginx" {
+		t.Fatalf("Failed to pass TestGetCMSObjectParams, got %+v", params)
+	}
+}
+
+func TestGetCustomMetricInfoList(t *testing.T) {
+	var cms CMSMetricSource
+	list := cms.GetCustomMetricInfoList()
+	if len(list) != len(workloadMetrics)*len(workloadResources) {
+		t.Fatalf("unexpected custom metric info list: %v", list)
+	}
+}
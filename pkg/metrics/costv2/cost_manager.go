@@ -481,30 +481,22 @@ func (cm *CostManager) getSingleValueMetric(metricName string, metricSelector la
 	return float64(valueList.Items[0].Value.MilliValue()) / 1000
 }
 
-func ComputeAllocationHandler(w http.ResponseWriter, r *http.Request) {
-	res := r.URL.Query()
-	paramsMap := make(map[string]string)
-	for k, v := range res {
-		paramsMap[k] = v[0]
-	}
-	klog.Infof("compute allocation params: %v", paramsMap)
-
+// ParseAllocationParams parses the params of allocation and estimated cost apis, the first value
+// of each param is used.
+func ParseAllocationParams(paramsMap map[string]string, apiType APIType) (AllocationParams, error) {
 	window, err := types.ParseWindow(paramsMap["window"])
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid 'window' parameter: %s", err), http.StatusBadRequest)
-		return
+		return AllocationParams{}, fmt.Errorf("Invalid 'window' parameter: %s", err)
 	}
-	if window.Duration() < time.Hour*24 {
-		http.Error(w, fmt.Sprintf("Invalid 'window' parameter %s: %s", paramsMap["window"], fmt.Errorf("window duration should be at least 1 day")), http.StatusBadRequest)
-		return
+	if apiType == TypeAllocation && window.Duration() < time.Hour*24 {
+		return AllocationParams{}, fmt.Errorf("Invalid 'window' parameter %s: %s", paramsMap["window"], fmt.Errorf("window duration should be at least 1 day"))
 	}
 
 	filter := &types.Filter{}
 	if filterStr, ok := paramsMap["filter"]; ok {
 		filter, err = types.ParseFilter(filterStr)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'filter' parameter %s: %s", paramsMap["filter"], err), http.StatusBadRequest)
-			return
+			return AllocationParams{}, fmt.Errorf("Invalid 'filter' parameter %s: %s", paramsMap["filter"], err)
 		}
 	}
 
@@ -512,17 +504,18 @@ func ComputeAllocationHandler(w http.ResponseWriter, r *http.Request) {
 	if stepStr, ok := paramsMap["step"]; ok {
 		step, err = util.ParseDuration(stepStr)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'step' parameter %s: %s", paramsMap["step"], err), http.StatusBadRequest)
-			return
+			return AllocationParams{}, fmt.Errorf("Invalid 'step' parameter %s: %s", paramsMap["step"], err)
 		}
-		if step < time.Hour*24 {
-			http.Error(w, fmt.Sprintf("Invalid 'step' parameter %s: %s", stepStr, fmt.Errorf("step duration should be at least 1 day")), http.StatusBadRequest)
-			return
+		if apiType == TypeAllocation && step < time.Hour*24 {
+			return AllocationParams{}, fmt.Errorf("Invalid 'step' parameter %s: %s", stepStr, fmt.Errorf("step duration should be at least 1 day"))
 		}
 	}
 
-	targetType := "cluster"
-	if targetTypeStr, ok := paramsMap["targetType"]; ok {
+	targetType := ""
+	if apiType == TypeAllocation {
+		targetType = "cluster"
+	}
+	if targetTypeStr, ok := paramsMap["targetType"]; ok && apiType == TypeAllocation {
 		targetType = targetTypeStr
 	}
 
@@ -541,12 +534,10 @@ func ComputeAllocationHandler(w http.ResponseWriter, r *http.Request) {
 	if resolutionStr, ok := paramsMap["resolution"]; ok {
 		matched, err := util.IsValidDurationString(resolutionStr)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'resolution' parameter %s: %s", paramsMap["resolution"], err), http.StatusBadRequest)
-			return
+			return AllocationParams{}, fmt.Errorf("Invalid 'resolution' parameter %s: %s", paramsMap["resolution"], err)
 		}
 		if !matched {
-			http.Error(w, fmt.Sprintf("Invalid 'resolution' parameter %s: %s", paramsMap["resolution"], fmt.Errorf("resolution should be a valid duration string")), http.StatusBadRequest)
-			return
+			return AllocationParams{}, fmt.Errorf("Invalid 'resolution' parameter %s: %s", paramsMap["resolution"], fmt.Errorf("resolution should be a valid duration string"))
 		}
 		resolution = resolutionStr
 	}
@@ -555,8 +546,7 @@ func ComputeAllocationHandler(w http.ResponseWriter, r *http.Request) {
 	if idleStr, ok := paramsMap["idle"]; ok {
 		idle, err = strconv.ParseBool(idleStr)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'idle' parameter %s: %s", paramsMap["idle"], err), http.StatusBadRequest)
-			return
+			return AllocationParams{}, fmt.Errorf("Invalid 'idle' parameter %s: %s", paramsMap["idle"], err)
 		}
 	}
 
@@ -564,8 +554,7 @@ func ComputeAllocationHandler(w http.ResponseWriter, r *http.Request) {
 	if shareIdleStr, ok := paramsMap["shareIdle"]; ok {
 		shareIdle, err = strconv.ParseBool(shareIdleStr)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'shareIdle' parameter %s: %s", paramsMap["shareIdle"], err), http.StatusBadRequest)
-			return
+			return AllocationParams{}, fmt.Errorf("Invalid 'shareIdle' parameter %s: %s", paramsMap["shareIdle"], err)
 		}
 	}
 
@@ -578,157 +567,63 @@ func ComputeAllocationHandler(w http.ResponseWriter, r *http.Request) {
 	if idleByNodeStr, ok := paramsMap["idleByNode"]; ok {
 		idleByNode, err = strconv.ParseBool(idleByNodeStr)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'idleByNode' parameter %s: %s", paramsMap["idleByNode"], err), http.StatusBadRequest)
-			return
+			return AllocationParams{}, fmt.Errorf("Invalid 'idleByNode' parameter %s: %s", paramsMap["idleByNode"], err)
 		}
 	}
 
-	cm := NewCostManager()
-	allocationParams := AllocationParams{
+	costType := types.AllocationPretaxAmount
+	if apiType == TypeCost {
+		costType = types.CostEstimated
+	}
+
+	return AllocationParams{
 		window:       window,
 		resolution:   resolution,
 		step:         step,
 		aggregate:    aggregate,
 		filter:       filter,
-		apiType:      TypeAllocation,
+		apiType:      apiType,
 		accumulateBy: AccumulateOptionNone,
-		costType:     types.AllocationPretaxAmount,
+		costType:     costType,
 		idle:         idle,
 		shareIdle:    shareIdle,
 		shareSplit:   shareSplit,
 		idleByNode:   idleByNode,
 		targetType:   targetType,
-	}
-	asr, err := cm.GetRangeAllocation(allocationParams)
-	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "bad request") {
-			WriteError(w, BadRequest(err.Error()))
-		} else {
-			WriteError(w, InternalServerError(err.Error()))
-		}
+	}, nil
+}
 
-		return
-	}
+// IsBadRequest returns true if the error of GetRangeAllocation is caused by invalid params.
+func IsBadRequest(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "bad request")
+}
 
-	format := ""
-	if formatStr, ok := paramsMap["format"]; ok {
-		format = formatStr
-	}
-	switch format {
-	case "json", "":
-		w.Header().Set("content-type", "application/json")
-		p, _ := json.Marshal(asr)
-		io.WriteString(w, string(p))
-	case "csv":
-		filename := "allocation.csv"
-		if err := writeCSVAllocationResponse(w, filename, *asr, allocationParams); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-	}
+func ComputeAllocationHandler(w http.ResponseWriter, r *http.Request) {
+	computeHandler(w, r, TypeAllocation, "allocation.csv")
 }
 
 func ComputeEstimatedCostHandler(w http.ResponseWriter, r *http.Request) {
+	computeHandler(w, r, TypeCost, "cost.csv")
+}
+
+func computeHandler(w http.ResponseWriter, r *http.Request, apiType APIType, filename string) {
 	res := r.URL.Query()
 	paramsMap := make(map[string]string)
 	for k, v := range res {
 		paramsMap[k] = v[0]
 	}
-	klog.Infof("compute estimated cost params: %v", paramsMap)
+	klog.Infof("compute %s params: %v", apiType, paramsMap)
 
-	window, err := types.ParseWindow(paramsMap["window"])
+	allocationParams, err := ParseAllocationParams(paramsMap, apiType)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid 'window' parameter: %s", err), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	filter := &types.Filter{}
-	if filterStr, ok := paramsMap["filter"]; ok {
-		filter, err = types.ParseFilter(filterStr)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'filter' parameter: %s", err), http.StatusBadRequest)
-			return
-		}
-	}
-
-	step := window.Duration()
-	if stepStr, ok := paramsMap["step"]; ok {
-		step, err = util.ParseDuration(stepStr)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'step' parameter: %s", err), http.StatusBadRequest)
-			return
-		}
-	}
-
-	// todo: parse other params
-	aggregate := "pod"
-	if aggregateStr, ok := paramsMap["aggregate"]; ok {
-		aggregate = aggregateStr
-	}
-
-	resolution := ""
-	if resolutionStr, ok := paramsMap["resolution"]; ok {
-		matched, err := util.IsValidDurationString(resolutionStr)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'resolution' parameter %s: %s", paramsMap["resolution"], err), http.StatusBadRequest)
-			return
-		}
-		if !matched {
-			http.Error(w, fmt.Sprintf("Invalid 'resolution' parameter %s: %s", paramsMap["resolution"], fmt.Errorf("resolution should be a valid duration string")), http.StatusBadRequest)
-			return
-		}
-		resolution = resolutionStr
-	}
-
-	idle := true
-	if idleStr, ok := paramsMap["idle"]; ok {
-		idle, err = strconv.ParseBool(idleStr)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'idle' parameter %s: %s", paramsMap["idle"], err), http.StatusBadRequest)
-			return
-		}
-	}
-
-	shareIdle := false
-	if shareIdleStr, ok := paramsMap["shareIdle"]; ok {
-		shareIdle, err = strconv.ParseBool(shareIdleStr)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'shareIdle' parameter %s: %s", paramsMap["shareIdle"], err), http.StatusBadRequest)
-			return
-		}
-	}
-
-	shareSplit := ShareSplitWeighted
-	if shareSplitStr, ok := paramsMap["shareSplit"]; ok {
-		shareSplit = shareSplitStr
-	}
-
-	idleByNode := false
-	if idleByNodeStr, ok := paramsMap["idleByNode"]; ok {
-		idleByNode, err = strconv.ParseBool(idleByNodeStr)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid 'idleByNode' parameter %s: %s", paramsMap["idleByNode"], err), http.StatusBadRequest)
-			return
-		}
-	}
-
 	cm := NewCostManager()
-	allocationParams := AllocationParams{
-		window:       window,
-		resolution:   resolution,
-		step:         step,
-		aggregate:    aggregate,
-		filter:       filter,
-		apiType:      TypeCost,
-		accumulateBy: AccumulateOptionNone,
-		costType:     types.CostEstimated,
-		idle:         idle,
-		shareIdle:    shareIdle,
-		shareSplit:   shareSplit,
-		idleByNode:   idleByNode,
-	}
 	asr, err := cm.GetRangeAllocation(allocationParams)
 	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "bad request") {
+		if IsBadRequest(err) {
 			WriteError(w, BadRequest(err.Error()))
 		} else {
 			WriteError(w, InternalServerError(err.Error()))
@@ -747,7 +642,6 @@ func ComputeEstimatedCostHandler(w http.ResponseWriter, r *http.Request) {
 		p, _ := json.Marshal(asr)
 		io.WriteString(w, string(p))
 	case "csv":
-		filename := "cost.csv"
 		if err := writeCSVAllocationResponse(w, filename, *asr, allocationParams); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
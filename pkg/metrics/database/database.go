@@ -0,0 +1,245 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	// the sessions and QPS of acs_rds_dashboard are only reported for RDS MySQL
+	RDS_MYSQL_ACTIVE_SESSIONS = "rds_mysql_active_sessions"
+	RDS_MYSQL_QPS             = "rds_mysql_qps"
+	RDS_CPU_UTILIZATION       = "rds_cpu_utilization"
+	RDS_IOPS_UTILIZATION      = "rds_iops_utilization"
+	RDS_REPLICATION_DELAY     = "rds_replication_delay"
+	POLARDB_ACTIVE_SESSIONS   = "polardb_active_sessions"
+	POLARDB_CPU_UTILIZATION   = "polardb_cpu_utilization"
+	POLARDB_IOPS              = "polardb_iops"
+	POLARDB_QPS               = "polardb_qps"
+	POLARDB_REPLICA_LAG       = "polardb_replica_lag"
+
+	//Global Params
+	RDS_INSTANCE_ID     = "rds.instance.id"
+	RDS_PERIOD          = "rds.period"
+	RDS_STATISTIC       = "rds.statistic"
+	RDS_AGGREGATION     = "rds.aggregation"
+	POLARDB_CLUSTER_ID  = "polardb.cluster.id"
+	POLARDB_NODE_ID     = "polardb.node.id"
+	POLARDB_NODE_ROLE   = "polardb.node.role"
+	POLARDB_PERIOD      = "polardb.period"
+	POLARDB_STATISTIC   = "polardb.statistic"
+	POLARDB_AGGREGATION = "polardb.aggregation"
+
+	PRODUCT_RDS     = "rds"
+	PRODUCT_POLARDB = "polardb"
+
+	RDS_NAMESPACE     = "acs_rds_dashboard"
+	POLARDB_NAMESPACE = "acs_polardb"
+)
+
+// dbMetric is the cms metric of a database product
+type dbMetric struct {
+	product string
+	name    string
+	unit    utils.Unit
+}
+
+var dbMetrics = map[string]dbMetric{
+	RDS_MYSQL_ACTIVE_SESSIONS: {product: PRODUCT_RDS, name: "MySQL_ActiveSessions", unit: utils.Unit{Name: utils.UNIT_COUNT}},
+	RDS_MYSQL_QPS:             {product: PRODUCT_RDS, name: "MySQL_QPS", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	RDS_CPU_UTILIZATION:       {product: PRODUCT_RDS, name: "CpuUsage", unit: utils.Unit{Name: utils.UNIT_PERCENT}},
+	RDS_IOPS_UTILIZATION:      {product: PRODUCT_RDS, name: "IOPSUsage", unit: utils.Unit{Name: utils.UNIT_PERCENT}},
+	RDS_REPLICATION_DELAY:     {product: PRODUCT_RDS, name: "DataDelay", unit: utils.Unit{Name: utils.UNIT_SECONDS}},
+	POLARDB_ACTIVE_SESSIONS:   {product: PRODUCT_POLARDB, name: "cluster_active_sessions", unit: utils.Unit{Name: utils.UNIT_COUNT}},
+	POLARDB_CPU_UTILIZATION:   {product: PRODUCT_POLARDB, name: "cluster_cpu_utilization", unit: utils.Unit{Name: utils.UNIT_PERCENT}},
+	POLARDB_IOPS:              {product: PRODUCT_POLARDB, name: "cluster_iops", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	POLARDB_QPS:               {product: PRODUCT_POLARDB, name: "cluster_qps", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	POLARDB_REPLICA_LAG:       {product: PRODUCT_POLARDB, name: "cluster_replica_lag_delay", unit: utils.Unit{Name: utils.UNIT_SECONDS}},
+}
+
+// DatabaseMetricSource serves the metrics of ApsaraDB RDS instances and PolarDB clusters from cms.
+type DatabaseMetricSource struct {
+	clients utils.ClientCache
+	// returns the nodes of PolarDB cluster, replaced in tests
+	nodes func(options utils.AccessOptions, clusterId string) ([]dbNode, error)
+}
+
+func NewDatabaseMetricSource() *DatabaseMetricSource {
+	ds := &DatabaseMetricSource{}
+	ds.nodes = ds.describeNodes
+	return ds
+}
+
+// list all external metric
+func (ds *DatabaseMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	for metric := range dbMetrics {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: metric,
+		})
+	}
+	return metricInfoList
+}
+
+// according to the incoming label, get the metric..
+func (ds *DatabaseMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	metric, ok := dbMetrics[info.Metric]
+	if !ok {
+		return values, fmt.Errorf("metric %s is not supported by database", info.Metric)
+	}
+	values, err = ds.getDatabaseMetrics(namespace, info.Metric, metric, requirements)
+	if err != nil {
+		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
+	}
+	return values, err
+}
+
+// a PolarDB metric costs a DescribeDBClusterAttribute and a DescribeMetricList per node,
+// so the requests of the same cluster are cached for cmsutil.CACHE_TTL
+func (ds *DatabaseMetricSource) CacheTTL() time.Duration {
+	return cmsutil.CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (ds *DatabaseMetricSource) MetricUnits() map[string]utils.Unit {
+	units := make(map[string]utils.Unit)
+	for name, metric := range dbMetrics {
+		units[name] = metric.unit
+	}
+	return units
+}
+
+type DatabaseParams struct {
+	utils.AccessOptions
+	InstanceId  string
+	ClusterId   string
+	NodeId      string
+	NodeRole    string
+	Period      int
+	Statistic   string
+	Aggregation string
+}
+
+// get the database specific metric values
+func (ds *DatabaseMetricSource) getDatabaseMetrics(namespace, externalMetric string, metric dbMetric, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	params, err := getDatabaseParams(namespace, requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get %s params,because of %v", metric.product, err)
+	}
+
+	// the dimensions of each datapoint series, the values of series are averaged
+	var dimensions []map[string]string
+	switch metric.product {
+	case PRODUCT_RDS:
+		if params.InstanceId == "" {
+			return values, fmt.Errorf("%s must be provided", RDS_INSTANCE_ID)
+		}
+		dimensions = append(dimensions, map[string]string{"instanceId": params.InstanceId})
+	case PRODUCT_POLARDB:
+		if params.ClusterId == "" {
+			return values, fmt.Errorf("%s must be provided", POLARDB_CLUSTER_ID)
+		}
+		nodeIds, err := ds.getNodeIds(params)
+		if err != nil {
+			return values, err
+		}
+		for _, nodeId := range nodeIds {
+			dimensions = append(dimensions, map[string]string{"clusterId": params.ClusterId, "nodeId": nodeId})
+		}
+	}
+
+	metricValue, err := ds.getAverageValue(metric, params, dimensions)
+	if err != nil {
+		return values, err
+	}
+	value, err := utils.ConvertValue(metricValue, metric.unit)
+	if err != nil {
+		return values, err
+	}
+
+	values = append(values, external_metrics.ExternalMetricValue{
+		MetricName: externalMetric,
+		Value:      *value,
+		Timestamp:  metav1.Now(),
+	})
+	return values, nil
+}
+
+// the average of the series, such as the cpu utilization of all readers
+func (ds *DatabaseMetricSource) getAverageValue(metric dbMetric, params *DatabaseParams, dimensions []map[string]string) (float64, error) {
+	client, err := cmsutil.NewClient(&ds.clients, params.AccessOptions)
+	if err != nil {
+		return 0, err
+	}
+
+	namespace := RDS_NAMESPACE
+	if metric.product == PRODUCT_POLARDB {
+		namespace = POLARDB_NAMESPACE
+	}
+
+	var sum float64
+	for _, d := range dimensions {
+		points, err := cmsutil.DescribeMetricList(client, cmsutil.NewMetricListParams(namespace, metric.name, d, params.Period))
+		if err != nil {
+			return 0, err
+		}
+		value, err := cmsutil.Aggregate(points, params.Statistic, params.Aggregation)
+		if err != nil {
+			return 0, fmt.Errorf("failed to aggregate %s of %v,because of %v", metric.name, d, err)
+		}
+		sum += value
+	}
+	return sum / float64(len(dimensions)), nil
+}
+
+// get the database Params
+func getDatabaseParams(namespace string, requirements labels.Requirements) (params *DatabaseParams, err error) {
+	params = &DatabaseParams{
+		AccessOptions: utils.GetAccessOptions(namespace, requirements),
+		NodeRole:      NODE_ROLE_WRITER,
+		Period:        cmsutil.MIN_PERIOD,
+		Statistic:     cmsutil.STATISTIC_AVERAGE,
+		Aggregation:   cmsutil.AGGREGATION_LATEST,
+	}
+	for _, r := range requirements {
+
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+
+		value := r.Values().List()[0]
+
+		switch r.Key() {
+		case RDS_INSTANCE_ID:
+			params.InstanceId = value
+		case POLARDB_CLUSTER_ID:
+			params.ClusterId = value
+		case POLARDB_NODE_ID:
+			params.NodeId = value
+		case POLARDB_NODE_ROLE:
+			if params.NodeRole, err = parseNodeRole(value); err != nil {
+				return params, err
+			}
+		case RDS_STATISTIC, POLARDB_STATISTIC:
+			if params.Statistic, err = cmsutil.ParseStatistic(value); err != nil {
+				return params, err
+			}
+		case RDS_AGGREGATION, POLARDB_AGGREGATION:
+			if params.Aggregation, err = cmsutil.ParseAggregation(value); err != nil {
+				return params, err
+			}
+		case RDS_PERIOD, POLARDB_PERIOD:
+			params.Period = cmsutil.ParsePeriod(value)
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+)
+
+func TestGetDatabaseParams(t *testing.T) {
+	params, err := getDatabaseParams("default", testutil.ParseRequirements(t, "polardb.cluster.id=pc-1,polardb.node.role=reader,polardb.statistic=maximum,polardb.aggregation=Max,polardb.period=300"))
+	if err != nil {
+		t.Fatalf("failed to get database params: %v", err)
+	}
+	if params.ClusterId != "pc-1" || params.NodeRole != NODE_ROLE_READER || params.Statistic != "Maximum" || params.Aggregation != "max" || params.Period != 300 {
+		t.Fatalf("unexpected database params: %+v", params)
+	}
+
+	params, err = getDatabaseParams("default", testutil.ParseRequirements(t, "rds.instance.id=rm-1,rds.period=30"))
+	if err != nil {
+		t.Fatalf("failed to get database params: %v", err)
+	}
+	if params.InstanceId != "rm-1" || params.NodeRole != NODE_ROLE_WRITER || params.Statistic != "Average" || params.Period != cmsutil.MIN_PERIOD {
+		t.Fatalf("unexpected database params: %+v", params)
+	}
+
+	if _, err := getDatabaseParams("default", testutil.ParseRequirements(t, "polardb.node.role=proxy")); err == nil {
+		t.Fatalf("expected error for unsupported node role")
+	}
+}
+
+func TestGetNodeIds(t *testing.T) {
+	ds := &DatabaseMetricSource{
+		nodes: func(options utils.AccessOptions, clusterId string) ([]dbNode, error) {
+			return []dbNode{
+				{Id: "pi-1", Role: NODE_ROLE_WRITER},
+				{Id: "pi-2", Role: NODE_ROLE_READER},
+				{Id: "pi-3", Role: NODE_ROLE_READER},
+			}, nil
+		},
+	}
+
+	cases := []struct {
+		params   DatabaseParams
+		expected []string
+	}{
+		{DatabaseParams{ClusterId: "pc-1", NodeRole: NODE_ROLE_WRITER}, []string{"pi-1"}},
+		{DatabaseParams{ClusterId: "pc-1", NodeRole: NODE_ROLE_READER}, []string{"pi-2", "pi-3"}},
+		// the node provided explicitly is not resolved
+		{DatabaseParams{ClusterId: "pc-1", NodeId: "pi-4", NodeRole: NODE_ROLE_READER}, []string{"pi-4"}},
+	}
+	for _, c := range cases {
+		nodeIds, err := ds.getNodeIds(&c.params)
+		if err != nil {
+			t.Fatalf("failed to get node ids of %+v: %v", c.params, err)
+		}
+		if !reflect.DeepEqual(nodeIds, c.expected) {
+			t.Fatalf("unexpected node ids of %+v: %v", c.params, nodeIds)
+		}
+	}
+
+	ds.nodes = func(options utils.AccessOptions, clusterId string) ([]dbNode, error) {
+		return []dbNode{{Id: "pi-1", Role: NODE_ROLE_WRITER}}, nil
+	}
+	if _, err := ds.getNodeIds(&DatabaseParams{ClusterId: "pc-1", NodeRole: NODE_ROLE_READER}); err == nil {
+		t.Fatalf("expected error for cluster without readers")
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/polardb"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// the roles of PolarDB nodes returned by DescribeDBClusterAttribute
+	NODE_ROLE_WRITER = "Writer"
+	NODE_ROLE_READER = "Reader"
+)
+
+// dbNode is a node of PolarDB cluster
+type dbNode struct {
+	Id   string
+	Role string
+}
+
+// parseNodeRole returns the canonical role, the role is case-insensitive.
+func parseNodeRole(role string) (string, error) {
+	for _, r := range []string{NODE_ROLE_WRITER, NODE_ROLE_READER} {
+		if strings.EqualFold(r, role) {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("node role %s is not supported, valid roles are %s and %s", role, NODE_ROLE_WRITER, NODE_ROLE_READER)
+}
+
+// the node provided explicitly, or the nodes of the role in the cluster
+func (ds *DatabaseMetricSource) getNodeIds(params *DatabaseParams) ([]string, error) {
+	if params.NodeId != "" {
+		return []string{params.NodeId}, nil
+	}
+
+	nodes, err := ds.nodes(params.AccessOptions, params.ClusterId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes of polardb cluster %s,because of %v", params.ClusterId, err)
+	}
+	nodeIds := make([]string, 0)
+	for _, node := range nodes {
+		if node.Role == params.NodeRole {
+			nodeIds = append(nodeIds, node.Id)
+		}
+	}
+	if len(nodeIds) == 0 {
+		return nil, fmt.Errorf("no %s node is found in polardb cluster %s", params.NodeRole, params.ClusterId)
+	}
+	return nodeIds, nil
+}
+
+func (ds *DatabaseMetricSource) describeNodes(options utils.AccessOptions, clusterId string) ([]dbNode, error) {
+	client, err := ds.polardbClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	request := polardb.CreateDescribeDBClusterAttributeRequest()
+	request.Scheme = "https"
+	request.DBClusterId = clusterId
+	response, err := client.DescribeDBClusterAttribute(request)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]dbNode, 0, len(response.DBNodes))
+	for _, node := range response.DBNodes {
+		nodes = append(nodes, dbNode{Id: node.DBNodeId, Role: node.DBNodeRole})
+	}
+	return nodes, nil
+}
+
+// the client of polardb openapi, which shares the ClientCache with cms client by a different key
+func (ds *DatabaseMetricSource) polardbClient(options utils.AccessOptions) (*polardb.Client, error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(options)
+	if err != nil {
+		log.Errorf("Failed to create polardb client,because of %v", err)
+		return nil, err
+	}
+
+	c, err := ds.clients.Get("polardb/"+accessUserInfo.Region, accessUserInfo, func() (interface{}, error) {
+		if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
+			return polardb.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
+		}
+		return polardb.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*polardb.Client), nil
+}
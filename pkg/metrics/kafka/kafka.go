@@ -0,0 +1,206 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	KAFKA_CONSUMER_GROUP_LAG = "kafka_consumer_group_lag"
+	KAFKA_TOPIC_LAG          = "kafka_topic_lag"
+	KAFKA_TOPIC_CONSUME_TPS  = "kafka_topic_consume_tps"
+	KAFKA_TOPIC_CONSUME_BPS  = "kafka_topic_consume_bytes"
+
+	//Global Params
+	KAFKA_INSTANCE_ID    = "kafka.instance.id"
+	KAFKA_CONSUMER_GROUP = "kafka.consumer.group"
+	KAFKA_TOPIC          = "kafka.topic"
+	KAFKA_PERIOD         = "kafka.period"
+	KAFKA_STATISTIC      = "kafka.statistic"
+	KAFKA_AGGREGATION    = "kafka.aggregation"
+
+	KAFKA_NAMESPACE = "acs_kafka"
+)
+
+// kafkaMetric is the cms metric of acs_kafka namespace
+type kafkaMetric struct {
+	name string
+	// the labels required by the dimensions of metric besides the instance
+	consumerGroup bool
+	topic         bool
+	unit          utils.Unit
+}
+
+var kafkaMetrics = map[string]kafkaMetric{
+	KAFKA_CONSUMER_GROUP_LAG: {name: "message_accumulation", consumerGroup: true, unit: utils.Unit{Name: utils.UNIT_COUNT}},
+	KAFKA_TOPIC_LAG:          {name: "message_accumulation_onetopic", consumerGroup: true, topic: true, unit: utils.Unit{Name: utils.UNIT_COUNT}},
+	KAFKA_TOPIC_CONSUME_TPS:  {name: "topic_reqs_output", topic: true, unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	KAFKA_TOPIC_CONSUME_BPS:  {name: "topic_message_output", topic: true, unit: utils.Unit{Name: utils.UNIT_BYTES_PER_SECOND}},
+}
+
+// KafkaMetricSource serves the lag and consumption metrics of Message Queue for Kafka from cms.
+type KafkaMetricSource struct {
+	clients utils.ClientCache
+}
+
+func NewKafkaMetricSource() *KafkaMetricSource {
+	return &KafkaMetricSource{}
+}
+
+// list all external metric
+func (ks *KafkaMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	for metric := range kafkaMetrics {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: metric,
+		})
+	}
+	return metricInfoList
+}
+
+// according to the incoming label, get the metric..
+func (ks *KafkaMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	metric, ok := kafkaMetrics[info.Metric]
+	if !ok {
+		return values, fmt.Errorf("metric %s is not supported by kafka", info.Metric)
+	}
+	values, err = ks.getKafkaMetrics(namespace, info.Metric, metric, requirements)
+	if err != nil {
+		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
+	}
+	return values, err
+}
+
+// the lag of acs_kafka is reported per minute, so the HPAs of a consumer group share one query in cmsutil.CACHE_TTL
+func (ks *KafkaMetricSource) CacheTTL() time.Duration {
+	return cmsutil.CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (ks *KafkaMetricSource) MetricUnits() map[string]utils.Unit {
+	units := make(map[string]utils.Unit)
+	for name, metric := range kafkaMetrics {
+		units[name] = metric.unit
+	}
+	return units
+}
+
+type KafkaParams struct {
+	InstanceId    string
+	ConsumerGroup string
+	Topic         string
+	Period        int
+	Statistic     string
+	Aggregation   string
+}
+
+// cms dimensions of the metric
+func (params *KafkaParams) dimensions(metric kafkaMetric) map[string]string {
+	dimensions := map[string]string{
+		"instanceId": params.InstanceId,
+	}
+	if metric.consumerGroup {
+		dimensions["consumerGroup"] = params.ConsumerGroup
+	}
+	if metric.topic {
+		dimensions["topic"] = params.Topic
+	}
+	return dimensions
+}
+
+// get the kafka specific metric values
+func (ks *KafkaMetricSource) getKafkaMetrics(namespace, externalMetric string, metric kafkaMetric, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	params, err := getKafkaParams(requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get kafka params,because of %v", err)
+	}
+	if err := validateKafkaParams(params, metric); err != nil {
+		return values, err
+	}
+
+	client, err := cmsutil.NewClient(&ks.clients, utils.GetAccessOptions(namespace, requirements))
+	if err != nil {
+		return values, err
+	}
+
+	points, err := cmsutil.DescribeMetricList(client, cmsutil.NewMetricListParams(KAFKA_NAMESPACE, metric.name, params.dimensions(metric), params.Period))
+	if err != nil {
+		return values, err
+	}
+
+	statistic, err := cmsutil.Aggregate(points, params.Statistic, params.Aggregation)
+	if err != nil {
+		return values, err
+	}
+	value, err := utils.ConvertValue(statistic, metric.unit)
+	if err != nil {
+		return values, err
+	}
+
+	values = append(values, external_metrics.ExternalMetricValue{
+		MetricName: externalMetric,
+		Value:      *value,
+		Timestamp:  metav1.Now(),
+	})
+	return values, nil
+}
+
+// get the kafka Params
+func getKafkaParams(requirements labels.Requirements) (params *KafkaParams, err error) {
+	params = &KafkaParams{
+		Period:      cmsutil.MIN_PERIOD,
+		Statistic:   cmsutil.STATISTIC_AVERAGE,
+		Aggregation: cmsutil.AGGREGATION_LATEST,
+	}
+	for _, r := range requirements {
+
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+
+		value := r.Values().List()[0]
+
+		switch r.Key() {
+		case KAFKA_INSTANCE_ID:
+			params.InstanceId = value
+		case KAFKA_CONSUMER_GROUP:
+			params.ConsumerGroup = value
+		case KAFKA_TOPIC:
+			params.Topic = value
+		case KAFKA_STATISTIC:
+			if params.Statistic, err = cmsutil.ParseStatistic(value); err != nil {
+				return params, err
+			}
+		case KAFKA_AGGREGATION:
+			if params.Aggregation, err = cmsutil.ParseAggregation(value); err != nil {
+				return params, err
+			}
+		case KAFKA_PERIOD:
+			params.Period = cmsutil.ParsePeriod(value)
+		}
+	}
+
+	return params, nil
+}
+
+// check the labels required by the dimensions of metric
+func validateKafkaParams(params *KafkaParams, metric kafkaMetric) error {
+	if params.InstanceId == "" {
+		return fmt.Errorf("%s must be provided", KAFKA_INSTANCE_ID)
+	}
+	if metric.consumerGroup && params.ConsumerGroup == "" {
+		return fmt.Errorf("%s must be provided", KAFKA_CONSUMER_GROUP)
+	}
+	if metric.topic && params.Topic == "" {
+		return fmt.Errorf("%s must be provided", KAFKA_TOPIC)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+)
+
+func TestGetKafkaParams(t *testing.T) {
+	params, err := getKafkaParams(testutil.ParseRequirements(t, "kafka.instance.id=alikafka-1,kafka.consumer.group=orders,kafka.topic=order,kafka.statistic=maximum,kafka.period=300"))
+	if err != nil {
+		t.Fatalf("failed to get kafka params: %v", err)
+	}
+	if params.InstanceId != "alikafka-1" || params.ConsumerGroup != "orders" || params.Topic != "order" || params.Statistic != "Maximum" || params.Period != 300 {
+		t.Fatalf("unexpected kafka params: %+v", params)
+	}
+
+	dimensions := params.dimensions(kafkaMetrics[KAFKA_CONSUMER_GROUP_LAG])
+	if len(dimensions) != 2 || dimensions["consumerGroup"] != "orders" {
+		t.Fatalf("unexpected dimensions of consumer group lag: %v", dimensions)
+	}
+	dimensions = params.dimensions(kafkaMetrics[KAFKA_TOPIC_LAG])
+	if len(dimensions) != 3 || dimensions["topic"] != "order" {
+		t.Fatalf("unexpected dimensions of topic lag: %v", dimensions)
+	}
+}
+
+func TestValidateKafkaParams(t *testing.T) {
+	params, err := getKafkaParams(testutil.ParseRequirements(t, "kafka.instance.id=alikafka-1,kafka.topic=order"))
+	if err != nil {
+		t.Fatalf("failed to get kafka params: %v", err)
+	}
+	if err := validateKafkaParams(params, kafkaMetrics[KAFKA_TOPIC_CONSUME_TPS]); err != nil {
+		t.Fatalf("unexpected error of topic metric: %v", err)
+	}
+	if err := validateKafkaParams(params, kafkaMetrics[KAFKA_TOPIC_LAG]); err == nil {
+		t.Fatalf("expected error for missing consumer group")
+	}
+	if _, err := getKafkaParams(testutil.ParseRequirements(t, "kafka.statistic=p99")); err == nil {
+		t.Fatalf("expected error for unsupported statistic")
+	}
+}
@@ -2,39 +2,80 @@ package metrics
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/ahas"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/alb"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cloudmonitor"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cost"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/costv2"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/database"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/kafka"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/metricstore"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/nlb"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/redis"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/rocketmq"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/slb"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/sls"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/custom_metrics"
 	"k8s.io/metrics/pkg/apis/external_metrics"
 	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
 )
 
+const (
+	// names of metric sources used by --enabled-metric-sources
+	SourceSLS          = "sls"
+	SourceSLB          = "slb"
+	SourceCMS          = "cms"
+	SourceAHAS         = "ahas"
+	SourceCost         = "cost"
+	SourceCostV2       = "costv2"
+	SourceCloudMonitor = "cloudmonitor"
+	SourceALB          = "alb"
+	SourceNLB          = "nlb"
+	SourceKafka        = "kafka"
+	SourceRocketMQ     = "rocketmq"
+	SourceDatabase     = "database"
+	SourceRedis        = "redis"
+	SourceMetricStore  = "metricstore"
+
+	// enable all metric sources
+	AllMetricSources = "*"
+)
+
 var (
 	externalMetricsManager *ExternalMetricsManager
 	customMetricsMangaer   *CustomMetricsManager
 )
 
 func init() {
-	externalMetricsManager = &ExternalMetricsManager{
-		metricsSource: make(map[p.ExternalMetricInfo]MetricSource),
-	}
-
+	externalMetricsManager = NewExternalMetricsManager()
 	customMetricsMangaer = &CustomMetricsManager{
-		metricsSource: make(map[p.CustomMetricInfo]MetricSource),
+		metricsSource: make(map[p.CustomMetricInfo]*sourceEntry),
 	}
 
 	// add metrics source
-	register(sls.NewSLSMetricSource())
-	register(slb.NewSLBMetricSource())
-	register(cms.NewCMSMetricSource())
-	register(ahas.NewAHASSentinelMetricSource())
-	register(cost.NewCOSTMetricSource())
-	register(costv2.NewCOSTV2MetricSource())
+	register(SourceSLS, sls.NewSLSMetricSource())
+	register(SourceSLB, slb.NewSLBMetricSource())
+	register(SourceCMS, cms.NewCMSMetricSource())
+	register(SourceAHAS, ahas.NewAHASSentinelMetricSource())
+	register(SourceCost, cost.NewCOSTMetricSource())
+	register(SourceCostV2, costv2.NewCOSTV2MetricSource())
+	register(SourceCloudMonitor, cloudmonitor.NewCloudMonitorMetricSource())
+	register(SourceALB, alb.NewALBMetricSource())
+	register(SourceNLB, nlb.NewNLBMetricSource())
+	register(SourceKafka, kafka.NewKafkaMetricSource())
+	register(SourceRocketMQ, rocketmq.NewRocketMQMetricSource())
+	register(SourceDatabase, database.NewDatabaseMetricSource())
+	register(SourceRedis, redis.NewRedisMetricSource())
+	register(SourceMetricStore, metricstore.NewMetricStoreSource())
 }
 
 func GetExternalMetricsManager() *ExternalMetricsManager {
@@ -45,8 +86,34 @@ func GetCustomMetricsManager() *CustomMetricsManager {
 	return customMetricsMangaer
 }
 
-func register(m MetricSource) {
-	externalMetricsManager.AddMetricsSource(m)
+func register(name string, m MetricSource) {
+	if err := Register(name, m); err != nil {
+		log.Errorf("Failed to register metric source %s,because of %v", name, err)
+	}
+}
+
+// Register adds the metric source to external metrics manager, and to custom metrics manager
+// if the source serves object metrics as well.
+func Register(name string, m MetricSource) error {
+	entry, err := externalMetricsManager.Register(name, m)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.(CustomMetricSource); ok {
+		customMetricsMangaer.addMetricsSource(entry)
+	}
+	return nil
+}
+
+// Unregister removes the metric source from both external and custom metrics manager.
+func Unregister(name string) {
+	externalMetricsManager.Unregister(name)
+	customMetricsMangaer.removeMetricsSource(name)
+}
+
+// Enable enables or disables the metric source, the metrics of disabled source are neither listed nor served.
+func Enable(name string, enabled bool) error {
+	return externalMetricsManager.Enable(name, enabled)
 }
 
 type MetricSource interface {
@@ -54,33 +121,324 @@ type MetricSource interface {
 	GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) ([]external_metrics.ExternalMetricValue, error)
 }
 
+// CustomMetricSource is implemented by metric sources which are able to
+// describe a metric by the kubernetes object itself (e.g. a Deployment).
+// The returned MetricValue doesn't need DescribedObject, it will be filled by the provider.
+type CustomMetricSource interface {
+	GetCustomMetricInfoList() []p.CustomMetricInfo
+	GetCustomMetric(info p.CustomMetricInfo, name types.NamespacedName, metricSelector labels.Selector) (*custom_metrics.MetricValue, error)
+}
+
+// RunnableMetricSource is implemented by metric source which needs to watch kubernetes objects.
+type RunnableMetricSource interface {
+	RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{})
+}
+
+// DynamicMetricSource is implemented by metric source whose metrics change at runtime.
+// The source calls the handler to refresh its registered metrics.
+type DynamicMetricSource interface {
+	SetMetricsChangedHandler(handler func())
+}
+
+// sourceEntry is a registered metric source
+type sourceEntry struct {
+	name   string
+	source MetricSource
+
+	// guarded by lock of ExternalMetricsManager
+	enabled bool
+	running bool
+	status  SourceStatus
+}
+
 type ExternalMetricsManager struct {
-	metricsSource map[p.ExternalMetricInfo]MetricSource
+	lock          sync.RWMutex
+	sources       map[string]*sourceEntry
+	metricsSource map[p.ExternalMetricInfo]*sourceEntry
+	cache         *metricsCache
+
+	// kubeClient and stopCh are kept to start the sources registered after RunUntil
+	kubeClient dynamic.Interface
+	stopCh     <-chan struct{}
 }
 
-type CustomMetricsManager struct {
-	metricsSource map[p.CustomMetricInfo]MetricSource
+func NewExternalMetricsManager() *ExternalMetricsManager {
+	return &ExternalMetricsManager{
+		sources:       make(map[string]*sourceEntry),
+		metricsSource: make(map[p.ExternalMetricInfo]*sourceEntry),
+		cache:         newMetricsCache(),
+	}
 }
 
-func (em *ExternalMetricsManager) AddMetricsSource(m MetricSource) {
-	metricInfoList := m.GetExternalMetricInfoList()
-	for _, p := range metricInfoList {
-		log.Infof("Register metric: %v to external metrics manager\n", p)
-		em.metricsSource[p] = m
+// Register adds the metric source with a unique name, the source is enabled by default.
+func (em *ExternalMetricsManager) Register(name string, m MetricSource) (*sourceEntry, error) {
+	em.lock.Lock()
+	if _, ok := em.sources[name]; ok {
+		em.lock.Unlock()
+		return nil, fmt.Errorf("metric source %s is already registered", name)
+	}
+	entry := &sourceEntry{
+		name:    name,
+		source:  m,
+		enabled: true,
+		status:  newSourceStatus(name),
+	}
+	em.sources[name] = entry
+	em.refreshMetricsSourceLocked(entry)
+	em.runLocked(entry)
+	em.lock.Unlock()
+
+	if dm, ok := m.(DynamicMetricSource); ok {
+		dm.SetMetricsChangedHandler(func() {
+			em.refreshMetricsSource(entry)
+		})
+	}
+	return entry, nil
+}
+
+// Unregister removes the metric source and its metrics.
+func (em *ExternalMetricsManager) Unregister(name string) {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	entry, ok := em.sources[name]
+	if !ok {
+		return
+	}
+	for info, e := range em.metricsSource {
+		if e == entry {
+			delete(em.metricsSource, info)
+		}
+	}
+	delete(em.sources, name)
+	sourceHealthy.DeleteLabelValues(name)
+	log.Infof("Unregister metric source %s from external metrics manager", name)
+}
+
+// Enable enables or disables the metric source.
+func (em *ExternalMetricsManager) Enable(name string, enabled bool) error {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	entry, ok := em.sources[name]
+	if !ok {
+		return fmt.Errorf("metric source %s is not found", name)
+	}
+	if entry.enabled != enabled {
+		log.Infof("Set metric source %s enabled: %v", name, enabled)
+	}
+	entry.enabled = enabled
+	em.runLocked(entry)
+	return nil
+}
+
+// SetEnabledSources enables the specific metric sources and disables the others, * means all.
+func (em *ExternalMetricsManager) SetEnabledSources(names []string) error {
+	enabled := make(map[string]bool)
+	for _, name := range names {
+		if name == AllMetricSources {
+			enabled = nil
+			break
+		}
+		enabled[name] = true
+	}
+
+	em.lock.RLock()
+	for name := range enabled {
+		if _, ok := em.sources[name]; !ok {
+			em.lock.RUnlock()
+			return fmt.Errorf("metric source %s is not found", name)
+		}
+	}
+	all := make([]string, 0, len(em.sources))
+	for name := range em.sources {
+		all = append(all, name)
+	}
+	em.lock.RUnlock()
+
+	for _, name := range all {
+		if err := em.Enable(name, enabled == nil || enabled[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsEnabled returns whether the metric source is registered and enabled.
+func (em *ExternalMetricsManager) IsEnabled(name string) bool {
+	em.lock.RLock()
+	defer em.lock.RUnlock()
+	entry, ok := em.sources[name]
+	return ok && entry.enabled
+}
+
+// re-register the metrics of the source, metrics registered by other sources are not overridden.
+// The handler of an unregistered source is ignored even if another source is registered with its name.
+func (em *ExternalMetricsManager) refreshMetricsSource(entry *sourceEntry) {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	if e, ok := em.sources[entry.name]; !ok || e != entry {
+		return
+	}
+	em.refreshMetricsSourceLocked(entry)
+}
+
+func (em *ExternalMetricsManager) refreshMetricsSourceLocked(entry *sourceEntry) {
+	metricInfoList := entry.source.GetExternalMetricInfoList()
+	current := make(map[p.ExternalMetricInfo]bool)
+	for _, info := range metricInfoList {
+		current[info] = true
+	}
+	for info, e := range em.metricsSource {
+		if e == entry && !current[info] {
+			log.Infof("Unregister metric: %v from external metrics manager\n", info)
+			delete(em.metricsSource, info)
+		}
+	}
+	for _, info := range metricInfoList {
+		if e, ok := em.metricsSource[info]; ok {
+			if e != entry {
+				log.Warningf("Metric %v is already registered by metric source %s and skip", info, e.name)
+			}
+			continue
+		}
+		log.Infof("Register metric: %v to external metrics manager\n", info)
+		em.metricsSource[info] = entry
+	}
+}
+
+// RunUntil starts the enabled metric sources which need to watch kubernetes objects.
+func (em *ExternalMetricsManager) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	em.kubeClient = kubeClient
+	em.stopCh = stopCh
+	for _, entry := range em.sources {
+		em.runLocked(entry)
 	}
 }
 
+// start the source once it is enabled and the manager is running
+func (em *ExternalMetricsManager) runLocked(entry *sourceEntry) {
+	if em.kubeClient == nil || !entry.enabled || entry.running {
+		return
+	}
+	if rm, ok := entry.source.(RunnableMetricSource); ok {
+		rm.RunUntil(em.kubeClient, em.stopCh)
+	}
+	entry.running = true
+}
+
+// GetMetricsInfoList returns the metrics of enabled sources. The metrics of unhealthy sources are still listed,
+// the health is only updated by requests and the source recovers once a request to it succeeds.
 func (em *ExternalMetricsManager) GetMetricsInfoList() []p.ExternalMetricInfo {
+	em.lock.RLock()
+	defer em.lock.RUnlock()
 	metricsInfoList := make([]p.ExternalMetricInfo, 0)
-	for source, _ := range em.metricsSource {
-		metricsInfoList = append(metricsInfoList, source)
+	for info, entry := range em.metricsSource {
+		if !entry.enabled {
+			continue
+		}
+		metricsInfoList = append(metricsInfoList, info)
 	}
 	return metricsInfoList
 }
 
+// GetSourcesStatus returns the status of all registered metric sources.
+func (em *ExternalMetricsManager) GetSourcesStatus() []SourceStatus {
+	em.lock.RLock()
+	defer em.lock.RUnlock()
+	statusList := make([]SourceStatus, 0, len(em.sources))
+	for _, entry := range em.sources {
+		status := entry.status
+		status.Enabled = entry.enabled
+		status.Metrics = 0
+		status.Units = metricUnits(entry.source)
+		for _, e := range em.metricsSource {
+			if e == entry {
+				status.Metrics++
+			}
+		}
+		statusList = append(statusList, status)
+	}
+	sort.Slice(statusList, func(i, j int) bool {
+		return statusList[i].Name < statusList[j].Name
+	})
+	return statusList
+}
+
 func (em *ExternalMetricsManager) GetExternalMetrics(namespace string, requirements labels.Requirements, info p.ExternalMetricInfo) ([]external_metrics.ExternalMetricValue, error) {
-	if source, ok := em.metricsSource[info]; ok {
-		return source.GetExternalMetric(info, namespace, requirements)
+	em.lock.RLock()
+	entry, ok := em.metricsSource[info]
+	enabled := ok && entry.enabled
+	em.lock.RUnlock()
+	if !enabled {
+		return nil, fmt.Errorf("The specific metric source %s is not found.\n", info.Metric)
+	}
+
+	source := entry.source
+	var ttl time.Duration
+	if cs, ok := source.(CachedMetricSource); ok {
+		ttl = cs.CacheTTL()
+	}
+	values, err := em.cache.getOrLoad(cacheKey(info, namespace, requirements), info.Metric, ttl, func() ([]external_metrics.ExternalMetricValue, error) {
+		values, err := source.GetExternalMetric(info, namespace, requirements)
+		em.updateStatus(entry, err)
+		return values, err
+	})
+	return values, err
+}
+
+func (em *ExternalMetricsManager) updateStatus(entry *sourceEntry, err error) {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	entry.status.update(err, time.Now())
+}
+
+type CustomMetricsManager struct {
+	lock          sync.RWMutex
+	metricsSource map[p.CustomMetricInfo]*sourceEntry
+}
+
+func (cm *CustomMetricsManager) addMetricsSource(entry *sourceEntry) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	metricInfoList := entry.source.(CustomMetricSource).GetCustomMetricInfoList()
+	for _, p := range metricInfoList {
+		log.Infof("Register metric: %v to custom metrics manager\n", p)
+		cm.metricsSource[p] = entry
+	}
+}
+
+func (cm *CustomMetricsManager) removeMetricsSource(name string) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	for info, entry := range cm.metricsSource {
+		if entry.name == name {
+			delete(cm.metricsSource, info)
+		}
+	}
+}
+
+func (cm *CustomMetricsManager) GetMetricsInfoList() []p.CustomMetricInfo {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+	metricsInfoList := make([]p.CustomMetricInfo, 0)
+	for info, entry := range cm.metricsSource {
+		if !externalMetricsManager.IsEnabled(entry.name) {
+			continue
+		}
+		metricsInfoList = append(metricsInfoList, info)
+	}
+	return metricsInfoList
+}
+
+func (cm *CustomMetricsManager) GetCustomMetric(name types.NamespacedName, info p.CustomMetricInfo, metricSelector labels.Selector) (*custom_metrics.MetricValue, error) {
+	cm.lock.RLock()
+	entry, ok := cm.metricsSource[info]
+	cm.lock.RUnlock()
+	if ok && externalMetricsManager.IsEnabled(entry.name) {
+		value, err := entry.source.(CustomMetricSource).GetCustomMetric(info, name, metricSelector)
+		externalMetricsManager.updateStatus(entry, err)
+		return value, err
 	}
 
 	return nil, fmt.Errorf("The specific metric source %s is not found.\n", info.Metric)
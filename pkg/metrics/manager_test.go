@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+type fakeMetricSource struct {
+	metrics []string
+	err     error
+}
+
+func (fs *fakeMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	for _, m := range fs.metrics {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{Metric: m})
+	}
+	return metricInfoList
+}
+
+func (fs *fakeMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) ([]external_metrics.ExternalMetricValue, error) {
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	return []external_metrics.ExternalMetricValue{{MetricName: info.Metric, Value: *resource.NewQuantity(1, resource.DecimalSI)}}, nil
+}
+
+func hasMetric(em *ExternalMetricsManager, metric string) bool {
+	for _, info := range em.GetMetricsInfoList() {
+		if info.Metric == metric {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRegisterAndUnregister(t *testing.T) {
+	em := NewExternalMetricsManager()
+	if _, err := em.Register("a", &fakeMetricSource{metrics: []string{"m1", "m2"}}); err != nil {
+		t.Fatalf("failed to register source: %v", err)
+	}
+	if _, err := em.Register("a", &fakeMetricSource{}); err == nil {
+		t.Fatalf("expect error when registering duplicated source")
+	}
+	// m2 is owned by source a and not overridden
+	if _, err := em.Register("b", &fakeMetricSource{metrics: []string{"m2", "m3"}}); err != nil {
+		t.Fatalf("failed to register source: %v", err)
+	}
+	if len(em.GetMetricsInfoList()) != 3 {
+		t.Fatalf("expect 3 metrics, got %v", em.GetMetricsInfoList())
+	}
+
+	em.Unregister("a")
+	if hasMetric(em, "m1") || hasMetric(em, "m2") || !hasMetric(em, "m3") {
+		t.Fatalf("unexpected metrics after unregister: %v", em.GetMetricsInfoList())
+	}
+	if _, err := em.GetExternalMetrics("default", nil, p.ExternalMetricInfo{Metric: "m1"}); err == nil {
+		t.Fatalf("expect error for metric of unregistered source")
+	}
+}
+
+type fakeDynamicMetricSource struct {
+	fakeMetricSource
+	handler func()
+}
+
+func (fs *fakeDynamicMetricSource) SetMetricsChangedHandler(handler func()) {
+	fs.handler = handler
+}
+
+func TestRefreshReplacedSource(t *testing.T) {
+	em := NewExternalMetricsManager()
+	old := &fakeDynamicMetricSource{fakeMetricSource: fakeMetricSource{metrics: []string{"m1"}}}
+	em.Register("a", old)
+	em.Unregister("a")
+	current := &fakeDynamicMetricSource{fakeMetricSource: fakeMetricSource{metrics: []string{"m2"}}}
+	em.Register("a", current)
+
+	// the stale handler of the unregistered source must not register its metrics again
+	old.metrics = []string{"m1", "m3"}
+	old.handler()
+	if hasMetric(em, "m1") || hasMetric(em, "m3") || !hasMetric(em, "m2") {
+		t.Fatalf("unexpected metrics after refreshing the unregistered source: %v", em.GetMetricsInfoList())
+	}
+
+	current.metrics = []string{"m2", "m4"}
+	current.handler()
+	if !hasMetric(em, "m4") {
+		t.Fatalf("expect metrics of the registered source to be refreshed: %v", em.GetMetricsInfoList())
+	}
+}
+
+func TestEnableSources(t *testing.T) {
+	em := NewExternalMetricsManager()
+	em.Register("a", &fakeMetricSource{metrics: []string{"m1"}})
+	em.Register("b", &fakeMetricSource{metrics: []string{"m2"}})
+
+	if err := em.SetEnabledSources([]string{"b"}); err != nil {
+		t.Fatalf("failed to set enabled sources: %v", err)
+	}
+	if hasMetric(em, "m1") || !hasMetric(em, "m2") {
+		t.Fatalf("unexpected metrics with source a disabled: %v", em.GetMetricsInfoList())
+	}
+	if _, err := em.GetExternalMetrics("default", nil, p.ExternalMetricInfo{Metric: "m1"}); err == nil {
+		t.Fatalf("expect error for metric of disabled source")
+	}
+
+	if err := em.SetEnabledSources([]string{AllMetricSources}); err != nil {
+		t.Fatalf("failed to set enabled sources: %v", err)
+	}
+	if !hasMetric(em, "m1") || !hasMetric(em, "m2") {
+		t.Fatalf("unexpected metrics with all sources enabled: %v", em.GetMetricsInfoList())
+	}
+
+	if err := em.SetEnabledSources([]string{"c"}); err == nil {
+		t.Fatalf("expect error for unknown source")
+	}
+	if err := em.Enable("c", true); err == nil {
+		t.Fatalf("expect error for unknown source")
+	}
+}
+
+func TestSourceStatus(t *testing.T) {
+	em := NewExternalMetricsManager()
+	source := &fakeMetricSource{metrics: []string{"m1"}, err: errors.New("throttled")}
+	em.Register("a", source)
+
+	if _, err := em.GetExternalMetrics("default", nil, p.ExternalMetricInfo{Metric: "m1"}); err == nil {
+		t.Fatalf("expect error from source")
+	}
+	status := em.GetSourcesStatus()
+	if len(status) != 1 || status[0].Healthy || status[0].LastError != "throttled" || status[0].Metrics != 1 {
+		t.Fatalf("unexpected status of unhealthy source: %+v", status)
+	}
+	transition := status[0].LastTransitionTime
+
+	source.err = nil
+	if _, err := em.GetExternalMetrics("default", nil, p.ExternalMetricInfo{Metric: "m1"}); err != nil {
+		t.Fatalf("failed to get metric: %v", err)
+	}
+	status = em.GetSourcesStatus()
+	if !status[0].Healthy || status[0].LastError != "" || status[0].LastTransitionTime.Before(transition) {
+		t.Fatalf("unexpected status of recovered source: %+v", status)
+	}
+}
+
+type fakeUnitMetricSource struct {
+	fakeMetricSource
+}
+
+func (fs *fakeUnitMetricSource) MetricUnits() map[string]utils.Unit {
+	return map[string]utils.Unit{"m1": {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND}}
+}
+
+func TestSourceStatusUnits(t *testing.T) {
+	em := NewExternalMetricsManager()
+	em.Register("a", &fakeMetricSource{metrics: []string{"m0"}})
+	em.Register("b", &fakeUnitMetricSource{fakeMetricSource{metrics: []string{"m1"}}})
+
+	status := em.GetSourcesStatus()
+	if len(status) != 2 || status[0].Units != nil || status[1].Units["m1"] != utils.UNIT_MILLISECONDS {
+		t.Fatalf("unexpected units in status: %+v", status)
+	}
+}
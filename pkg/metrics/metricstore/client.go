@@ -0,0 +1,67 @@
+package metricstore
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	prom "sigs.k8s.io/prometheus-adapter/pkg/client"
+)
+
+const (
+	// the header of security token if the credentials are from STS
+	SECURITY_TOKEN_HEADER = "x-acs-security-token"
+
+	// the timeout of a request to MetricStore, the context of prometheus client is not passed to the request
+	REQUEST_TIMEOUT = 60 * time.Second
+)
+
+// credentialRoundTripper signs the requests to MetricStore with the credentials of AccessKey,
+// the credentials are resolved for every request since the STS tokens are rotated.
+type credentialRoundTripper struct {
+	options utils.AccessOptions
+	rt      http.RoundTripper
+}
+
+func (c *credentialRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(c.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials of metricstore,because of %v", err)
+	}
+	// the headers are shared by the requests of prometheus client
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+	if accessUserInfo.Token != "" {
+		req.Header.Set(SECURITY_TOKEN_HEADER, accessUserInfo.Token)
+	}
+	return c.rt.RoundTrip(req)
+}
+
+// endpoint returns the prometheus compatible api of MetricStore
+func endpoint(config *MetricStoreConfig) (*url.URL, error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(utils.AccessOptions{Region: config.Region, Profile: config.Profile})
+	if err != nil {
+		return nil, err
+	}
+	host := fmt.Sprintf("%s.%s.log.aliyuncs.com", config.Project, accessUserInfo.Region)
+	if config.internal() {
+		host = fmt.Sprintf("%s.%s-intranet.log.aliyuncs.com", config.Project, accessUserInfo.Region)
+	}
+	return url.Parse(fmt.Sprintf("https://%s/prometheus/%s/%s", host, config.Project, config.MetricStore))
+}
+
+// newPromClient creates the prometheus client of MetricStore
+func newPromClient(config *MetricStoreConfig, baseURL *url.URL, rt http.RoundTripper) prom.Client {
+	httpClient := &http.Client{
+		Timeout: REQUEST_TIMEOUT,
+		Transport: &credentialRoundTripper{
+			options: utils.AccessOptions{Region: config.Region, Profile: config.Profile},
+			rt:      rt,
+		},
+	}
+	genericPromClient := prom.NewGenericAPIClient(httpClient, baseURL, http.Header{})
+	instrumentedGenericPromClient := utils.InstrumentGenericAPIClient(genericPromClient, baseURL.String())
+	return prom.NewClientForAPI(instrumentedGenericPromClient)
+}
@@ -0,0 +1,105 @@
+package metricstore
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	cfg "sigs.k8s.io/prometheus-adapter/pkg/config"
+)
+
+// Config is the file of --metricstore-config
+type Config struct {
+	MetricStores []MetricStoreConfig `yaml:"metricStores"`
+}
+
+// MetricStoreConfig maps the metrics of a SLS MetricStore to external metrics,
+// the rules are the same as the externalRules of prometheus.
+type MetricStoreConfig struct {
+	Project     string `yaml:"project"`
+	MetricStore string `yaml:"metricStore"`
+	// the region and credential profile to access the MetricStore, see alibabacloud.region and alibabacloud.profile
+	Region  string `yaml:"region,omitempty"`
+	Profile string `yaml:"profile,omitempty"`
+	// use the intranet endpoint of SLS, true by default
+	Internal *bool `yaml:"internal,omitempty"`
+
+	ExternalRules []cfg.DiscoveryRule `yaml:"externalRules"`
+}
+
+// Options of the MetricStore source, which are provided by the adapter before it starts.
+type Options struct {
+	// the config file of MetricStores, the source is disabled if empty
+	ConfigFile string
+	// used by the resource overrides of rules
+	Mapper apimeta.RESTMapper
+	// MetricsRelistInterval and MetricsMaxAge are the same as prometheus
+	MetricsRelistInterval time.Duration
+	MetricsMaxAge         time.Duration
+}
+
+var (
+	// the project is a part of the endpoint of SLS
+	projectPattern     = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+	metricStorePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	optionsLock sync.RWMutex
+	options     Options
+)
+
+// SetOptions configures the MetricStore source, the MetricStores are loaded when the source runs.
+func SetOptions(opts Options) {
+	optionsLock.Lock()
+	defer optionsLock.Unlock()
+	options = opts
+}
+
+func getOptions() Options {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+	return options
+}
+
+// readConfig loads and validates the config of MetricStores
+func readConfig(path string) (*Config, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metricstore config %s,because of %v", path, err)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(contents, config); err != nil {
+		return nil, fmt.Errorf("failed to parse metricstore config %s,because of %v", path, err)
+	}
+	for _, c := range config.MetricStores {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func (c *MetricStoreConfig) validate() error {
+	if c.Project == "" || c.MetricStore == "" {
+		return errors.New("project and metricStore must be provided")
+	}
+	if !projectPattern.MatchString(c.Project) || !metricStorePattern.MatchString(c.MetricStore) {
+		return fmt.Errorf("invalid project or metricStore %s", c)
+	}
+	if len(c.ExternalRules) == 0 {
+		return fmt.Errorf("externalRules of metricstore %s/%s must be provided", c.Project, c.MetricStore)
+	}
+	return nil
+}
+
+func (c *MetricStoreConfig) internal() bool {
+	return c.Internal == nil || *c.Internal
+}
+
+func (c *MetricStoreConfig) String() string {
+	return fmt.Sprintf("%s/%s", c.Project, c.MetricStore)
+}
@@ -0,0 +1,204 @@
+package metricstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	externalprovider "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider/prometheusProvider/external-provider"
+	pmodel "github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+	prom "sigs.k8s.io/prometheus-adapter/pkg/client"
+	"sigs.k8s.io/prometheus-adapter/pkg/naming"
+)
+
+const (
+	// the default of MetricsRelistInterval and MetricsMaxAge
+	DEFAULT_RELIST_INTERVAL = 10 * time.Minute
+	DEFAULT_MAX_AGE         = 20 * time.Minute
+
+	// the timeout of a query, which is sent to MetricStore as well
+	QUERY_TIMEOUT = 30 * time.Second
+)
+
+// the endpoint is resolved again if the region is not available yet, such as the metadata server is unreachable
+var endpointRetryInterval = 30 * time.Second
+
+// metricStore serves the external metrics of a SLS MetricStore by the rules of prometheus
+type metricStore struct {
+	// the order of MetricStore in config
+	index      int
+	config     MetricStoreConfig
+	promClient prom.Client
+	registry   externalprovider.ExternalSeriesRegistry
+	converter  externalprovider.MetricConverter
+}
+
+// MetricStoreSource runs PromQL against the prometheus compatible api of SLS MetricStores.
+type MetricStoreSource struct {
+	lock     sync.RWMutex
+	stores   []*metricStore
+	onChange func()
+
+	// returns the prometheus api of MetricStore, replaced in tests
+	endpoint func(config *MetricStoreConfig) (*url.URL, error)
+}
+
+func NewMetricStoreSource() *MetricStoreSource {
+	return &MetricStoreSource{endpoint: endpoint}
+}
+
+// list the external metrics discovered from all MetricStores
+func (ms *MetricStoreSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	seen := make(map[string]bool)
+	for _, store := range ms.stores {
+		for _, info := range store.registry.ListAllMetrics() {
+			if seen[info.Metric] {
+				continue
+			}
+			seen[info.Metric] = true
+			metricInfoList = append(metricInfoList, info)
+		}
+	}
+	return metricInfoList
+}
+
+// query the MetricStore which discovers the metric, the first one is used if the metric is discovered by many.
+func (ms *MetricStoreSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	metricSelector := labels.NewSelector().Add(requirements...)
+
+	ms.lock.RLock()
+	stores := ms.stores
+	ms.lock.RUnlock()
+	for _, store := range stores {
+		selector, found, err := store.registry.QueryForMetric(namespace, info.Metric, metricSelector)
+		if err != nil {
+			log.Warningf("Failed to generate query of %s for metricstore %s,because of %v", info.Metric, &store.config, err)
+			return values, err
+		}
+		if !found {
+			continue
+		}
+
+		log.V(4).Infof("External metrics: %s metricstore: %s query: %s", info.Metric, &store.config, selector)
+		ctx, cancel := context.WithTimeout(context.Background(), QUERY_TIMEOUT)
+		queryResults, err := store.promClient.Query(ctx, pmodel.Now(), selector)
+		cancel()
+		if err != nil {
+			log.Warningf("Failed to query metricstore %s,because of %v", &store.config, err)
+			return values, fmt.Errorf("failed to query metricstore %s,because of %v", &store.config, err)
+		}
+		list, err := store.converter.Convert(info, queryResults)
+		if err != nil {
+			return values, err
+		}
+		return list.Items, nil
+	}
+	return values, fmt.Errorf("metric %s is not found in metricstores", info.Metric)
+}
+
+func (ms *MetricStoreSource) SetMetricsChangedHandler(handler func()) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.onChange = handler
+}
+
+// RunUntil loads the MetricStores from config file and discovers their metrics periodically,
+// it returns without waiting for the endpoints of MetricStores.
+func (ms *MetricStoreSource) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	opts := getOptions()
+	if opts.ConfigFile == "" {
+		log.Infof("No metricstore config is provided and skip discovering metrics of metricstores")
+		return
+	}
+	config, err := readConfig(opts.ConfigFile)
+	if err != nil {
+		log.Errorf("Failed to load metricstores,because of %v", err)
+		return
+	}
+	if opts.MetricsRelistInterval <= 0 {
+		opts.MetricsRelistInterval = DEFAULT_RELIST_INTERVAL
+	}
+	if opts.MetricsMaxAge <= 0 {
+		opts.MetricsMaxAge = DEFAULT_MAX_AGE
+	}
+
+	for i := range config.MetricStores {
+		go ms.runMetricStore(i, config.MetricStores[i], opts, stopCh)
+	}
+}
+
+// runMetricStore resolves the endpoint of MetricStore until it succeeds, and then discovers its metrics.
+func (ms *MetricStoreSource) runMetricStore(index int, c MetricStoreConfig, opts Options, stopCh <-chan struct{}) {
+	var baseURL *url.URL
+	wait.PollImmediateUntil(endpointRetryInterval, func() (bool, error) {
+		u, err := ms.endpoint(&c)
+		if err != nil {
+			log.Errorf("Failed to get endpoint of metricstore %s and retry in %v,because of %v", &c, endpointRetryInterval, err)
+			return false, nil
+		}
+		baseURL = u
+		return true, nil
+	}, stopCh)
+	if baseURL == nil {
+		return
+	}
+
+	runner, err := ms.addMetricStore(index, c, newPromClient(&c, baseURL, http.DefaultTransport), opts)
+	if err != nil {
+		log.Errorf("Failed to add metricstore %s,because of %v", &c, err)
+		return
+	}
+	runner.RunUntil(stopCh)
+	log.Infof("Discovering external metrics of metricstore %s from %s", &c, baseURL)
+}
+
+// addMetricStore registers the MetricStore in the order of config, the metrics are discovered once the returned runner starts.
+func (ms *MetricStoreSource) addMetricStore(index int, config MetricStoreConfig, promClient prom.Client, opts Options) (externalprovider.Runnable, error) {
+	namers, err := naming.NamersFromConfig(config.ExternalRules, opts.Mapper)
+	if err != nil {
+		return nil, fmt.Errorf("unable to construct naming scheme from externalRules: %v", err)
+	}
+
+	basicLister := externalprovider.NewBasicMetricLister(promClient, namers, opts.MetricsMaxAge)
+	periodicLister, runner := externalprovider.NewPeriodicMetricLister(basicLister, opts.MetricsRelistInterval)
+	// the registry is notified before the source since it's added first
+	registry := externalprovider.NewExternalSeriesRegistry(periodicLister)
+	periodicLister.AddNotificationReceiver(func(externalprovider.MetricUpdateResult) {
+		ms.lock.RLock()
+		onChange := ms.onChange
+		ms.lock.RUnlock()
+		if onChange != nil {
+			onChange()
+		}
+	})
+
+	ms.lock.Lock()
+	// the stores are sorted in a copy since GetExternalMetric iterates them without lock
+	stores := append(make([]*metricStore, 0, len(ms.stores)+1), ms.stores...)
+	stores = append(stores, &metricStore{
+		index:      index,
+		config:     config,
+		promClient: promClient,
+		registry:   registry,
+		converter:  externalprovider.NewMetricConverter(),
+	})
+	sort.SliceStable(stores, func(i, j int) bool {
+		return stores[i].index < stores[j].index
+	})
+	ms.stores = stores
+	ms.lock.Unlock()
+	return runner, nil
+}
@@ -0,0 +1,226 @@
+package metricstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	externalprovider "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider/prometheusProvider/external-provider"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const testConfig = `
+metricStores:
+- project: k8s-log-c1
+  metricStore: app-metrics
+  region: cn-hangzhou
+  profile: metrics
+  internal: false
+  externalRules:
+  - seriesQuery: 'http_requests_total'
+    resources:
+      namespaced: false
+    name:
+      matches: "^(.*)_total$"
+      as: "${1}_per_second"
+    metricsQuery: 'sum(rate(<<.Series>>{<<.LabelMatchers>>}[1m])) by (<<.GroupBy>>)'
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metricstore")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config, err := readConfig(writeFile(t, dir, "config.yaml", testConfig))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if len(config.MetricStores) != 1 || config.MetricStores[0].internal() || len(config.MetricStores[0].ExternalRules) != 1 {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+
+	invalid := []string{
+		"metricStores:\n- project: k8s-log-c1\n",
+		"metricStores:\n- project: evil.com/x\n  metricStore: m\n  externalRules:\n  - seriesQuery: up\n",
+		"metricStores:\n- project: k8s-log-c1\n  metricstore: m\n",
+	}
+	for _, c := range invalid {
+		if _, err := readConfig(writeFile(t, dir, "invalid.yaml", c)); err == nil {
+			t.Fatalf("expected error for config %q", c)
+		}
+	}
+}
+
+// newMetricStoreServer serves the series and query api of MetricStore k8s-log-c1/app-metrics
+func newMetricStoreServer(queries *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "ak-1" || password != "sk-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/prometheus/k8s-log-c1/app-metrics/api/v1/series":
+			w.Write([]byte(`{"status": "success", "data": [{"__name__": "http_requests_total", "app": "web"}]}`))
+		case "/prometheus/k8s-log-c1/app-metrics/api/v1/query":
+			*queries = append(*queries, r.FormValue("query"))
+			w.Write([]byte(`{"status": "success", "data": {"resultType": "vector", "result": [
+				{"metric": {"app": "web"}, "value": [1700000000, "12.5"]}
+			]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestMetricStoreSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metricstore")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	utils.SetProfilesPath(writeFile(t, dir, "profiles.json", `{"profiles": [
+		{"name": "metrics", "mode": "AK", "accessKeyId": "ak-1", "accessKeySecret": "sk-1"}
+	]}`))
+	defer utils.SetProfilesPath(utils.DEFAULT_PROFILES_PATH)
+
+	var queries []string
+	server := newMetricStoreServer(&queries)
+	defer server.Close()
+
+	config, err := readConfig(writeFile(t, dir, "config.yaml", testConfig))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	c := config.MetricStores[0]
+	baseURL, _ := url.Parse(server.URL + "/prometheus/k8s-log-c1/app-metrics")
+
+	changed := 0
+	ms := NewMetricStoreSource()
+	ms.SetMetricsChangedHandler(func() { changed++ })
+	runner, err := ms.addMetricStore(0, c, newPromClient(&c, baseURL, http.DefaultTransport), Options{MetricsRelistInterval: time.Minute, MetricsMaxAge: time.Minute})
+	if err != nil {
+		t.Fatalf("failed to add metricstore: %v", err)
+	}
+	runner.(externalprovider.MetricListerWithNotification).UpdateNow()
+
+	infos := ms.GetExternalMetricInfoList()
+	if changed != 1 || len(infos) != 1 || infos[0].Metric != "http_requests_per_second" {
+		t.Fatalf("unexpected metrics %v, changed %d times", infos, changed)
+	}
+
+	selector, _ := labels.Parse("app=web")
+	requirements, _ := selector.Requirements()
+	values, err := ms.GetExternalMetric(p.ExternalMetricInfo{Metric: "http_requests_per_second"}, "default", requirements)
+	if err != nil {
+		t.Fatalf("failed to get metric: %v", err)
+	}
+	if len(values) != 1 || values[0].Value.String() != "12500m" || values[0].MetricLabels["app"] != "web" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+	if len(queries) != 1 || !strings.Contains(queries[0], `http_requests_total{app="web"}`) {
+		t.Fatalf("unexpected queries: %v", queries)
+	}
+
+	if _, err := ms.GetExternalMetric(p.ExternalMetricInfo{Metric: "unknown"}, "default", requirements); err == nil {
+		t.Fatalf("expected error for unknown metric")
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metricstore")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	utils.SetProfilesPath(writeFile(t, dir, "profiles.json", `{"profiles": [
+		{"name": "metrics", "mode": "AK", "region": "cn-beijing", "accessKeyId": "ak-1", "accessKeySecret": "sk-1"}
+	]}`))
+	defer utils.SetProfilesPath(utils.DEFAULT_PROFILES_PATH)
+
+	// the region falls back to the one of profile
+	c := &MetricStoreConfig{Project: "k8s-log-c1", MetricStore: "app-metrics", Profile: "metrics"}
+	u, err := endpoint(c)
+	if err != nil {
+		t.Fatalf("failed to get endpoint: %v", err)
+	}
+	if u.String() != "https://k8s-log-c1.cn-beijing-intranet.log.aliyuncs.com/prometheus/k8s-log-c1/app-metrics" {
+		t.Fatalf("unexpected endpoint: %s", u)
+	}
+
+	c.Profile = "unknown"
+	if _, err := endpoint(c); err == nil {
+		t.Fatalf("expected error for unknown profile")
+	}
+}
+
+func TestRunUntilRetriesEndpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metricstore")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	utils.SetProfilesPath(writeFile(t, dir, "profiles.json", `{"profiles": [
+		{"name": "metrics", "mode": "AK", "accessKeyId": "ak-1", "accessKeySecret": "sk-1"}
+	]}`))
+	defer utils.SetProfilesPath(utils.DEFAULT_PROFILES_PATH)
+
+	var queries []string
+	server := newMetricStoreServer(&queries)
+	defer server.Close()
+
+	SetOptions(Options{ConfigFile: writeFile(t, dir, "config.yaml", testConfig), MetricsRelistInterval: time.Minute, MetricsMaxAge: time.Minute})
+	defer SetOptions(Options{})
+	defer func(interval time.Duration) { endpointRetryInterval = interval }(endpointRetryInterval)
+	endpointRetryInterval = 10 * time.Millisecond
+
+	// the region is not available at the first time
+	var lock sync.Mutex
+	calls := 0
+	ms := NewMetricStoreSource()
+	ms.endpoint = func(config *MetricStoreConfig) (*url.URL, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if calls == 1 {
+			return nil, errors.New("region is not available")
+		}
+		return url.Parse(server.URL + "/prometheus/k8s-log-c1/app-metrics")
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ms.RunUntil(nil, stopCh)
+
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(ms.GetExternalMetricInfoList()) == 1, nil
+	})
+	if err != nil {
+		t.Fatalf("expected metrics to be discovered after retrying endpoint: %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected endpoint to be resolved twice, got %d", calls)
+	}
+}
@@ -0,0 +1,204 @@
+package nlb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	NLB_NEW_CONNECTION    = "nlb_new_connection"
+	NLB_ACTIVE_CONNECTION = "nlb_active_connection"
+	NLB_DROP_CONNECTION   = "nlb_drop_connection"
+	NLB_TRAFFIC_RX        = "nlb_traffic_rx"
+	NLB_TRAFFIC_TX        = "nlb_traffic_tx"
+
+	//Global Params
+	NLB_INSTANCE_ID       = "nlb.instance.id"
+	NLB_LISTENER_PORT     = "nlb.listener.port"
+	NLB_LISTENER_PROTOCOL = "nlb.listener.protocol"
+	NLB_PERIOD            = "nlb.period"
+
+	NLB_NAMESPACE = "acs_nlb"
+
+	DEFAULT_LISTENER_PROTOCOL = "TCP"
+)
+
+// units of the raw values of nlb metrics
+var nlbUnits = map[string]utils.Unit{
+	NLB_NEW_CONNECTION:    {Name: utils.UNIT_COUNT_PER_SECOND},
+	NLB_ACTIVE_CONNECTION: {Name: utils.UNIT_COUNT},
+	NLB_DROP_CONNECTION:   {Name: utils.UNIT_COUNT_PER_SECOND},
+	NLB_TRAFFIC_RX:        {Name: utils.UNIT_BYTES_PER_SECOND},
+	NLB_TRAFFIC_TX:        {Name: utils.UNIT_BYTES_PER_SECOND},
+}
+
+// NLBMetricSource serves the metrics of NLB listeners from cms.
+type NLBMetricSource struct {
+	clients utils.ClientCache
+}
+
+func NewNLBMetricSource() *NLBMetricSource {
+	return &NLBMetricSource{}
+}
+
+// list all external metric
+func (nb *NLBMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	var MetricArray = []string{
+		NLB_NEW_CONNECTION,
+		NLB_ACTIVE_CONNECTION,
+		NLB_DROP_CONNECTION,
+		NLB_TRAFFIC_RX,
+		NLB_TRAFFIC_TX,
+	}
+	for _, metric := range MetricArray {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: metric,
+		})
+	}
+	return metricInfoList
+}
+
+// according to the incoming label, get the metric..
+func (nb *NLBMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	switch info.Metric {
+	case NLB_NEW_CONNECTION:
+		values, err = nb.getNLBMetrics(namespace, "ListenerNewConnection", NLB_NEW_CONNECTION, requirements)
+	case NLB_ACTIVE_CONNECTION:
+		values, err = nb.getNLBMetrics(namespace, "ListenerActiveConnection", NLB_ACTIVE_CONNECTION, requirements)
+	case NLB_DROP_CONNECTION:
+		values, err = nb.getNLBMetrics(namespace, "ListenerDropConnection", NLB_DROP_CONNECTION, requirements)
+	case NLB_TRAFFIC_RX:
+		values, err = nb.getNLBMetrics(namespace, "ListenerTrafficRX", NLB_TRAFFIC_RX, requirements)
+	case NLB_TRAFFIC_TX:
+		values, err = nb.getNLBMetrics(namespace, "ListenerTrafficTX", NLB_TRAFFIC_TX, requirements)
+	}
+	if err != nil {
+		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
+	}
+	return values, err
+}
+
+// the listener datapoints of acs_nlb are aggregated per minute, so the HPAs of a listener share one query in cmsutil.CACHE_TTL
+func (nb *NLBMetricSource) CacheTTL() time.Duration {
+	return cmsutil.CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (nb *NLBMetricSource) MetricUnits() map[string]utils.Unit {
+	return nlbUnits
+}
+
+// RunUntil watches Services to resolve NLB instances.
+func (nb *NLBMetricSource) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	utils.GetServiceLister().Run(kubeClient, stopCh)
+}
+
+type NLBParams struct {
+	InstanceId       string
+	ListenerPort     string
+	ListenerProtocol string
+	ServiceName      string
+	ServiceNamespace string
+	ServicePort      string
+	Period           int
+}
+
+// get the nlb specific metric values
+func (nb *NLBMetricSource) getNLBMetrics(namespace, metric, externalMetric string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	params, err := getNLBParams(requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get nlb params,because of %v", err)
+	}
+
+	if params.ServiceName != "" {
+		if params.ServiceNamespace == "" {
+			params.ServiceNamespace = namespace
+		}
+		if err := nb.resolveService(params); err != nil {
+			return values, err
+		}
+	}
+
+	if params.InstanceId == "" || params.ListenerPort == "" {
+		return values, fmt.Errorf("%s and %s or %s must be provided", NLB_INSTANCE_ID, NLB_LISTENER_PORT, utils.K8S_SERVICE_NAME)
+	}
+	if params.ListenerProtocol == "" {
+		params.ListenerProtocol = DEFAULT_LISTENER_PROTOCOL
+	}
+
+	client, err := cmsutil.NewClient(&nb.clients, utils.GetAccessOptions(namespace, requirements))
+	if err != nil {
+		return values, err
+	}
+
+	dimensions := map[string]string{
+		"instanceId":       params.InstanceId,
+		"listenerProtocol": params.ListenerProtocol,
+		"listenerPort":     params.ListenerPort,
+	}
+	points, err := cmsutil.DescribeMetricList(client, cmsutil.NewMetricListParams(NLB_NAMESPACE, metric, dimensions, params.Period))
+	if err != nil {
+		return values, err
+	}
+
+	statistic, err := cmsutil.Aggregate(points, cmsutil.STATISTIC_AVERAGE, cmsutil.AGGREGATION_LATEST)
+	if err != nil {
+		return values, err
+	}
+	value, err := utils.ConvertValue(statistic, nlbUnits[externalMetric])
+	if err != nil {
+		return values, err
+	}
+
+	values = append(values, external_metrics.ExternalMetricValue{
+		MetricName: externalMetric,
+		Value:      *value,
+		Timestamp:  metav1.Now(),
+	})
+	return values, nil
+}
+
+// get the nlb Params
+func getNLBParams(requirements labels.Requirements) (params *NLBParams, err error) {
+	params = &NLBParams{
+		Period: cmsutil.MIN_PERIOD,
+	}
+	for _, r := range requirements {
+
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+
+		value := r.Values().List()[0]
+
+		switch r.Key() {
+		case NLB_INSTANCE_ID:
+			params.InstanceId = value
+		case NLB_LISTENER_PORT:
+			params.ListenerPort = value
+		case NLB_LISTENER_PROTOCOL:
+			params.ListenerProtocol = strings.ToUpper(value)
+		case utils.K8S_SERVICE_NAME:
+			params.ServiceName = value
+		case utils.K8S_SERVICE_NAMESPACE:
+			params.ServiceNamespace = value
+		case utils.K8S_SERVICE_PORT:
+			params.ServicePort = value
+		case NLB_PERIOD:
+			params.Period = cmsutil.ParsePeriod(value)
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,63 @@
+package nlb
+
+import (
+	"testing"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetNLBParams(t *testing.T) {
+	params, err := getNLBParams(testutil.ParseRequirements(t, "nlb.instance.id=nlb-1,nlb.listener.port=443,nlb.listener.protocol=tcpssl,nlb.period=120"))
+	if err != nil {
+		t.Fatalf("failed to get nlb params: %v", err)
+	}
+	if params.InstanceId != "nlb-1" || params.ListenerPort != "443" || params.ListenerProtocol != "TCPSSL" || params.Period != 120 {
+		t.Fatalf("unexpected nlb params: %+v", params)
+	}
+}
+
+func newService(annotations map[string]string, ports ...v1.ServicePort) *v1.Service {
+	svc := &v1.Service{}
+	svc.Namespace = "default"
+	svc.Name = "nginx"
+	svc.Annotations = annotations
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	svc.Spec.Ports = ports
+	svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: "nlb-abc123.cn-hangzhou.nlb.aliyuncs.com"}}
+	return svc
+}
+
+func TestResolveNLBParams(t *testing.T) {
+	params := &NLBParams{}
+	if err := resolveNLBParams(newService(nil, v1.ServicePort{Port: 80, Protocol: v1.ProtocolTCP}), params); err != nil {
+		t.Fatalf("failed to resolve service: %v", err)
+	}
+	if params.InstanceId != "nlb-abc123" || params.ListenerPort != "80" || params.ListenerProtocol != "TCP" {
+		t.Fatalf("unexpected params resolved from service: %+v", params)
+	}
+
+	annotations := map[string]string{
+		utils.LOADBALANCER_ID_ANNOTATION: "nlb-reused",
+		PROTOCOL_PORT_ANNOTATION:         "tcpssl:443",
+	}
+	ports := []v1.ServicePort{
+		{Name: "http", Port: 80, Protocol: v1.ProtocolTCP},
+		{Name: "https", Port: 443, Protocol: v1.ProtocolTCP},
+	}
+	params = &NLBParams{ServicePort: "https"}
+	if err := resolveNLBParams(newService(annotations, ports...), params); err != nil {
+		t.Fatalf("failed to resolve service: %v", err)
+	}
+	if params.InstanceId != "nlb-reused" || params.ListenerPort != "443" || params.ListenerProtocol != "TCPSSL" {
+		t.Fatalf("unexpected params resolved from annotations: %+v", params)
+	}
+
+	if err := resolveNLBParams(newService(nil, ports...), &NLBParams{}); err == nil {
+		t.Fatalf("expect error without port of multi-port service")
+	}
+	if err := resolveNLBParams(newService(nil, ports...), &NLBParams{ServicePort: "grpc"}); err == nil {
+		t.Fatalf("expect error for unknown port")
+	}
+}
@@ -0,0 +1,75 @@
+package nlb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// the listener protocols of service ports, such as tcpssl:443,udp:53
+	PROTOCOL_PORT_ANNOTATION = "service.beta.kubernetes.io/alibaba-cloud-loadbalancer-protocol-port"
+
+	// the address of NLB instance, such as nlb-xxx.cn-hangzhou.nlb.aliyuncs.com
+	NLB_INSTANCE_PREFIX = "nlb-"
+)
+
+// fill the NLB instance and listener of params with the Service
+func (nb *NLBMetricSource) resolveService(params *NLBParams) error {
+	svc, err := utils.GetServiceLister().Get(params.ServiceNamespace, params.ServiceName)
+	if err != nil {
+		return err
+	}
+	return resolveNLBParams(svc, params)
+}
+
+// the params provided explicitly are not overridden.
+func resolveNLBParams(svc *v1.Service, params *NLBParams) error {
+	if params.InstanceId == "" {
+		instanceId, err := getInstanceId(svc)
+		if err != nil {
+			return err
+		}
+		params.InstanceId = instanceId
+	}
+
+	if params.ListenerPort == "" {
+		port, err := utils.GetServicePort(svc, params.ServicePort)
+		if err != nil {
+			return err
+		}
+		params.ListenerPort = strconv.Itoa(int(port.Port))
+		if params.ListenerProtocol == "" {
+			params.ListenerProtocol = getListenerProtocol(svc, port)
+		}
+	}
+	return nil
+}
+
+func getInstanceId(svc *v1.Service) (string, error) {
+	if id := utils.GetLoadBalancerId(svc); id != "" {
+		return id, nil
+	}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if strings.HasPrefix(ingress.Hostname, NLB_INSTANCE_PREFIX) {
+			return strings.SplitN(ingress.Hostname, ".", 2)[0], nil
+		}
+	}
+	return "", fmt.Errorf("NLB instance of service %s/%s is not found", svc.Namespace, svc.Name)
+}
+
+func getListenerProtocol(svc *v1.Service, port *v1.ServicePort) string {
+	for _, pp := range strings.Split(svc.Annotations[PROTOCOL_PORT_ANNOTATION], ",") {
+		parts := strings.Split(strings.TrimSpace(pp), ":")
+		if len(parts) == 2 && parts[1] == strconv.Itoa(int(port.Port)) {
+			return strings.ToUpper(parts[0])
+		}
+	}
+	if port.Protocol != "" {
+		return string(port.Protocol)
+	}
+	return DEFAULT_LISTENER_PROTOCOL
+}
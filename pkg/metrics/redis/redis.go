@@ -0,0 +1,283 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	REDIS_QPS              = "redis_qps"
+	REDIS_HIT_RATIO        = "redis_hit_ratio"
+	REDIS_MEMORY_USAGE     = "redis_memory_usage"
+	REDIS_CONNECTION_USAGE = "redis_connection_usage"
+	REDIS_CPU_USAGE        = "redis_cpu_usage"
+
+	//Global Params
+	REDIS_INSTANCE_ID      = "redis.instance.id"
+	REDIS_NODE_ID          = "redis.node.id"
+	REDIS_ARCHITECTURE     = "redis.architecture"
+	REDIS_PERIOD           = "redis.period"
+	REDIS_STATISTIC        = "redis.statistic"
+	REDIS_AGGREGATION      = "redis.aggregation"
+	REDIS_NODE_AGGREGATION = "redis.node.aggregation"
+
+	// the architectures of instance, the metrics of cluster instances are prefixed by Sharding
+	ARCHITECTURE_STANDARD = "standard"
+	ARCHITECTURE_CLUSTER  = "cluster"
+
+	// the label of per-node series
+	NODE_ID_LABEL = "nodeId"
+
+	// how the values of nodes are reduced to a single series, or none to return a series per node
+	NODE_AGGREGATION_MAX  = "max"
+	NODE_AGGREGATION_SUM  = "sum"
+	NODE_AGGREGATION_AVG  = "avg"
+	NODE_AGGREGATION_NONE = "none"
+
+	REDIS_NAMESPACE = "acs_kvstore"
+)
+
+// redisMetric is the cms metric of acs_kvstore namespace without the architecture prefix
+type redisMetric struct {
+	name string
+	unit utils.Unit
+}
+
+var redisMetrics = map[string]redisMetric{
+	REDIS_QPS:              {name: "UsedQPS", unit: utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND}},
+	REDIS_HIT_RATIO:        {name: "HitRate", unit: utils.Unit{Name: utils.UNIT_PERCENT}},
+	REDIS_MEMORY_USAGE:     {name: "MemoryUsage", unit: utils.Unit{Name: utils.UNIT_PERCENT}},
+	REDIS_CONNECTION_USAGE: {name: "ConnectionUsage", unit: utils.Unit{Name: utils.UNIT_PERCENT}},
+	REDIS_CPU_USAGE:        {name: "CpuUsage", unit: utils.Unit{Name: utils.UNIT_PERCENT}},
+}
+
+// RedisMetricSource serves the metrics of Tair/Redis instances from cms.
+type RedisMetricSource struct {
+	clients utils.ClientCache
+}
+
+func NewRedisMetricSource() *RedisMetricSource {
+	return &RedisMetricSource{}
+}
+
+// list all external metric
+func (rs *RedisMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	for metric := range redisMetrics {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: metric,
+		})
+	}
+	return metricInfoList
+}
+
+// according to the incoming label, get the metric..
+func (rs *RedisMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	metric, ok := redisMetrics[info.Metric]
+	if !ok {
+		return values, fmt.Errorf("metric %s is not supported by redis", info.Metric)
+	}
+	values, err = rs.getRedisMetrics(namespace, info.Metric, metric, requirements)
+	if err != nil {
+		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
+	}
+	return values, err
+}
+
+// the datapoints of all nodes are fetched by one query and aggregated per minute,
+// so the query of an instance is reused in cmsutil.CACHE_TTL
+func (rs *RedisMetricSource) CacheTTL() time.Duration {
+	return cmsutil.CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (rs *RedisMetricSource) MetricUnits() map[string]utils.Unit {
+	units := make(map[string]utils.Unit)
+	for name, metric := range redisMetrics {
+		units[name] = metric.unit
+	}
+	return units
+}
+
+type RedisParams struct {
+	InstanceId   string
+	NodeId       string
+	Architecture string
+	Period       int
+	Statistic    string
+	Aggregation  string
+	// NodeAggregation reduces the values of nodes, max by default
+	NodeAggregation string
+}
+
+// the cms metric name of the architecture, such as StandardUsedQPS or ShardingUsedQPS
+func (params *RedisParams) metricName(metric redisMetric) string {
+	if params.Architecture == ARCHITECTURE_CLUSTER {
+		return "Sharding" + metric.name
+	}
+	return "Standard" + metric.name
+}
+
+// get the redis specific metric values, the values of nodes are reduced by the node aggregation
+// unless it's none, which returns one value per node
+func (rs *RedisMetricSource) getRedisMetrics(namespace, externalMetric string, metric redisMetric, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	params, err := getRedisParams(requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get redis params,because of %v", err)
+	}
+
+	client, err := cmsutil.NewClient(&rs.clients, utils.GetAccessOptions(namespace, requirements))
+	if err != nil {
+		return values, err
+	}
+
+	dimensions := map[string]string{"instanceId": params.InstanceId}
+	if params.NodeId != "" {
+		dimensions[NODE_ID_LABEL] = params.NodeId
+	}
+	points, err := cmsutil.DescribeMetricList(client, cmsutil.NewMetricListParams(REDIS_NAMESPACE, params.metricName(metric), dimensions, params.Period))
+	if err != nil {
+		return values, err
+	}
+
+	series, err := getNodeSeries(points, params.Statistic, params.Aggregation, params.NodeAggregation)
+	if err != nil {
+		return values, err
+	}
+	for _, s := range series {
+		value, err := utils.ConvertValue(s.value, metric.unit)
+		if err != nil {
+			return values, err
+		}
+		metricLabels := map[string]string{}
+		if s.nodeId != "" {
+			metricLabels[NODE_ID_LABEL] = s.nodeId
+		}
+		values = append(values, external_metrics.ExternalMetricValue{
+			MetricName:   externalMetric,
+			MetricLabels: metricLabels,
+			Value:        *value,
+			Timestamp:    metav1.Now(),
+		})
+	}
+	return values, nil
+}
+
+type nodeSeries struct {
+	// empty if the series is reduced from all the nodes
+	nodeId string
+	value  float64
+}
+
+// group the datapoints by node and aggregate each group, then the values of nodes are reduced
+// to a single series by nodeAggregation, or returned ordered by node if it's none
+func getNodeSeries(points []map[string]interface{}, statistic, aggregation, nodeAggregation string) ([]nodeSeries, error) {
+	groups := make(map[string][]map[string]interface{})
+	for _, point := range points {
+		nodeId, _ := point[NODE_ID_LABEL].(string)
+		groups[nodeId] = append(groups[nodeId], point)
+	}
+
+	series := make([]nodeSeries, 0, len(groups))
+	for nodeId, group := range groups {
+		value, err := cmsutil.Aggregate(group, statistic, aggregation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate datapoints of node %s,because of %v", nodeId, err)
+		}
+		series = append(series, nodeSeries{nodeId: nodeId, value: value})
+	}
+	if len(series) == 0 {
+		return nil, errors.New("NoMetricData")
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].nodeId < series[j].nodeId
+	})
+	if nodeAggregation == NODE_AGGREGATION_NONE {
+		return series, nil
+	}
+	return []nodeSeries{reduceNodes(series, nodeAggregation)}, nil
+}
+
+// reduce the values of nodes by max, sum or avg
+func reduceNodes(series []nodeSeries, nodeAggregation string) nodeSeries {
+	var sum, max float64
+	for i, s := range series {
+		sum += s.value
+		if i == 0 || s.value > max {
+			max = s.value
+		}
+	}
+	switch nodeAggregation {
+	case NODE_AGGREGATION_SUM:
+		return nodeSeries{value: sum}
+	case NODE_AGGREGATION_AVG:
+		return nodeSeries{value: sum / float64(len(series))}
+	default:
+		return nodeSeries{value: max}
+	}
+}
+
+// get the redis Params
+func getRedisParams(requirements labels.Requirements) (params *RedisParams, err error) {
+	params = &RedisParams{
+		Architecture: ARCHITECTURE_STANDARD,
+		Period:       cmsutil.MIN_PERIOD,
+		Statistic:    cmsutil.STATISTIC_AVERAGE,
+		Aggregation:  cmsutil.AGGREGATION_LATEST,
+
+		NodeAggregation: NODE_AGGREGATION_MAX,
+	}
+	for _, r := range requirements {
+
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+
+		value := r.Values().List()[0]
+
+		switch r.Key() {
+		case REDIS_INSTANCE_ID:
+			params.InstanceId = value
+		case REDIS_NODE_ID:
+			params.NodeId = value
+		case REDIS_ARCHITECTURE:
+			params.Architecture = strings.ToLower(value)
+			if params.Architecture != ARCHITECTURE_STANDARD && params.Architecture != ARCHITECTURE_CLUSTER {
+				return params, fmt.Errorf("architecture %s is not supported, valid architectures are %s and %s", value, ARCHITECTURE_STANDARD, ARCHITECTURE_CLUSTER)
+			}
+		case REDIS_STATISTIC:
+			if params.Statistic, err = cmsutil.ParseStatistic(value); err != nil {
+				return params, err
+			}
+		case REDIS_AGGREGATION:
+			if params.Aggregation, err = cmsutil.ParseAggregation(value); err != nil {
+				return params, err
+			}
+		case REDIS_NODE_AGGREGATION:
+			params.NodeAggregation = strings.ToLower(value)
+			switch params.NodeAggregation {
+			case NODE_AGGREGATION_MAX, NODE_AGGREGATION_SUM, NODE_AGGREGATION_AVG, NODE_AGGREGATION_NONE:
+			default:
+				return params, fmt.Errorf("node aggregation %s is not supported, valid aggregations are %s, %s, %s and %s", value, NODE_AGGREGATION_MAX, NODE_AGGREGATION_SUM, NODE_AGGREGATION_AVG, NODE_AGGREGATION_NONE)
+			}
+		case REDIS_PERIOD:
+			params.Period = cmsutil.ParsePeriod(value)
+		}
+	}
+	if params.InstanceId == "" {
+		return params, fmt.Errorf("%s must be provided", REDIS_INSTANCE_ID)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"testing"
+
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+)
+
+func TestGetRedisParams(t *testing.T) {
+	params, err := getRedisParams(testutil.ParseRequirements(t, "redis.instance.id=r-1,redis.architecture=Cluster,redis.statistic=maximum,redis.period=300"))
+	if err != nil {
+		t.Fatalf("failed to get redis params: %v", err)
+	}
+	if params.InstanceId != "r-1" || params.Architecture != ARCHITECTURE_CLUSTER || params.Statistic != "Maximum" || params.Period != 300 || params.NodeAggregation != NODE_AGGREGATION_MAX {
+		t.Fatalf("unexpected redis params: %+v", params)
+	}
+	if name := params.metricName(redisMetrics[REDIS_QPS]); name != "ShardingUsedQPS" {
+		t.Fatalf("unexpected metric name of cluster instance: %s", name)
+	}
+
+	if _, err := getRedisParams(testutil.ParseRequirements(t, "redis.node.id=r-1-db-0")); err == nil {
+		t.Fatalf("expected error for missing instance id")
+	}
+	if _, err := getRedisParams(testutil.ParseRequirements(t, "redis.instance.id=r-1,redis.architecture=proxy")); err == nil {
+		t.Fatalf("expected error for unsupported architecture")
+	}
+	if params, err := getRedisParams(testutil.ParseRequirements(t, "redis.instance.id=r-1,redis.node.aggregation=None")); err != nil || params.NodeAggregation != NODE_AGGREGATION_NONE {
+		t.Fatalf("unexpected node aggregation %+v: %v", params, err)
+	}
+	if _, err := getRedisParams(testutil.ParseRequirements(t, "redis.instance.id=r-1,redis.node.aggregation=min")); err == nil {
+		t.Fatalf("expected error for unsupported node aggregation")
+	}
+}
+
+func TestGetNodeSeries(t *testing.T) {
+	points, err := cmsutil.ParseDatapoints(`[
+		{"timestamp":1,"instanceId":"r-1","nodeId":"r-1-db-1","Average":30,"Maximum":40},
+		{"timestamp":1,"instanceId":"r-1","nodeId":"r-1-db-0","Average":10,"Maximum":20},
+		{"timestamp":2,"instanceId":"r-1","nodeId":"r-1-db-1","Average":50,"Maximum":90},
+		{"timestamp":2,"instanceId":"r-1","nodeId":"r-1-db-0","Average":15,"Maximum":25}
+	]`)
+	if err != nil {
+		t.Fatalf("failed to parse datapoints: %v", err)
+	}
+
+	series, err := getNodeSeries(points, cmsutil.STATISTIC_AVERAGE, cmsutil.AGGREGATION_LATEST, NODE_AGGREGATION_NONE)
+	if err != nil {
+		t.Fatalf("failed to get node series: %v", err)
+	}
+	if len(series) != 2 || series[0].nodeId != "r-1-db-0" || series[0].value != 15 || series[1].value != 50 {
+		t.Fatalf("unexpected node series: %+v", series)
+	}
+
+	series, err = getNodeSeries(points, cmsutil.STATISTIC_MAXIMUM, cmsutil.AGGREGATION_MAX, NODE_AGGREGATION_NONE)
+	if err != nil {
+		t.Fatalf("failed to get node series: %v", err)
+	}
+	if series[0].value != 25 || series[1].value != 90 {
+		t.Fatalf("unexpected max of node series: %+v", series)
+	}
+
+	// the latest values of nodes are 15 and 50
+	for nodeAggregation, expected := range map[string]float64{NODE_AGGREGATION_MAX: 50, NODE_AGGREGATION_SUM: 65, NODE_AGGREGATION_AVG: 32.5} {
+		series, err = getNodeSeries(points, cmsutil.STATISTIC_AVERAGE, cmsutil.AGGREGATION_LATEST, nodeAggregation)
+		if err != nil {
+			t.Fatalf("failed to get node series: %v", err)
+		}
+		if len(series) != 1 || series[0].nodeId != "" || series[0].value != expected {
+			t.Fatalf("unexpected %s of nodes: %+v", nodeAggregation, series)
+		}
+	}
+
+	if _, err := getNodeSeries(nil, cmsutil.STATISTIC_AVERAGE, cmsutil.AGGREGATION_LATEST, NODE_AGGREGATION_MAX); err == nil {
+		t.Fatalf("expected error for empty datapoints")
+	}
+}
@@ -0,0 +1,95 @@
+package rocketmq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ons"
+	log "k8s.io/klog/v2"
+)
+
+// onsClient is the part of RocketMQ(ons) OpenAPI used by the metric source, it's implemented
+// by *ons.Client and replaced by a fake client in tests.
+type onsClient interface {
+	OnsConsumerAccumulate(request *ons.OnsConsumerAccumulateRequest) (*ons.OnsConsumerAccumulateResponse, error)
+	OnsConsumerStatus(request *ons.OnsConsumerStatusRequest) (*ons.OnsConsumerStatusResponse, error)
+}
+
+// consumerStatus is the backlog and consumption of a group
+type consumerStatus struct {
+	// the count of ready messages
+	TotalDiff int64
+	// the delay(ms) of the earliest ready message
+	DelayTime int64
+	// only returned by OnsConsumerStatus
+	ConsumeTps float64
+}
+
+func (rs *RocketMQMetricSource) getClient(options utils.AccessOptions) (onsClient, error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(options)
+	if err != nil {
+		log.Errorf("Failed to create rocketmq client,because of %v", err)
+		return nil, err
+	}
+
+	c, err := rs.clients.Get(accessUserInfo.Region, accessUserInfo, func() (interface{}, error) {
+		if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
+			return ons.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
+		}
+		return ons.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*ons.Client), nil
+}
+
+// the backlog of the group, or of the topic subscribed by the group if the topic is provided
+func getAccumulate(client onsClient, params *RocketMQParams) (*consumerStatus, error) {
+	request := ons.CreateOnsConsumerAccumulateRequest()
+	request.Scheme = "https"
+	request.InstanceId = params.InstanceId
+	request.GroupId = params.GroupId
+	request.Detail = requests.NewBoolean(params.Topic != "")
+
+	response, err := client.OnsConsumerAccumulate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Topic == "" {
+		return &consumerStatus{
+			TotalDiff: response.Data.TotalDiff,
+			DelayTime: response.Data.DelayTime,
+		}, nil
+	}
+	for _, detail := range response.Data.DetailInTopicList.DetailInTopicDo {
+		if detail.Topic == params.Topic {
+			return &consumerStatus{
+				TotalDiff: detail.TotalDiff,
+				DelayTime: detail.DelayTime,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("topic %s is not subscribed by group %s", params.Topic, params.GroupId)
+}
+
+// the consumption tps of the group
+func getConsumerStatus(client onsClient, params *RocketMQParams) (*consumerStatus, error) {
+	request := ons.CreateOnsConsumerStatusRequest()
+	request.Scheme = "https"
+	request.InstanceId = params.InstanceId
+	request.GroupId = params.GroupId
+
+	response, err := client.OnsConsumerStatus(request)
+	if err != nil {
+		return nil, err
+	}
+	return &consumerStatus{
+		TotalDiff:  response.Data.TotalDiff,
+		DelayTime:  response.Data.DelayTime,
+		ConsumeTps: response.Data.ConsumeTps,
+	}, nil
+}
@@ -0,0 +1,182 @@
+package rocketmq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+const (
+	ROCKETMQ_READY_MESSAGES   = "rocketmq_ready_messages"
+	ROCKETMQ_DELIVERY_LATENCY = "rocketmq_delivery_latency"
+	ROCKETMQ_CONSUME_TPS      = "rocketmq_consume_tps"
+
+	//Global Params
+	ROCKETMQ_INSTANCE_ID = "rocketmq.instance.id"
+	ROCKETMQ_GROUP_ID    = "rocketmq.group.id"
+	ROCKETMQ_TOPIC       = "rocketmq.topic"
+
+	// the backlog is counted by the broker in real time, cache it shortly to protect the api quota
+	CACHE_TTL = 15 * time.Second
+)
+
+// rocketMQMetric reads the value from the consumer status of a group
+type rocketMQMetric struct {
+	// the consumption tps is only returned by OnsConsumerStatus, which doesn't support topics
+	consumeTps bool
+	value      func(status *consumerStatus) float64
+	unit       utils.Unit
+}
+
+var rocketMQMetrics = map[string]rocketMQMetric{
+	ROCKETMQ_READY_MESSAGES: {
+		value: func(status *consumerStatus) float64 { return float64(status.TotalDiff) },
+		unit:  utils.Unit{Name: utils.UNIT_COUNT},
+	},
+	ROCKETMQ_DELIVERY_LATENCY: {
+		value: func(status *consumerStatus) float64 { return float64(status.DelayTime) },
+		unit:  utils.Unit{Name: utils.UNIT_MILLISECONDS},
+	},
+	ROCKETMQ_CONSUME_TPS: {
+		consumeTps: true,
+		value:      func(status *consumerStatus) float64 { return status.ConsumeTps },
+		unit:       utils.Unit{Name: utils.UNIT_COUNT_PER_SECOND},
+	},
+}
+
+// RocketMQMetricSource serves the backlog and consumption of RocketMQ groups from the ons api.
+type RocketMQMetricSource struct {
+	clients utils.ClientCache
+	// returns the ons client, replaced in tests
+	client func(options utils.AccessOptions) (onsClient, error)
+}
+
+func NewRocketMQMetricSource() *RocketMQMetricSource {
+	rs := &RocketMQMetricSource{}
+	rs.client = rs.getClient
+	return rs
+}
+
+// list all external metric
+func (rs *RocketMQMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
+	metricInfoList := make([]p.ExternalMetricInfo, 0)
+	for metric := range rocketMQMetrics {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: metric,
+		})
+	}
+	return metricInfoList
+}
+
+// according to the incoming label, get the metric..
+func (rs *RocketMQMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	metric, ok := rocketMQMetrics[info.Metric]
+	if !ok {
+		return values, fmt.Errorf("metric %s is not supported by rocketmq", info.Metric)
+	}
+	values, err = rs.getRocketMQMetrics(namespace, info.Metric, metric, requirements)
+	if err != nil {
+		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
+	}
+	return values, err
+}
+
+// the backlog is read from the ons api instead of cms, so it's cached shorter than the cms sources
+func (rs *RocketMQMetricSource) CacheTTL() time.Duration {
+	return CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (rs *RocketMQMetricSource) MetricUnits() map[string]utils.Unit {
+	units := make(map[string]utils.Unit)
+	for name, metric := range rocketMQMetrics {
+		units[name] = metric.unit
+	}
+	return units
+}
+
+type RocketMQParams struct {
+	InstanceId string
+	GroupId    string
+	Topic      string
+}
+
+// get the rocketmq specific metric values
+func (rs *RocketMQMetricSource) getRocketMQMetrics(namespace, externalMetric string, metric rocketMQMetric, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	params, err := getRocketMQParams(requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get rocketmq params,because of %v", err)
+	}
+	if err := validateRocketMQParams(params, metric); err != nil {
+		return values, err
+	}
+
+	client, err := rs.client(utils.GetAccessOptions(namespace, requirements))
+	if err != nil {
+		return values, err
+	}
+
+	var status *consumerStatus
+	if metric.consumeTps {
+		status, err = getConsumerStatus(client, params)
+	} else {
+		status, err = getAccumulate(client, params)
+	}
+	if err != nil {
+		return values, err
+	}
+
+	value, err := utils.ConvertValue(metric.value(status), metric.unit)
+	if err != nil {
+		return values, err
+	}
+
+	values = append(values, external_metrics.ExternalMetricValue{
+		MetricName: externalMetric,
+		Value:      *value,
+		Timestamp:  metav1.Now(),
+	})
+	return values, nil
+}
+
+// get the rocketmq Params
+func getRocketMQParams(requirements labels.Requirements) (params *RocketMQParams, err error) {
+	params = &RocketMQParams{}
+	for _, r := range requirements {
+
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+
+		value := r.Values().List()[0]
+
+		switch r.Key() {
+		case ROCKETMQ_INSTANCE_ID:
+			params.InstanceId = value
+		case ROCKETMQ_GROUP_ID:
+			params.GroupId = value
+		case ROCKETMQ_TOPIC:
+			params.Topic = value
+		}
+	}
+	return params, nil
+}
+
+func validateRocketMQParams(params *RocketMQParams, metric rocketMQMetric) error {
+	if params.InstanceId == "" {
+		return fmt.Errorf("%s must be provided", ROCKETMQ_INSTANCE_ID)
+	}
+	if params.GroupId == "" {
+		return fmt.Errorf("%s must be provided", ROCKETMQ_GROUP_ID)
+	}
+	if metric.consumeTps && params.Topic != "" {
+		return fmt.Errorf("%s is not supported by %s", ROCKETMQ_TOPIC, ROCKETMQ_CONSUME_TPS)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package rocketmq
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ons"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+)
+
+// fakeClient returns the consumer status of group GID_orders in instance MQ_INST_1
+type fakeClient struct {
+	accumulateRequests int
+	statusRequests     int
+}
+
+func (c *fakeClient) OnsConsumerAccumulate(request *ons.OnsConsumerAccumulateRequest) (*ons.OnsConsumerAccumulateResponse, error) {
+	c.accumulateRequests++
+	if request.InstanceId != "MQ_INST_1" || request.GroupId != "GID_orders" {
+		return nil, fmt.Errorf("group %s not found", request.GroupId)
+	}
+	response := ons.CreateOnsConsumerAccumulateResponse()
+	response.Data.TotalDiff = 1200
+	response.Data.DelayTime = 3500
+	if request.Detail == "true" {
+		response.Data.DetailInTopicList.DetailInTopicDo = []ons.DetailInTopicDo{
+			{Topic: "order", TotalDiff: 1000, DelayTime: 3500},
+			{Topic: "refund", TotalDiff: 200, DelayTime: 800},
+		}
+	}
+	return response, nil
+}
+
+func (c *fakeClient) OnsConsumerStatus(request *ons.OnsConsumerStatusRequest) (*ons.OnsConsumerStatusResponse, error) {
+	c.statusRequests++
+	response := ons.CreateOnsConsumerStatusResponse()
+	response.Data.ConsumeTps = 12.5
+	return response, nil
+}
+
+func newFakeSource(client *fakeClient) *RocketMQMetricSource {
+	return &RocketMQMetricSource{
+		client: func(options utils.AccessOptions) (onsClient, error) {
+			return client, nil
+		},
+	}
+}
+
+func TestGetRocketMQMetrics(t *testing.T) {
+	client := &fakeClient{}
+	rs := newFakeSource(client)
+
+	cases := []struct {
+		metric   string
+		selector string
+		expected string
+	}{
+		{ROCKETMQ_READY_MESSAGES, "rocketmq.instance.id=MQ_INST_1,rocketmq.group.id=GID_orders", "1200"},
+		{ROCKETMQ_READY_MESSAGES, "rocketmq.instance.id=MQ_INST_1,rocketmq.group.id=GID_orders,rocketmq.topic=refund", "200"},
+		{ROCKETMQ_DELIVERY_LATENCY, "rocketmq.instance.id=MQ_INST_1,rocketmq.group.id=GID_orders,rocketmq.topic=order", "3500"},
+		{ROCKETMQ_CONSUME_TPS, "rocketmq.instance.id=MQ_INST_1,rocketmq.group.id=GID_orders", "12500m"},
+	}
+	for _, c := range cases {
+		values, err := rs.GetExternalMetric(p.ExternalMetricInfo{Metric: c.metric}, "default", testutil.ParseRequirements(t, c.selector))
+		if err != nil {
+			t.Fatalf("failed to get %s of %s: %v", c.metric, c.selector, err)
+		}
+		if len(values) != 1 || values[0].Value.String() != c.expected {
+			t.Fatalf("unexpected %s of %s: %v", c.metric, c.selector, values)
+		}
+	}
+	if client.accumulateRequests != 3 || client.statusRequests != 1 {
+		t.Fatalf("unexpected requests: %+v", client)
+	}
+}
+
+func TestGetRocketMQMetricsWithInvalidParams(t *testing.T) {
+	client := &fakeClient{}
+	rs := newFakeSource(client)
+
+	cases := []struct {
+		metric   string
+		selector string
+	}{
+		{ROCKETMQ_READY_MESSAGES, "rocketmq.group.id=GID_orders"},
+		{ROCKETMQ_READY_MESSAGES, "rocketmq.instance.id=MQ_INST_1"},
+		{ROCKETMQ_CONSUME_TPS, "rocketmq.instance.id=MQ_INST_1,rocketmq.group.id=GID_orders,rocketmq.topic=order"},
+		{ROCKETMQ_READY_MESSAGES, "rocketmq.instance.id=MQ_INST_1,rocketmq.group.id=GID_orders,rocketmq.topic=unknown"},
+		{ROCKETMQ_READY_MESSAGES, "rocketmq.instance.id=MQ_INST_1,rocketmq.group.id=GID_unknown"},
+	}
+	for _, c := range cases {
+		if _, err := rs.GetExternalMetric(p.ExternalMetricInfo{Metric: c.metric}, "default", testutil.ParseRequirements(t, c.selector)); err == nil {
+			t.Fatalf("expected error of %s with %s", c.metric, c.selector)
+		}
+	}
+	// the params are validated before calling the api
+	if client.accumulateRequests != 2 || client.statusRequests != 0 {
+		t.Fatalf("unexpected requests: %+v", client)
+	}
+}
@@ -0,0 +1,111 @@
+package slb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+	v1 "k8s.io/api/core/v1"
+	log "k8s.io/klog/v2"
+)
+
+// fill the SLB instance and port of params with the Service
+func (sb *SLBMetricSource) resolveService(params *SLBParams) error {
+	svc, err := utils.GetServiceLister().Get(params.ServiceNamespace, params.ServiceName)
+	if err != nil {
+		return err
+	}
+	return resolveSLBParams(svc, params, func(address string) (string, error) {
+		return sb.getInstanceIdByAddress(params.AccessOptions, address)
+	})
+}
+
+// the params provided explicitly are not overridden, the instance is looked up by address
+// if CCM doesn't record it on the Service.
+func resolveSLBParams(svc *v1.Service, params *SLBParams, lookup func(address string) (string, error)) error {
+	if params.InstanceId == "" {
+		params.InstanceId = utils.GetLoadBalancerId(svc)
+	}
+	if params.InstanceId == "" {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP == "" {
+				continue
+			}
+			instanceId, err := lookup(ingress.IP)
+			if err != nil {
+				return err
+			}
+			params.InstanceId = instanceId
+			break
+		}
+	}
+	if params.InstanceId == "" {
+		return fmt.Errorf("SLB instance of service %s/%s is not found", svc.Namespace, svc.Name)
+	}
+
+	if params.Port == "" {
+		port, err := utils.GetServicePort(svc, params.ServicePort)
+		if err != nil {
+			return err
+		}
+		params.Port = strconv.Itoa(int(port.Port))
+	}
+	return nil
+}
+
+// the address of SLB instance never changes, so the mapping is cached forever.
+// The same private address may be used in other regions or accounts, so it's cached with the options.
+func (sb *SLBMetricSource) getInstanceIdByAddress(options utils.AccessOptions, address string) (string, error) {
+	key := options.Profile + "@" + options.Region + "/" + address
+	sb.lock.RLock()
+	instanceId, ok := sb.instances[key]
+	sb.lock.RUnlock()
+	if ok {
+		return instanceId, nil
+	}
+
+	client, err := sb.slbClient(options)
+	if err != nil {
+		return "", err
+	}
+	request := slb.CreateDescribeLoadBalancersRequest()
+	request.Scheme = "https"
+	request.Address = address
+	response, err := client.DescribeLoadBalancers(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe load balancer of %s,because of %v", address, err)
+	}
+	lbs := response.LoadBalancers.LoadBalancer
+	if len(lbs) == 0 {
+		return "", fmt.Errorf("SLB instance of address %s is not found", address)
+	}
+	instanceId = lbs[0].LoadBalancerId
+
+	sb.lock.Lock()
+	sb.instances[key] = instanceId
+	sb.lock.Unlock()
+	log.Infof("Resolved SLB instance %s of address %s", instanceId, address)
+	return instanceId, nil
+}
+
+// the client of slb openapi, which shares the ClientCache with cms client by a different key
+func (sb *SLBMetricSource) slbClient(options utils.AccessOptions) (*slb.Client, error) {
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(options)
+	if err != nil {
+		log.Errorf("Failed to create slb client,because of %v", err)
+		return nil, err
+	}
+
+	c, err := sb.clients.Get("slb/"+accessUserInfo.Region, accessUserInfo, func() (interface{}, error) {
+		if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
+			return slb.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
+		}
+		return slb.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*slb.Client), nil
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 
+	cmsutil "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/cms"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
 
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/cms"
@@ -13,10 +13,11 @@ import (
 	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
 
 	"strconv"
+	"sync"
 	"time"
 
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	log "k8s.io/klog/v2"
 	"k8s.io/metrics/pkg/apis/external_metrics"
 )
@@ -43,11 +44,40 @@ const (
 	SLB_INSTANCE_ID = "slb.instance.id"
 	SLB_PORT        = "slb.instance.port"
 	SLB_PERIOD      = "slb.period"
+	SLB_STATISTIC   = "slb.statistic"
+	// aggregate the statistic over the period instead of using the latest datapoint
+	SLB_AGGREGATION = "slb.aggregation"
 
 	MIN_PERIOD = 60
 )
 
-type SLBMetricSource struct{}
+// units of the raw values of slb metrics
+var slbUnits = map[string]utils.Unit{
+	SLB_L4_TRAFFIC_RX:             {Name: utils.UNIT_BITS_PER_SECOND},
+	SLB_L4_TRAFFIC_TX:             {Name: utils.UNIT_BITS_PER_SECOND},
+	SLB_L4_PACKET_TX:              {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L4_PACKET_RX:              {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L4_ACTIVE_CONNECTION:      {Name: utils.UNIT_COUNT},
+	SLB_L4_MAX_CONNECTION:         {Name: utils.UNIT_COUNT},
+	SLB_L4_CONNECTION_UTILIZATION: {Name: utils.UNIT_PERCENT},
+	SLB_L7_QPS:                    {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L7_RT:                     {Name: utils.UNIT_MILLISECONDS},
+	SLB_L7_STATUS_2XX:             {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L7_STATUS_3XX:             {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L7_STATUS_4XX:             {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L7_STATUS_5XX:             {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L7_UPSTREAM_4XX:           {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L7_UPSTREAM_5XX:           {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLB_L7_UPSTREAM_RT:            {Name: utils.UNIT_MILLISECONDS},
+}
+
+type SLBMetricSource struct {
+	clients utils.ClientCache
+
+	lock sync.RWMutex
+	// the address of load balancer -> instance id
+	instances map[string]string
+}
 
 //list all external metric
 func (sb *SLBMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
@@ -120,23 +150,24 @@ func (sb *SLBMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespac
 	return values, err
 }
 
-//the client of slb
-func (sb *SLBMetricSource) Client() (client *cms.Client, err error) {
-
-	accessUserInfo, err := utils.GetAccessUserInfo()
-	if err != nil {
-		log.Errorf("Failed to get accessUserInfo,because of %v.", err)
-		return nil, err
-	}
+// the SLB listener datapoints are aggregated per minute, so the responses are reused in cmsutil.CACHE_TTL
+func (sb *SLBMetricSource) CacheTTL() time.Duration {
+	return cmsutil.CACHE_TTL
+}
 
-	if strings.HasPrefix(accessUserInfo.AccessKeyId, "STS.") {
-		client, err = cms.NewClientWithStsToken(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
-	} else {
-		client, err = cms.NewClientWithAccessKey(accessUserInfo.Region, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret)
+// MetricUnits describes the units of metric values
+func (sb *SLBMetricSource) MetricUnits() map[string]utils.Unit {
+	return slbUnits
+}
 
-	}
-	return client, err
+// RunUntil watches Services to resolve SLB instances.
+func (sb *SLBMetricSource) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	utils.GetServiceLister().Run(kubeClient, stopCh)
+}
 
+//the client of slb
+func (sb *SLBMetricSource) Client(options utils.AccessOptions) (client *cms.Client, err error) {
+	return cmsutil.NewClient(&sb.clients, options)
 }
 
 // Global params
@@ -147,24 +178,39 @@ type SLBGlobalParams struct {
 
 //
 func NewSLBMetricSource() *SLBMetricSource {
-	return &SLBMetricSource{}
+	return &SLBMetricSource{
+		instances: make(map[string]string),
+	}
 }
 
 type SLBParams struct {
 	SLBGlobalParams
-	Period int
+	utils.AccessOptions
+	Period           int
+	Statistic        string
+	Aggregation      string
+	ServiceName      string
+	ServiceNamespace string
+	ServicePort      string
 }
 
 //get the slb specific metric values
 func (sms *SLBMetricSource) getSLBMetrics(namespace, metric, externalMetric string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
-	namespace = "acs_slb_dashboard"
-
-	params, err := getSLBParams(requirements)
+	params, err := getSLBParams(namespace, requirements)
 	if err != nil {
 		return values, fmt.Errorf("failed to get slb params,because of %v", err)
 	}
 
-	client, err := sms.Client()
+	if params.InstanceId == "" || params.Port == "" {
+		if params.ServiceNamespace == "" {
+			params.ServiceNamespace = namespace
+		}
+		if err := sms.resolveService(params); err != nil {
+			return values, err
+		}
+	}
+
+	client, err := sms.Client(params.AccessOptions)
 	if err != nil {
 		log.Errorf("Failed to create slb client,because of %v", err)
 		return values, err
@@ -172,7 +218,7 @@ func (sms *SLBMetricSource) getSLBMetrics(namespace, metric, externalMetric stri
 
 	request := cms.CreateDescribeMetricListRequest()
 	request.Scheme = "https"
-	request.Namespace = namespace
+	request.Namespace = "acs_slb_dashboard"
 	request.MetricName = metric
 
 	//time range
@@ -199,14 +245,18 @@ func (sms *SLBMetricSource) getSLBMetrics(namespace, metric, externalMetric stri
 		return values, err
 	}
 
-	metricValue, err := getMetricFromDataPoints(response.Datapoints)
+	metricValue, err := getMetricFromDataPoints(response.Datapoints, params.Statistic, params.Aggregation)
 	if err != nil {
 		log.Errorf("Failed to get slb metrics from api,because of %v", err)
 		return values, err
 	}
+	value, err := utils.ConvertValue(metricValue, slbUnits[externalMetric])
+	if err != nil {
+		return values, err
+	}
 	values = append(values, external_metrics.ExternalMetricValue{
 		MetricName: externalMetric,
-		Value:      *resource.NewQuantity(int64(metricValue), resource.DecimalSI),
+		Value:      *value,
 		Timestamp:  metav1.Now(),
 	})
 	return values, nil
@@ -229,9 +279,12 @@ func createDimensions(instanceId, port string) (string, error) {
 }
 
 //get the slb Params
-func getSLBParams(requirements labels.Requirements) (params *SLBParams, err error) {
+func getSLBParams(namespace string, requirements labels.Requirements) (params *SLBParams, err error) {
 	params = &SLBParams{
-		Period: MIN_PERIOD,
+		AccessOptions: utils.GetAccessOptions(namespace, requirements),
+		Period:        MIN_PERIOD,
+		Statistic:     cmsutil.STATISTIC_AVERAGE,
+		Aggregation:   cmsutil.AGGREGATION_LATEST,
 	}
 	for _, r := range requirements {
 
@@ -246,6 +299,20 @@ func getSLBParams(requirements labels.Requirements) (params *SLBParams, err erro
 			params.InstanceId = value
 		case SLB_PORT:
 			params.Port = value
+		case utils.K8S_SERVICE_NAME:
+			params.ServiceName = value
+		case utils.K8S_SERVICE_NAMESPACE:
+			params.ServiceNamespace = value
+		case utils.K8S_SERVICE_PORT:
+			params.ServicePort = value
+		case SLB_STATISTIC:
+			if params.Statistic, err = cmsutil.ParseStatistic(value); err != nil {
+				return params, err
+			}
+		case SLB_AGGREGATION:
+			if params.Aggregation, err = cmsutil.ParseAggregation(value); err != nil {
+				return params, err
+			}
 		case SLB_PERIOD:
 			if params.Period, err = strconv.Atoi(value); err != nil {
 				log.Errorf("Failed to parse period and skip,because of %v", err)
@@ -253,8 +320,8 @@ func getSLBParams(requirements labels.Requirements) (params *SLBParams, err erro
 			}
 		}
 	}
-	if params.InstanceId == "" || params.Port == "" {
-		return params, errors.New("InstanceId and Port must be provide")
+	if (params.InstanceId == "" || params.Port == "") && params.ServiceName == "" {
+		return params, errors.New("InstanceId and Port or Service must be provide")
 	}
 
 	if params.Period < MIN_PERIOD {
@@ -265,27 +332,16 @@ func getSLBParams(requirements labels.Requirements) (params *SLBParams, err erro
 	return params, nil
 }
 
-type DataPoint struct {
-	Timestamp int64   `json:"timestamp"`
-	Vip       string  `json:"vip,omitempty"`
-	Average   float64 `json:"Average"`
-	Minimum   float64 `json:"Minimum"`
-	Maximum   float64 `json:"Maximum"`
-}
-
 // extract metric data points
-func getMetricFromDataPoints(datapoints string) (value float64, err error) {
+func getMetricFromDataPoints(datapoints, statistic, aggregation string) (value float64, err error) {
 	if datapoints == "" {
 		return 0, errors.New("NoMetricData")
 	}
 
-	points := make([]DataPoint, 0)
-
-	err = json.Unmarshal([]byte(datapoints), &points)
-
-	if err != nil || len(points) == 0 {
+	points, err := cmsutil.ParseDatapoints(datapoints)
+	if err != nil {
 		return 0, err
 	}
 
-	return points[len(points)-1].Average, nil
+	return cmsutil.Aggregate(points, statistic, aggregation)
 }
@@ -1,13 +1,17 @@
 package slb
 
 import (
-	"k8s.io/apimachinery/pkg/labels"
+	"fmt"
 	"testing"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 func TestInvalidGetSLBParams(t *testing.T) {
 	r := make([]labels.Requirement, 0)
-	_, e := getSLBParams(r)
+	_, e := getSLBParams("default", r)
 	if e != nil {
 		t.Log("pass TestInvalidGetSLBParams")
 		return
@@ -22,7 +26,7 @@ func TestValidGetSLBParams(t *testing.T) {
 		t.Fatalf("new requirement err: %v", e)
 	}
 	r = append(r, *requirement)
-	_, e = getSLBParams(r)
+	_, e = getSLBParams("default", r)
 	if e == nil {
 		t.Logf("Pass TstValidGetSLBParams")
 	}
@@ -35,3 +39,83 @@ func TestGetExternalMetricInfoList(t *testing.T) {
 		t.Logf("slb External Metric-Info-List include: %v", info)
 	}
 }
+
+func TestGetSLBParamsWithService(t *testing.T) {
+	requirement, e := labels.NewRequirement("k8s.service.name", "=", []string{"nginx"})
+	if e != nil {
+		t.Fatalf("new requirement err: %v", e)
+	}
+	params, e := getSLBParams("default", []labels.Requirement{*requirement})
+	if e != nil {
+		t.Fatalf("service should be accepted instead of instance and port: %v", e)
+	}
+	if params.ServiceName != "nginx" {
+		t.Fatalf("unexpected slb params: %+v", params)
+	}
+}
+
+func TestResolveSLBParams(t *testing.T) {
+	svc := &v1.Service{}
+	svc.Namespace = "default"
+	svc.Name = "nginx"
+	svc.Spec.Ports = []v1.ServicePort{{Name: "http", Port: 80}, {Name: "https", Port: 443}}
+	svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "47.0.0.1"}}
+
+	lookups := 0
+	lookup := func(address string) (string, error) {
+		lookups++
+		if address != "47.0.0.1" {
+			return "", fmt.Errorf("unexpected address %s", address)
+		}
+		return "lb-by-address", nil
+	}
+
+	params := &SLBParams{ServicePort: "https"}
+	if err := resolveSLBParams(svc, params, lookup); err != nil {
+		t.Fatalf("failed to resolve service: %v", err)
+	}
+	if params.InstanceId != "lb-by-address" || params.Port != "443" || lookups != 1 {
+		t.Fatalf("unexpected params resolved by address: %+v", params)
+	}
+
+	svc.Labels = map[string]string{utils.LOADBALANCER_ID_LABEL: "lb-by-label"}
+	params = &SLBParams{ServicePort: "80"}
+	if err := resolveSLBParams(svc, params, lookup); err != nil {
+		t.Fatalf("failed to resolve service: %v", err)
+	}
+	if params.InstanceId != "lb-by-label" || params.Port != "80" || lookups != 1 {
+		t.Fatalf("unexpected params resolved by label: %+v", params)
+	}
+
+	if err := resolveSLBParams(svc, &SLBParams{}, lookup); err == nil {
+		t.Fatalf("expect error without port of multi-port service")
+	}
+}
+
+func TestGetSLBParamsWithStatistic(t *testing.T) {
+	r := make([]labels.Requirement, 0)
+	for k, v := range map[string]string{
+		SLB_INSTANCE_ID: "lb-1",
+		SLB_PORT:        "80",
+		SLB_STATISTIC:   "maximum",
+		SLB_AGGREGATION: "max",
+	} {
+		requirement, e := labels.NewRequirement(k, "=", []string{v})
+		if e != nil {
+			t.Fatalf("new requirement err: %v", e)
+		}
+		r = append(r, *requirement)
+	}
+	params, e := getSLBParams("default", r)
+	if e != nil {
+		t.Fatalf("failed to get slb params: %v", e)
+	}
+	if params.Statistic != "Maximum" || params.Aggregation != "max" {
+		t.Fatalf("unexpected slb params: %+v", params)
+	}
+
+	value, e := getMetricFromDataPoints(`[{"Average":1,"Maximum":5},{"Average":2,"Maximum":3}]`, params.Statistic, params.Aggregation)
+	if e != nil || value != 5 {
+		t.Fatalf("unexpected peak value: %v, %v", value, e)
+	}
+}
@@ -0,0 +1,112 @@
+package sls
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	slssdk "github.com/aliyun/aliyun-log-go-sdk"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// the behavior of query without any log, zero by default
+	EMPTY_RESULT_ZERO  = "zero"
+	EMPTY_RESULT_ERROR = "error"
+
+	// the backoff of retries is doubled from INITIAL_BACKOFF to MAX_BACKOFF
+	INITIAL_BACKOFF = 500 * time.Millisecond
+	MAX_BACKOFF     = 4 * time.Second
+
+	// the suggested seconds for the client to retry if the quota of sls is exceeded
+	QUOTA_RETRY_SECONDS = 15
+)
+
+// slsExecutor runs the queries of sls, retries the incomplete results and the server errors with backoff.
+type slsExecutor struct {
+	client  slssdk.ClientInterface
+	backoff time.Duration
+}
+
+func newSLSExecutor(client slssdk.ClientInterface) *slsExecutor {
+	return &slsExecutor{
+		client:  client,
+		backoff: INITIAL_BACKOFF,
+	}
+}
+
+// query the logs in [begin, end) and parse every returned row
+func (e *slsExecutor) query(params *SLSGlobalParams, begin, end int64, query string) ([]slsValue, error) {
+	var lastErr error
+	backoff := e.backoff
+	for i := 0; i < params.MaxRetry; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > MAX_BACKOFF {
+				backoff = MAX_BACKOFF
+			}
+		}
+
+		queryRsp, err := e.client.GetLogs(params.Project, params.LogStore, "", begin, end, query, 100, 0, false)
+		if err != nil {
+			if retryable(err) {
+				lastErr = err
+				log.Warningf("Failed to query sls %s/%s(attempt %d),because of %v", params.Project, params.LogStore, i+1, err)
+				continue
+			}
+			return nil, toAPIError(params, err)
+		}
+
+		// if there are too many logs in sls, query may be not completed, we should retry
+		if !queryRsp.IsComplete() {
+			lastErr = nil
+			log.Warningf("The query of sls %s/%s is incomplete(attempt %d)", params.Project, params.LogStore, i+1)
+			continue
+		}
+
+		if len(queryRsp.Logs) == 0 {
+			if params.EmptyResult == EMPTY_RESULT_ERROR {
+				return nil, apierr.NewNotFound(schema.GroupResource{Group: "sls", Resource: "logs"}, fmt.Sprintf("%s/%s", params.Project, params.LogStore))
+			}
+			return []slsValue{{labels: map[string]string{}, value: 0}}, nil
+		}
+		return parseLogs(queryRsp.Logs)
+	}
+	if lastErr != nil {
+		return nil, toAPIError(params, lastErr)
+	}
+	return nil, apierr.NewServiceUnavailable(fmt.Sprintf("the query of sls %s/%s is not completed after %d attempts, it might because of too many logs", params.Project, params.LogStore, params.MaxRetry))
+}
+
+// the errors of network and sls server are retried
+func retryable(err error) bool {
+	slsErr, ok := err.(*slssdk.Error)
+	if !ok {
+		return true
+	}
+	return slsErr.HTTPCode <= 0 || slsErr.HTTPCode >= http.StatusInternalServerError
+}
+
+// toAPIError converts the error codes of sls to the errors of kubernetes api
+func toAPIError(params *SLSGlobalParams, err error) error {
+	slsErr, ok := err.(*slssdk.Error)
+	if !ok {
+		return apierr.NewInternalError(err)
+	}
+	switch {
+	case slsErr.Code == "ProjectNotExist":
+		return apierr.NewNotFound(schema.GroupResource{Group: "sls", Resource: "projects"}, params.Project)
+	case slsErr.Code == "LogStoreNotExist":
+		return apierr.NewNotFound(schema.GroupResource{Group: "sls", Resource: "logstores"}, params.LogStore)
+	case strings.Contains(slsErr.Code, "Quota") || slsErr.HTTPCode == http.StatusTooManyRequests:
+		return apierr.NewTooManyRequests(fmt.Sprintf("quota of sls is exceeded: %s", slsErr.Message), QUOTA_RETRY_SECONDS)
+	case slsErr.HTTPCode == http.StatusUnauthorized || slsErr.HTTPCode == http.StatusForbidden:
+		return apierr.NewForbidden(schema.GroupResource{Group: "sls", Resource: "logstores"}, params.LogStore, fmt.Errorf("%s: %s", slsErr.Code, slsErr.Message))
+	case slsErr.HTTPCode == http.StatusBadRequest:
+		return apierr.NewBadRequest(fmt.Sprintf("invalid query of sls, %s: %s", slsErr.Code, slsErr.Message))
+	}
+	return apierr.NewInternalError(err)
+}
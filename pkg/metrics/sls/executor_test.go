@@ -0,0 +1,92 @@
+package sls
+
+import (
+	"testing"
+
+	slssdk "github.com/aliyun/aliyun-log-go-sdk"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// fakeClient returns the responses in order, the other methods of ClientInterface are not implemented
+type fakeClient struct {
+	slssdk.ClientInterface
+	responses []*slssdk.GetLogsResponse
+	errors    []error
+	requests  int
+}
+
+func (c *fakeClient) GetLogs(project, logstore string, topic string, from int64, to int64, queryExp string,
+	maxLineNum int64, offset int64, reverse bool) (*slssdk.GetLogsResponse, error) {
+	i := c.requests
+	c.requests++
+	if i < len(c.errors) && c.errors[i] != nil {
+		return nil, c.errors[i]
+	}
+	return c.responses[i], nil
+}
+
+func newFakeExecutor(client *fakeClient) *slsExecutor {
+	return &slsExecutor{client: client}
+}
+
+func TestExecutorRetry(t *testing.T) {
+	params := &SLSGlobalParams{Project: "p", LogStore: "l", MaxRetry: 3, EmptyResult: EMPTY_RESULT_ZERO}
+	client := &fakeClient{
+		errors: []error{&slssdk.Error{HTTPCode: 500, Code: "InternalServerError"}},
+		responses: []*slssdk.GetLogsResponse{
+			nil,
+			{Progress: "Incomplete", Logs: []map[string]string{{"value": "1"}}},
+			{Progress: "Complete", Logs: []map[string]string{{"value": "2"}}},
+		},
+	}
+	rows, err := newFakeExecutor(client).query(params, 0, 60, "*")
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	if client.requests != 3 || len(rows) != 1 || rows[0].value != 2 {
+		t.Fatalf("unexpected rows %+v after %d requests", rows, client.requests)
+	}
+
+	// the incomplete result is not returned
+	client = &fakeClient{responses: []*slssdk.GetLogsResponse{{Progress: "Incomplete"}, {Progress: "Incomplete"}, {Progress: "Incomplete"}}}
+	if _, err := newFakeExecutor(client).query(params, 0, 60, "*"); !apierr.IsServiceUnavailable(err) {
+		t.Fatalf("expected service unavailable for incomplete result, got %v", err)
+	}
+}
+
+func TestExecutorEmptyResult(t *testing.T) {
+	params := &SLSGlobalParams{Project: "p", LogStore: "l", MaxRetry: 1, EmptyResult: EMPTY_RESULT_ZERO}
+	client := &fakeClient{responses: []*slssdk.GetLogsResponse{{Progress: "Complete"}, {Progress: "Complete"}}}
+	rows, err := newFakeExecutor(client).query(params, 0, 60, "*")
+	if err != nil || len(rows) != 1 || rows[0].value != 0 {
+		t.Fatalf("expected zero for empty result: %+v, %v", rows, err)
+	}
+
+	params.EmptyResult = EMPTY_RESULT_ERROR
+	if _, err := newFakeExecutor(client).query(params, 0, 60, "*"); !apierr.IsNotFound(err) {
+		t.Fatalf("expected not found for empty result, got %v", err)
+	}
+}
+
+func TestExecutorErrors(t *testing.T) {
+	params := &SLSGlobalParams{Project: "p", LogStore: "l", MaxRetry: 3}
+	cases := []struct {
+		err   error
+		check func(error) bool
+	}{
+		{&slssdk.Error{HTTPCode: 404, Code: "ProjectNotExist"}, apierr.IsNotFound},
+		{&slssdk.Error{HTTPCode: 403, Code: "ReadQuotaExceed"}, apierr.IsTooManyRequests},
+		{&slssdk.Error{HTTPCode: 401, Code: "Unauthorized"}, apierr.IsForbidden},
+		{&slssdk.Error{HTTPCode: 400, Code: "ParameterInvalid"}, apierr.IsBadRequest},
+	}
+	for _, c := range cases {
+		client := &fakeClient{errors: []error{c.err}}
+		if _, err := newFakeExecutor(client).query(params, 0, 60, "*"); !c.check(err) {
+			t.Fatalf("unexpected error of %v: %v", c.err, err)
+		}
+		// the client errors are not retried
+		if client.requests != 1 {
+			t.Fatalf("unexpected %d requests for %v", client.requests, c.err)
+		}
+	}
+}
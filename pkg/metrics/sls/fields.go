@@ -0,0 +1,105 @@
+package sls
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// the formats of ingress access logs
+	INGRESS_FORMAT_NGINX = "nginx"
+	INGRESS_FORMAT_ALB   = "alb"
+
+	// the keys of customized fields, e.g. sls.ingress.field.status
+	FIELD_ROUTE                  = "route"
+	FIELD_STATUS                 = "status"
+	FIELD_UPSTREAM_STATUS        = "upstream_status"
+	FIELD_REQUEST_TIME           = "request_time"
+	FIELD_UPSTREAM_RESPONSE_TIME = "upstream_response_time"
+	FIELD_REQUEST_LENGTH         = "request_length"
+	FIELD_BODY_BYTES_SENT        = "body_bytes_sent"
+)
+
+// the field names are rendered into the query, so only the plain identifiers are allowed
+var validField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ingressFields are the field names of ingress access logs
+type ingressFields struct {
+	Route                string
+	Status               string
+	UpstreamStatus       string
+	RequestTime          string
+	UpstreamResponseTime string
+	RequestLength        string
+	BodyBytesSent        string
+}
+
+// the default fields of the access logs collected by ACK
+var defaultIngressFields = map[string]ingressFields{
+	INGRESS_FORMAT_NGINX: {
+		Route:                "proxy_upstream_name",
+		Status:               "status",
+		UpstreamStatus:       "upstream_status",
+		RequestTime:          "request_time",
+		UpstreamResponseTime: "upstream_response_time",
+		RequestLength:        "request_length",
+		BodyBytesSent:        "body_bytes_sent",
+	},
+	INGRESS_FORMAT_ALB: {
+		Route:                "slb_pool_name",
+		Status:               "status",
+		UpstreamStatus:       "upstream_status",
+		RequestTime:          "request_time",
+		UpstreamResponseTime: "upstream_response_time",
+		RequestLength:        "request_length",
+		BodyBytesSent:        "body_bytes_sent",
+	},
+}
+
+// set the customized field of access logs
+func (params *SLSIngressParams) setField(key, value string) error {
+	switch key {
+	case FIELD_ROUTE, FIELD_STATUS, FIELD_UPSTREAM_STATUS, FIELD_REQUEST_TIME, FIELD_UPSTREAM_RESPONSE_TIME, FIELD_REQUEST_LENGTH, FIELD_BODY_BYTES_SENT:
+	default:
+		return fmt.Errorf("field %s of ingress logs is not supported", key)
+	}
+	if !validField.MatchString(value) {
+		return fmt.Errorf("invalid field name %s of %s", value, key)
+	}
+	if params.Fields == nil {
+		params.Fields = make(map[string]string)
+	}
+	params.Fields[key] = value
+	return nil
+}
+
+// ingressFields returns the fields of the format with the customized ones
+func (params *SLSIngressParams) ingressFields(metricName string) ingressFields {
+	format := params.Format
+	if format == "" {
+		format = INGRESS_FORMAT_NGINX
+		if metricName == SLS_ALB_INGRESS_QPS {
+			format = INGRESS_FORMAT_ALB
+		}
+	}
+	fields := defaultIngressFields[format]
+	for key, value := range params.Fields {
+		switch key {
+		case FIELD_ROUTE:
+			fields.Route = value
+		case FIELD_STATUS:
+			fields.Status = value
+		case FIELD_UPSTREAM_STATUS:
+			fields.UpstreamStatus = value
+		case FIELD_REQUEST_TIME:
+			fields.RequestTime = value
+		case FIELD_UPSTREAM_RESPONSE_TIME:
+			fields.UpstreamResponseTime = value
+		case FIELD_REQUEST_LENGTH:
+			fields.RequestLength = value
+		case FIELD_BODY_BYTES_SENT:
+			fields.BodyBytesSent = value
+		}
+	}
+	return fields
+}
@@ -4,18 +4,37 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
-	"regexp"
-
-	slssdk "github.com/aliyun/aliyun-log-go-sdk"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	log "k8s.io/klog/v2"
 	"k8s.io/metrics/pkg/apis/external_metrics"
 )
 
+// units of ingress metrics, the latencies of access logs are in seconds and served in ms
+var ingressUnits = map[string]utils.Unit{
+	SLS_ALB_INGRESS_QPS:       {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLS_INGRESS_QPS:           {Name: utils.UNIT_COUNT_PER_SECOND},
+	SLS_INGRESS_LATENCY_AVG:   {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_LATENCY_P50:   {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_LATENCY_P95:   {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_LATENCY_P9999: {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_LATENCY_P99:   {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_INFLOW:        {Name: utils.UNIT_BYTES_PER_SECOND},
+
+	SLS_INGRESS_OUTFLOW:              {Name: utils.UNIT_BYTES_PER_SECOND},
+	SLS_INGRESS_4XX_RATIO:            {Name: utils.UNIT_PERCENT},
+	SLS_INGRESS_5XX_RATIO:            {Name: utils.UNIT_PERCENT},
+	SLS_INGRESS_UPSTREAM_LATENCY_AVG: {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_UPSTREAM_LATENCY_P50: {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_UPSTREAM_LATENCY_P95: {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_UPSTREAM_LATENCY_P99: {Name: utils.UNIT_MILLISECONDS, Scale: utils.MILLISECONDS_PER_SECOND},
+	SLS_INGRESS_UPSTREAM_FAILURES:    {Name: utils.UNIT_COUNT},
+}
+
 type QPSResponse struct {
 	Data []qps `json:"data"`
 }
@@ -23,55 +42,91 @@ type qps struct {
 	Count int64 `json:"count"`
 }
 
+const (
+	// the column of query result which is the metric value
+	VALUE_COLUMN = "value"
+	// the label of series when the ingress metrics are grouped by route
+	ROUTE_LABEL = "route"
+)
+
 type SLSIngressParams struct {
 	SLSGlobalParams
 	Route string
+	// return one series per route
+	GroupByRoute bool
+	// nginx or alb, nginx by default except sls_alb_ingress_qps
+	Format string
+	// the customized field names of access logs, e.g. status -> http_status
+	Fields map[string]string
 }
 
 func (ss *SLSMetricSource) getSLSIngressQuery(params *SLSIngressParams, metricName string) (begin int64, end int64, query string) {
 	now := time.Now().Unix()
 	queryRealBegin := now - int64(params.DelaySeconds) - int64(params.Interval)
 	end = now - int64(params.DelaySeconds)
-	begin = now - 100
+	begin = queryRealBegin
 	if len(params.Route) == 0 {
 		params.Route = "*"
 	}
+	fields := params.ingressFields(metricName)
 	var queryItem string
 	switch metricName {
-	case SLS_ALB_INGRESS_QPS:
-		queryItem = fmt.Sprintf("count(1) / %d", params.Interval)
-		query = fmt.Sprintf("* and slb_pool_name: %s | SELECT %s as value from log WHERE __time__ >= %d  and __time__ < %d", params.Route, queryItem, queryRealBegin, end)
-		return
-	case SLS_INGRESS_QPS:
+	case SLS_ALB_INGRESS_QPS, SLS_INGRESS_QPS:
 		queryItem = fmt.Sprintf("count(1) / %d", params.Interval)
 	case SLS_INGRESS_LATENCY_AVG:
-		queryItem = "avg(request_time) * 1000"
+		queryItem = fmt.Sprintf(`avg("%s")`, fields.RequestTime)
 	case SLS_INGRESS_LATENCY_P50:
-		queryItem = "approx_percentile(request_time, 0.50) * 1000"
+		queryItem = fmt.Sprintf(`approx_percentile("%s", 0.50)`, fields.RequestTime)
 	case SLS_INGRESS_LATENCY_P95:
-		queryItem = "approx_percentile(request_time, 0.95) * 1000"
+		queryItem = fmt.Sprintf(`approx_percentile("%s", 0.95)`, fields.RequestTime)
 	case SLS_INGRESS_LATENCY_P9999:
-		queryItem = "approx_percentile(request_time, 0.9999) * 1000"
+		queryItem = fmt.Sprintf(`approx_percentile("%s", 0.9999)`, fields.RequestTime)
 	case SLS_INGRESS_LATENCY_P99:
-		queryItem = "approx_percentile(request_time, 0.99) * 1000"
+		queryItem = fmt.Sprintf(`approx_percentile("%s", 0.99)`, fields.RequestTime)
 	case SLS_INGRESS_INFLOW:
-		queryItem = fmt.Sprintf("sum(request_length) / %d", params.Interval)
+		queryItem = fmt.Sprintf(`sum("%s") / %d`, fields.RequestLength, params.Interval)
+	case SLS_INGRESS_OUTFLOW:
+		queryItem = fmt.Sprintf(`sum(try_cast("%s" as double)) / %d`, fields.BodyBytesSent, params.Interval)
+	case SLS_INGRESS_4XX_RATIO:
+		queryItem = statusRatio(fields.Status, 400)
+	case SLS_INGRESS_5XX_RATIO:
+		queryItem = statusRatio(fields.Status, 500)
+	case SLS_INGRESS_UPSTREAM_LATENCY_AVG:
+		queryItem = fmt.Sprintf(`avg(try_cast("%s" as double))`, fields.UpstreamResponseTime)
+	case SLS_INGRESS_UPSTREAM_LATENCY_P50:
+		queryItem = fmt.Sprintf(`approx_percentile(try_cast("%s" as double), 0.50)`, fields.UpstreamResponseTime)
+	case SLS_INGRESS_UPSTREAM_LATENCY_P95:
+		queryItem = fmt.Sprintf(`approx_percentile(try_cast("%s" as double), 0.95)`, fields.UpstreamResponseTime)
+	case SLS_INGRESS_UPSTREAM_LATENCY_P99:
+		queryItem = fmt.Sprintf(`approx_percentile(try_cast("%s" as double), 0.99)`, fields.UpstreamResponseTime)
+	case SLS_INGRESS_UPSTREAM_FAILURES:
+		queryItem = fmt.Sprintf(`count_if(try_cast("%s" as bigint) >= 500)`, fields.UpstreamStatus)
 	default:
 		// add default action for unknown metric
-		query = ""
+		return begin, end, ""
 	}
-	query = fmt.Sprintf("* and proxy_upstream_name: %s | SELECT %s as value from log WHERE __time__ >= %d  and __time__ < %d", params.Route, queryItem, queryRealBegin, end)
+	if params.GroupByRoute {
+		// one series per route, which is labeled by route
+		query = fmt.Sprintf(`* and %s: %s | SELECT "%s" as %s, %s as value from log WHERE __time__ >= %d  and __time__ < %d GROUP BY "%s"`, fields.Route, params.Route, fields.Route, ROUTE_LABEL, queryItem, queryRealBegin, end, fields.Route)
+		return
+	}
+	query = fmt.Sprintf("* and %s: %s | SELECT %s as value from log WHERE __time__ >= %d  and __time__ < %d", fields.Route, params.Route, queryItem, queryRealBegin, end)
 	return
 }
 
+// the percentage of requests whose status is in [class, class+100), 0 if there is no request
+func statusRatio(statusField string, class int) string {
+	return fmt.Sprintf(`if(count(1) = 0, 0, count_if(try_cast("%s" as bigint) >= %d and try_cast("%s" as bigint) < %d) * 100.0 / count(1))`, statusField, class, statusField, class+100)
+}
+
 func (ss *SLSMetricSource) getSLSIngressMetrics(namespace string, requirements labels.Requirements, metricName string) (values []external_metrics.ExternalMetricValue, err error) {
 
-	params, err := getSLSParams(requirements)
+	params, err := getSLSParams(namespace, requirements)
 	if err != nil {
 		return values, fmt.Errorf("failed to get sls params,because of %v", err)
 	}
 
-	client, err := ss.Client(params.Internal)
+	client, err := ss.Client(params.AccessOptions, params.Internal)
 	if err != nil {
 		log.Errorf("Failed to create sls client, because of %v", err)
 		return values, err
@@ -84,47 +139,88 @@ func (ss *SLSMetricSource) getSLSIngressMetrics(namespace string, requirements l
 		return values, errors.New("MetricNotSupport")
 	}
 
-	var queryRsp *slssdk.GetLogsResponse
-	for i := 0; i < params.MaxRetry; i++ {
-		queryRsp, err = client.GetLogs(params.Project, params.LogStore, "", begin, end, query, 100, 0, false)
+	rows, err := newSLSExecutor(client).query(&params.SLSGlobalParams, begin, end, query)
+	if err != nil {
+		return values, err
+	}
+	return toExternalMetricValues(metricName, rows, ingressUnits[metricName], requirements)
+}
 
-		if err != nil || len(queryRsp.Logs) == 0 {
-			return values, err
-		}
+// slsValue is a row of query result, the columns except value are the labels
+type slsValue struct {
+	labels map[string]string
+	value  float64
+}
 
-		// if there are too many logs in sls, query may be not completed, we should retry
-		if !queryRsp.IsComplete() {
-			continue
+// parseLogs converts the rows of query result, which must select the result as value
+func parseLogs(logs []map[string]string) (rows []slsValue, err error) {
+	rows = make([]slsValue, 0, len(logs))
+	for _, l := range logs {
+		raw, ok := l[VALUE_COLUMN]
+		if !ok {
+			return nil, fmt.Errorf("column %s is not found in query result", VALUE_COLUMN)
 		}
-
-		value := queryRsp.Logs[0]["value"]
-		var valid = regexp.MustCompile("[0-9.]")
-		array := valid.FindAllStringSubmatch(value, -1)
-
-		valStr := ""
-		for _, i := range array {
-			if len(i) == 1 {
-				valStr += i[0]
-			}
+		value, err := parseValue(raw)
+		if err != nil {
+			return nil, err
 		}
-
-		if valStr == "" {
-			valStr = "0"
+		rowLabels := make(map[string]string)
+		for k, v := range l {
+			// skip the value and the reserved fields of sls, e.g. __time__ and __source__
+			if k == VALUE_COLUMN || strings.HasPrefix(k, "__") {
+				continue
+			}
+			rowLabels[k] = v
 		}
+		rows = append(rows, slsValue{labels: rowLabels, value: value})
+	}
+	return rows, nil
+}
 
-		val, err := strconv.ParseFloat(valStr, 64)
+// parseValue parses the value column, null is returned by sls if there is no log in the range
+func parseValue(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse value %s of query result,because of %v", raw, err)
+	}
+	return value, nil
+}
 
+// toExternalMetricValues converts the rows which match the labels of metric selector
+func toExternalMetricValues(metricName string, rows []slsValue, unit utils.Unit, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	for _, row := range rows {
+		if !matchRequirements(row.labels, requirements) {
+			continue
+		}
+		quantity, err := utils.ConvertValue(row.value, unit)
 		if err != nil {
 			return values, err
 		}
-
 		values = append(values, external_metrics.ExternalMetricValue{
-			MetricName: metricName,
-			Value:      *resource.NewQuantity(int64(val), resource.DecimalSI),
-			Timestamp:  metav1.Now(),
+			MetricName:   metricName,
+			MetricLabels: row.labels,
+			Value:        *quantity,
+			Timestamp:    metav1.Now(),
 		})
+	}
+	return values, nil
+}
 
-		return values, err
+// matchRequirements returns false if any label of the row doesn't match the selector,
+// the requirements on the keys which are not returned by the query are ignored.
+func matchRequirements(rowLabels map[string]string, requirements labels.Requirements) bool {
+	set := labels.Set(rowLabels)
+	for _, r := range requirements {
+		if !set.Has(r.Key()) {
+			continue
+		}
+		if !r.Matches(set) {
+			return false
+		}
 	}
-	return values, errors.New("Query sls timeout,it might because of too many logs.")
+	return true
 }
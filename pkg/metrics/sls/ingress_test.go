@@ -3,13 +3,14 @@ package sls
 import (
 	"fmt"
 	"k8s.io/apimachinery/pkg/labels"
+	"strings"
 	"testing"
 )
 
 func TestInvalidGetSLSParams(t *testing.T) {
 	r := make([]labels.Requirement, 0)
 
-	_, e := getSLSParams(r)
+	_, e := getSLSParams("default", r)
 	if e != nil {
 		t.Log("pass TestInvalidGetSLSParams")
 		return
@@ -39,3 +40,104 @@ func TestIngressQuery(t *testing.T) {
 		fmt.Printf("M:%s, B:%d, E:%d, Q:%s \n", metricInfo.Metric, begin, end, query)
 	}
 }
+
+func TestParseLogs(t *testing.T) {
+	rows, err := parseLogs([]map[string]string{
+		{"route": "default-a-80", "value": "-1.5", "__time__": "1700000000", "__source__": ""},
+		{"route": "default-b-80", "value": "2.5E3"},
+		{"route": "default-c-80", "value": "null"},
+	})
+	if err != nil {
+		t.Fatalf("failed to parse logs: %v", err)
+	}
+	expected := []float64{-1.5, 2500, 0}
+	for i, row := range rows {
+		if row.value != expected[i] || len(row.labels) != 1 || row.labels[ROUTE_LABEL] == "" {
+			t.Fatalf("unexpected row %d: %+v", i, row)
+		}
+	}
+
+	if _, err := parseLogs([]map[string]string{{"value": "1,000"}}); err == nil {
+		t.Fatalf("expected error for invalid value")
+	}
+	if _, err := parseLogs([]map[string]string{{"count": "1"}}); err == nil {
+		t.Fatalf("expected error for missing value column")
+	}
+}
+
+func TestToExternalMetricValues(t *testing.T) {
+	rows := []slsValue{
+		{labels: map[string]string{ROUTE_LABEL: "default-a-80"}, value: 10},
+		{labels: map[string]string{ROUTE_LABEL: "default-b-80"}, value: 20},
+	}
+	s, _ := labels.Parse("sls.project=p,route=default-b-80")
+	requirements, _ := s.Requirements()
+	values, err := toExternalMetricValues(SLS_INGRESS_QPS, rows, ingressUnits[SLS_INGRESS_QPS], requirements)
+	if err != nil {
+		t.Fatalf("failed to convert rows: %v", err)
+	}
+	if len(values) != 1 || values[0].MetricLabels[ROUTE_LABEL] != "default-b-80" || values[0].Value.String() != "20" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+
+	// all series are returned if the route is not selected
+	values, _ = toExternalMetricValues(SLS_INGRESS_QPS, rows, ingressUnits[SLS_INGRESS_QPS], nil)
+	if len(values) != 2 {
+		t.Fatalf("unexpected values: %v", values)
+	}
+
+	// the latencies of access logs are in seconds and served in ms
+	values, _ = toExternalMetricValues(SLS_INGRESS_LATENCY_P95, []slsValue{{value: 0.0125}}, ingressUnits[SLS_INGRESS_LATENCY_P95], nil)
+	if len(values) != 1 || values[0].Value.String() != "12500m" {
+		t.Fatalf("unexpected latency: %v", values)
+	}
+}
+
+func TestIngressQueryGroupByRoute(t *testing.T) {
+	var sms SLSMetricSource
+	params := &SLSIngressParams{
+		SLSGlobalParams: SLSGlobalParams{Interval: 60},
+		GroupByRoute:    true,
+	}
+	_, _, query := sms.getSLSIngressQuery(params, SLS_ALB_INGRESS_QPS)
+	if !strings.Contains(query, `SELECT "slb_pool_name" as route, count(1) / 60 as value`) || !strings.HasSuffix(query, `GROUP BY "slb_pool_name"`) {
+		t.Fatalf("unexpected query: %s", query)
+	}
+	if _, _, query := sms.getSLSIngressQuery(params, "unknown"); query != "" {
+		t.Fatalf("unexpected query of unknown metric: %s", query)
+	}
+}
+
+func TestIngressFields(t *testing.T) {
+	var sms SLSMetricSource
+	s, _ := labels.Parse("sls.project=p,sls.logstore=l,sls.ingress.format=alb,sls.ingress.field.status=http_status,sls.ingress.route=pool-1")
+	requirements, _ := s.Requirements()
+	params, err := getSLSParams("default", requirements)
+	if err != nil {
+		t.Fatalf("failed to get sls params: %v", err)
+	}
+	_, _, query := sms.getSLSIngressQuery(params, SLS_INGRESS_5XX_RATIO)
+	if !strings.HasPrefix(query, "* and slb_pool_name: pool-1 | ") || !strings.Contains(query, `try_cast("http_status" as bigint) >= 500 and try_cast("http_status" as bigint) < 600`) {
+		t.Fatalf("unexpected query: %s", query)
+	}
+
+	// the route of nginx format is used by default
+	params.Format = ""
+	params.Fields = nil
+	_, _, query = sms.getSLSIngressQuery(params, SLS_INGRESS_OUTFLOW)
+	if !strings.HasPrefix(query, "* and proxy_upstream_name: pool-1 | ") || !strings.Contains(query, `sum(try_cast("body_bytes_sent" as double)) / 15`) {
+		t.Fatalf("unexpected query: %s", query)
+	}
+
+	for _, selector := range []string{
+		"sls.project=p,sls.logstore=l,sls.ingress.format=envoy",
+		"sls.project=p,sls.logstore=l,sls.ingress.field.unknown=x",
+		"sls.project=p,sls.logstore=l,sls.ingress.field.status=1x",
+	} {
+		s, _ := labels.Parse(selector)
+		requirements, _ := s.Requirements()
+		if _, err := getSLSParams("default", requirements); err == nil {
+			t.Fatalf("expected error for %s", selector)
+		}
+	}
+}
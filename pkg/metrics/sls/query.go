@@ -0,0 +1,101 @@
+package sls
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+)
+
+// the params are rendered into the query as they are, so only the plain words are allowed
+var validParam = regexp.MustCompile(`^[A-Za-z0-9_.\-]*$`)
+
+// the values of SLSQueryTemplate are served as they are
+var slsQueryUnit = utils.Unit{Name: utils.UNIT_COUNT}
+
+type SLSQueryParams struct {
+	SLSGlobalParams
+	Template string
+	Params   map[string]string
+}
+
+// get the params of sls_query from labels
+func getSLSQueryParams(namespace string, requirements labels.Requirements) (params *SLSQueryParams, err error) {
+	globalParams, err := getSLSParams(namespace, requirements)
+	if err != nil {
+		return nil, err
+	}
+	params = &SLSQueryParams{
+		SLSGlobalParams: globalParams.SLSGlobalParams,
+		Params:          make(map[string]string),
+	}
+	for _, r := range requirements {
+
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+
+		value := r.Values().List()[0]
+
+		switch {
+		case r.Key() == SLS_LABEL_QUERY_TEMPLATE:
+			params.Template = value
+		case strings.HasPrefix(r.Key(), SLS_LABEL_QUERY_PARAM):
+			if !validParam.MatchString(value) {
+				return nil, fmt.Errorf("value %s of %s is invalid", value, r.Key())
+			}
+			params.Params[strings.TrimPrefix(r.Key(), SLS_LABEL_QUERY_PARAM)] = value
+		}
+	}
+
+	if params.Template == "" {
+		return nil, fmt.Errorf("%s must be provided", SLS_LABEL_QUERY_TEMPLATE)
+	}
+	return params, nil
+}
+
+// render the query of template with the time range and params
+func (ss *SLSMetricSource) getSLSQuery(params *SLSQueryParams) (begin int64, end int64, query string, err error) {
+	tmpl, ok := ss.getTemplate(params.Template)
+	if !ok {
+		return 0, 0, "", fmt.Errorf("SLSQueryTemplate %s is not found", params.Template)
+	}
+
+	end = time.Now().Unix() - int64(params.DelaySeconds)
+	begin = end - int64(params.Interval)
+	query, err = tmpl.render(&queryVars{
+		Begin:    begin,
+		End:      end,
+		Interval: params.Interval,
+		Params:   params.Params,
+	})
+	return begin, end, query, err
+}
+
+// get the value of the query template
+func (ss *SLSMetricSource) getSLSQueryMetrics(namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
+	params, err := getSLSQueryParams(namespace, requirements)
+	if err != nil {
+		return values, fmt.Errorf("failed to get sls params,because of %v", err)
+	}
+
+	begin, end, query, err := ss.getSLSQuery(params)
+	if err != nil {
+		return values, err
+	}
+
+	client, err := ss.Client(params.AccessOptions, params.Internal)
+	if err != nil {
+		return values, err
+	}
+
+	rows, err := newSLSExecutor(client).query(&params.SLSGlobalParams, begin, end, query)
+	if err != nil {
+		return values, err
+	}
+	return toExternalMetricValues(SLS_QUERY, rows, slsQueryUnit, requirements)
+}
@@ -0,0 +1,56 @@
+package sls
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetSLSQuery(t *testing.T) {
+	ss := NewSLSMetricSource()
+	err := ss.setTemplate(&SLSQueryTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "errors"},
+		Spec: SLSQueryTemplateSpec{
+			Query: `* and status >= {{.Params.status}} | SELECT count(1) / {{.Interval}} as value FROM log WHERE __time__ >= {{.Begin}} and __time__ < {{.End}}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to set template: %v", err)
+	}
+
+	params, err := getSLSQueryParams("default", testutil.ParseRequirements(t, "sls.project=p,sls.logstore=l,sls.query.template=errors,sls.query.param.status=500,sls.query.interval=60,sls.query.delay=0"))
+	if err != nil {
+		t.Fatalf("failed to get sls query params: %v", err)
+	}
+	begin, end, query, err := ss.getSLSQuery(params)
+	if err != nil {
+		t.Fatalf("failed to render query: %v", err)
+	}
+	expected := fmt.Sprintf("* and status >= 500 | SELECT count(1) / 60 as value FROM log WHERE __time__ >= %d and __time__ < %d", begin, end)
+	if end-begin != 60 || query != expected {
+		t.Fatalf("unexpected query [%d, %d): %s", begin, end, query)
+	}
+
+	// the params used by template must be provided
+	params, _ = getSLSQueryParams("default", testutil.ParseRequirements(t, "sls.project=p,sls.logstore=l,sls.query.template=errors"))
+	if _, _, _, err := ss.getSLSQuery(params); err == nil {
+		t.Fatalf("expected error for missing param")
+	}
+
+	ss.removeTemplate("errors")
+	params, _ = getSLSQueryParams("default", testutil.ParseRequirements(t, "sls.project=p,sls.logstore=l,sls.query.template=errors,sls.query.param.status=500"))
+	if _, _, _, err := ss.getSLSQuery(params); err == nil {
+		t.Fatalf("expected error for removed template")
+	}
+}
+
+func TestGetSLSQueryParamsWithInvalidParams(t *testing.T) {
+	if _, err := getSLSQueryParams("default", testutil.ParseRequirements(t, "sls.project=p,sls.logstore=l")); err == nil {
+		t.Fatalf("expected error for missing template")
+	}
+	if err := (&SLSQueryTemplate{ObjectMeta: metav1.ObjectMeta{Name: "invalid"}, Spec: SLSQueryTemplateSpec{Query: "{{.Begin"}}).Default(); err == nil {
+		t.Fatalf("expected error for invalid template")
+	}
+}
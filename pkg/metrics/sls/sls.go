@@ -10,6 +10,9 @@ import (
 	"k8s.io/metrics/pkg/apis/external_metrics"
 	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -21,20 +24,45 @@ const (
 	SLS_INGRESS_LATENCY_P9999 = "sls_ingress_latency_p9999"
 	SLS_INGRESS_LATENCY_P99   = "sls_ingress_latency_p99"
 	SLS_INGRESS_INFLOW        = "sls_ingress_inflow" // byte per second
+	SLS_QUERY                 = "sls_query"          // value of SLSQueryTemplate
 
-	SLS_LABEL_PROJECT         = "sls.project"
-	SLS_LABEL_LOGSTORE        = "sls.logstore"
-	SLS_LABEL_QUERY_INTERVAL  = "sls.query.interval"  // query interval seconds, min val 15s
-	SLS_LABEL_QUERY_DELAY     = "sls.query.delay"     // query delay seconds, default 0s
-	SLS_LABEL_QUERY_MAX_RETRY = "sls.query.max_retry" // max retry, default 5
-	SLS_LABEL_INGRESS_ROUTE   = "sls.ingress.route"   // e.g. namespace-svc-port
-	SLS_INTERNAL_ENDPOINT     = "sls.internal.endpoint"
+	SLS_INGRESS_OUTFLOW              = "sls_ingress_outflow"   // byte per second
+	SLS_INGRESS_4XX_RATIO            = "sls_ingress_4xx_ratio" // percentage of 4xx requests
+	SLS_INGRESS_5XX_RATIO            = "sls_ingress_5xx_ratio" // percentage of 5xx requests
+	SLS_INGRESS_UPSTREAM_LATENCY_AVG = "sls_ingress_upstream_latency_avg"
+	SLS_INGRESS_UPSTREAM_LATENCY_P50 = "sls_ingress_upstream_latency_p50"
+	SLS_INGRESS_UPSTREAM_LATENCY_P95 = "sls_ingress_upstream_latency_p95"
+	SLS_INGRESS_UPSTREAM_LATENCY_P99 = "sls_ingress_upstream_latency_p99"
+	SLS_INGRESS_UPSTREAM_FAILURES    = "sls_ingress_upstream_failures" // count of upstream 5xx in the interval
+
+	SLS_LABEL_PROJECT                = "sls.project"
+	SLS_LABEL_LOGSTORE               = "sls.logstore"
+	SLS_LABEL_QUERY_INTERVAL         = "sls.query.interval"         // query interval seconds, min val 15s
+	SLS_LABEL_QUERY_DELAY            = "sls.query.delay"            // query delay seconds, default 0s
+	SLS_LABEL_QUERY_MAX_RETRY        = "sls.query.max_retry"        // max retry, default 5
+	SLS_LABEL_QUERY_EMPTY_RESULT     = "sls.query.empty_result"     // zero or error if no log is found, default zero
+	SLS_LABEL_INGRESS_ROUTE          = "sls.ingress.route"          // e.g. namespace-svc-port
+	SLS_LABEL_INGRESS_GROUP_BY_ROUTE = "sls.ingress.group_by_route" // one series per route if true
+	SLS_LABEL_INGRESS_FORMAT         = "sls.ingress.format"         // nginx or alb
+	SLS_LABEL_INGRESS_FIELD          = "sls.ingress.field."         // prefix of customized fields, e.g. sls.ingress.field.status
+	SLS_INTERNAL_ENDPOINT            = "sls.internal.endpoint"
+	SLS_LABEL_QUERY_TEMPLATE         = "sls.query.template" // name of SLSQueryTemplate
+	SLS_LABEL_QUERY_PARAM            = "sls.query.param."   // prefix of query params, e.g. sls.query.param.status
 
 	MIN_INTERVAL      = 15
 	MAX_RETRY_DEFAULT = 5
+
+	// the minimal query interval of sls
+	CACHE_TTL = 15 * time.Second
 )
 
-type SLSMetricSource struct{}
+type SLSMetricSource struct {
+	clients utils.ClientCache
+
+	lock sync.RWMutex
+	// SLSQueryTemplate name -> template
+	templates map[string]*SLSQueryTemplate
+}
 
 func (ss *SLSMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
 	metricInfoList := make([]p.ExternalMetricInfo, 0)
@@ -71,20 +99,52 @@ func (ss *SLSMetricSource) GetExternalMetricInfoList() []p.ExternalMetricInfo {
 	metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
 		Metric: SLS_INGRESS_INFLOW,
 	})
+	// ingress outflow, error ratio and upstream
+	for _, metric := range []string{SLS_INGRESS_OUTFLOW, SLS_INGRESS_4XX_RATIO, SLS_INGRESS_5XX_RATIO,
+		SLS_INGRESS_UPSTREAM_LATENCY_AVG, SLS_INGRESS_UPSTREAM_LATENCY_P50, SLS_INGRESS_UPSTREAM_LATENCY_P95,
+		SLS_INGRESS_UPSTREAM_LATENCY_P99, SLS_INGRESS_UPSTREAM_FAILURES} {
+		metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+			Metric: metric,
+		})
+	}
+	// value of query template
+	metricInfoList = append(metricInfoList, p.ExternalMetricInfo{
+		Metric: SLS_QUERY,
+	})
 	return metricInfoList
 }
 func (ss *SLSMetricSource) GetExternalMetric(info p.ExternalMetricInfo, namespace string, requirements labels.Requirements) (values []external_metrics.ExternalMetricValue, err error) {
-	values, err = ss.getSLSIngressMetrics(namespace, requirements, info.Metric)
+	if info.Metric == SLS_QUERY {
+		values, err = ss.getSLSQueryMetrics(namespace, requirements)
+	} else {
+		values, err = ss.getSLSIngressMetrics(namespace, requirements, info.Metric)
+	}
 	if err != nil {
 		log.Warningf("Failed to GetExternalMetric %s,because of %v", info.Metric, err)
 	}
 	return values, err
 }
 
+// the logs are queried by the interval of at least MIN_INTERVAL seconds, a shorter cache would run the same query again
+func (ss *SLSMetricSource) CacheTTL() time.Duration {
+	return CACHE_TTL
+}
+
+// MetricUnits describes the units of metric values
+func (ss *SLSMetricSource) MetricUnits() map[string]utils.Unit {
+	units := map[string]utils.Unit{
+		SLS_QUERY: slsQueryUnit,
+	}
+	for metric, unit := range ingressUnits {
+		units[metric] = unit
+	}
+	return units
+}
+
 // create client with specific project
-func (ss *SLSMetricSource) Client(internal bool) (client sls.ClientInterface, err error) {
+func (ss *SLSMetricSource) Client(options utils.AccessOptions, internal bool) (client sls.ClientInterface, err error) {
 
-	accessUserInfo, err := utils.GetAccessUserInfo()
+	accessUserInfo, err := utils.GetAccessUserInfoWithOptions(options)
 	if err != nil {
 		log.Infof("Failed to GetAccessUserInfo,because of %v", err)
 		return client, err
@@ -95,20 +155,26 @@ func (ss *SLSMetricSource) Client(internal bool) (client sls.ClientInterface, er
 	} else {
 		endpoint = fmt.Sprintf("%s.log.aliyuncs.com", accessUserInfo.Region)
 	}
-	client = sls.CreateNormalInterface(endpoint, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token)
-
-	return client, nil
+	c, err := ss.clients.Get(endpoint, accessUserInfo, func() (interface{}, error) {
+		return sls.CreateNormalInterface(endpoint, accessUserInfo.AccessKeyId, accessUserInfo.AccessKeySecret, accessUserInfo.Token), nil
+	})
+	if err != nil {
+		return client, err
+	}
+	return c.(sls.ClientInterface), nil
 }
 
 // get sls params from labels
-func getSLSParams(requirements labels.Requirements) (params *SLSIngressParams, err error) {
+func getSLSParams(namespace string, requirements labels.Requirements) (params *SLSIngressParams, err error) {
 	// set default value
 	params = &SLSIngressParams{
 		SLSGlobalParams: SLSGlobalParams{
-			Interval:     MIN_INTERVAL,
-			MaxRetry:     MAX_RETRY_DEFAULT,
-			DelaySeconds: 10,
-			Internal:     true,
+			AccessOptions: utils.GetAccessOptions(namespace, requirements),
+			Interval:      MIN_INTERVAL,
+			MaxRetry:      MAX_RETRY_DEFAULT,
+			DelaySeconds:  10,
+			EmptyResult:   EMPTY_RESULT_ZERO,
+			Internal:      true,
 		},
 	}
 	for _, r := range requirements {
@@ -127,6 +193,13 @@ func getSLSParams(requirements labels.Requirements) (params *SLSIngressParams, e
 			params.LogStore = value
 		case SLS_LABEL_INGRESS_ROUTE:
 			params.Route = value
+		case SLS_LABEL_INGRESS_GROUP_BY_ROUTE:
+			params.GroupByRoute = value == "true"
+		case SLS_LABEL_INGRESS_FORMAT:
+			if _, ok := defaultIngressFields[value]; !ok {
+				return nil, fmt.Errorf("%s must be %s or %s", SLS_LABEL_INGRESS_FORMAT, INGRESS_FORMAT_NGINX, INGRESS_FORMAT_ALB)
+			}
+			params.Format = value
 		case SLS_LABEL_QUERY_INTERVAL:
 			if params.Interval, err = strconv.Atoi(value); err != nil {
 				log.Errorf("Failed to parse %s,because of %v.", SLS_LABEL_QUERY_INTERVAL, err)
@@ -142,10 +215,21 @@ func getSLSParams(requirements labels.Requirements) (params *SLSIngressParams, e
 				log.Errorf("Failed to parse %s,because of %v", SLS_LABEL_QUERY_MAX_RETRY, err)
 				return nil, err
 			}
+		case SLS_LABEL_QUERY_EMPTY_RESULT:
+			if value != EMPTY_RESULT_ZERO && value != EMPTY_RESULT_ERROR {
+				return nil, fmt.Errorf("%s must be %s or %s", SLS_LABEL_QUERY_EMPTY_RESULT, EMPTY_RESULT_ZERO, EMPTY_RESULT_ERROR)
+			}
+			params.EmptyResult = value
 		case SLS_INTERNAL_ENDPOINT:
 			if value != "" && value == "false" {
 				params.Internal = false
 			}
+		default:
+			if strings.HasPrefix(r.Key(), SLS_LABEL_INGRESS_FIELD) {
+				if err := params.setField(strings.TrimPrefix(r.Key(), SLS_LABEL_INGRESS_FIELD), value); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
 
@@ -162,7 +246,8 @@ func getSLSParams(requirements labels.Requirements) (params *SLSIngressParams, e
 	}
 
 	if params.DelaySeconds < 0 {
-
+		log.Infof("The DelaySeconds you specific is %d and use 0 instead", params.DelaySeconds)
+		params.DelaySeconds = 0
 	}
 
 	return params, nil
@@ -170,14 +255,19 @@ func getSLSParams(requirements labels.Requirements) (params *SLSIngressParams, e
 
 // Global params
 type SLSGlobalParams struct {
+	utils.AccessOptions
 	Project      string
 	LogStore     string
 	Interval     int
 	DelaySeconds int
 	MaxRetry     int
 	Internal     bool
+	// zero or error if no log is found
+	EmptyResult string
 }
 
 func NewSLSMetricSource() *SLSMetricSource {
-	return &SLSMetricSource{}
+	return &SLSMetricSource{
+		templates: make(map[string]*SLSQueryTemplate),
+	}
 }
@@ -0,0 +1,155 @@
+package sls
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	GROUP   = "metrics.alibabacloud.com"
+	VERSION = "v1alpha1"
+	KIND    = "SLSQueryTemplate"
+
+	resyncPeriod = 10 * time.Minute
+)
+
+// GVR of SLSQueryTemplate
+var SLSQueryTemplateResource = schema.GroupVersionResource{
+	Group:    GROUP,
+	Version:  VERSION,
+	Resource: "slsquerytemplates",
+}
+
+// SLSQueryTemplate defines a named sls query which is served by the sls_query metric.
+type SLSQueryTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SLSQueryTemplateSpec `json:"spec"`
+}
+
+type SLSQueryTemplateSpec struct {
+	// query and analysis statement in text/template, which must select the result as value, e.g.
+	// * and status >= {{.Params.status}} | SELECT count(1) as value FROM log WHERE __time__ >= {{.Begin}} and __time__ < {{.End}}
+	Query string `json:"query"`
+
+	// parsed from Query
+	tmpl *template.Template
+}
+
+// queryVars are the placeholders of the query template
+type queryVars struct {
+	// unix seconds of the query range [Begin, End)
+	Begin int64
+	End   int64
+	// seconds of the query range
+	Interval int
+	// the params of metric selector, sls.query.param.status=500 is provided as {{.Params.status}}
+	Params map[string]string
+}
+
+// Default parses the query template
+func (s *SLSQueryTemplate) Default() error {
+	if s.Spec.Query == "" {
+		return errors.New("query must be provided")
+	}
+	tmpl, err := template.New(s.Name).Option("missingkey=error").Parse(s.Spec.Query)
+	if err != nil {
+		return fmt.Errorf("failed to parse query,because of %v", err)
+	}
+	s.Spec.tmpl = tmpl
+	return nil
+}
+
+// render the query with the time range and params
+func (s *SLSQueryTemplate) render(vars *queryVars) (string, error) {
+	var buf bytes.Buffer
+	if err := s.Spec.tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render query of template %s,because of %v", s.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// RunUntil watches SLSQueryTemplate and serves the templates by sls_query,
+// the watching starts once the CRD is installed.
+func (ss *SLSMetricSource) RunUntil(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	utils.RunWhenResourceAvailable(kubeClient, SLSQueryTemplateResource, stopCh, func() {
+		factory := dynamicinformer.NewDynamicSharedInformerFactory(kubeClient, resyncPeriod)
+		informer := factory.ForResource(SLSQueryTemplateResource).Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: ss.onUpdate,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				ss.onUpdate(newObj)
+			},
+			DeleteFunc: ss.onDelete,
+		})
+		factory.Start(stopCh)
+		log.Infof("Start watching SLSQueryTemplate")
+	})
+}
+
+func (ss *SLSMetricSource) onUpdate(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	tmpl := &SLSQueryTemplate{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, tmpl); err != nil {
+		log.Errorf("Failed to convert SLSQueryTemplate %s,because of %v", u.GetName(), err)
+		return
+	}
+	if err := ss.setTemplate(tmpl); err != nil {
+		log.Errorf("Failed to register SLSQueryTemplate %s,because of %v", tmpl.Name, err)
+		return
+	}
+	log.Infof("Registered SLSQueryTemplate %s", tmpl.Name)
+}
+
+func (ss *SLSMetricSource) onDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	ss.removeTemplate(u.GetName())
+	log.Infof("Unregistered SLSQueryTemplate %s", u.GetName())
+}
+
+// add or update the query template
+func (ss *SLSMetricSource) setTemplate(tmpl *SLSQueryTemplate) error {
+	if err := tmpl.Default(); err != nil {
+		return err
+	}
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.templates[tmpl.Name] = tmpl
+	return nil
+}
+
+func (ss *SLSMetricSource) removeTemplate(name string) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	delete(ss.templates, name)
+}
+
+func (ss *SLSMetricSource) getTemplate(name string) (*SLSQueryTemplate, bool) {
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+	tmpl, ok := ss.templates[name]
+	return tmpl, ok
+}
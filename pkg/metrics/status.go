@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	log "k8s.io/klog/v2"
+)
+
+var (
+	sourceHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "alibaba_cloud_metrics_adapter_metric_source_healthy",
+			Help: "Whether the last request to the metric source succeeded (1) or not (0).",
+		},
+		[]string{"source"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(sourceHealthy)
+}
+
+// SourceStatus describes the health of a registered metric source.
+type SourceStatus struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Metrics is the number of external metrics registered by the source
+	Metrics int `json:"metrics"`
+	// Units is the unit of each metric if the source describes them
+	Units   map[string]string `json:"units,omitempty"`
+	Healthy bool              `json:"healthy"`
+	// LastError is the error of the last failed request, it's kept until the source recovers
+	LastError          string    `json:"lastError,omitempty"`
+	LastRequestTime    time.Time `json:"lastRequestTime,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// UnitMetricSource is implemented by metric source which describes the units of its metric values.
+type UnitMetricSource interface {
+	MetricUnits() map[string]utils.Unit
+}
+
+// the units served in status, nil if the source doesn't describe them
+func metricUnits(source MetricSource) map[string]string {
+	us, ok := source.(UnitMetricSource)
+	if !ok {
+		return nil
+	}
+	units := make(map[string]string)
+	for metric, unit := range us.MetricUnits() {
+		units[metric] = unit.Name
+	}
+	return units
+}
+
+// the source is treated as healthy until the first failed request
+func newSourceStatus(name string) SourceStatus {
+	sourceHealthy.WithLabelValues(name).Set(1)
+	return SourceStatus{
+		Name:    name,
+		Healthy: true,
+	}
+}
+
+func (s *SourceStatus) update(err error, now time.Time) {
+	healthy := err == nil
+	if s.Healthy != healthy {
+		s.LastTransitionTime = now
+		if healthy {
+			log.Infof("Metric source %s recovered", s.Name)
+		} else {
+			log.Warningf("Metric source %s became unhealthy,because of %v", s.Name, err)
+		}
+	}
+	s.Healthy = healthy
+	s.LastRequestTime = now
+	if healthy {
+		s.LastError = ""
+		sourceHealthy.WithLabelValues(s.Name).Set(1)
+	} else {
+		s.LastError = err.Error()
+		sourceHealthy.WithLabelValues(s.Name).Set(0)
+	}
+}
+
+// SourcesStatusHandler serves the status of metric sources in json.
+func SourcesStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(externalMetricsManager.GetSourcesStatus()); err != nil {
+		log.Errorf("Failed to encode metric sources status,because of %v", err)
+	}
+}
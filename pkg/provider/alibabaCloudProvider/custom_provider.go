@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package alibabaCloudProvider
+
+import (
+	"fmt"
+
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	log "k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/apis/custom_metrics"
+	p "sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+	"sigs.k8s.io/custom-metrics-apiserver/pkg/provider/helpers"
+)
+
+// return metric described by the specific object
+func (ep *AlibabaCloudMetricsProvider) GetMetricByName(name types.NamespacedName, info p.CustomMetricInfo, metricSelector labels.Selector) (*custom_metrics.MetricValue, error) {
+	log.V(4).Infof("Received request for object: %s, resource: %s, metric name: %s, metric selectors: %s", name.String(), info.GroupResource.String(), info.Metric, metricSelector.String())
+
+	info, _, err := info.Normalized(ep.mapper)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := ep.cManager.GetCustomMetric(name, info, metricSelector)
+	if err != nil {
+		log.Errorf("Failed to GetCustomMetric, because of %v ", err)
+		return nil, err
+	}
+
+	ref, err := helpers.ReferenceFor(ep.mapper, name, info)
+	if err != nil {
+		return nil, err
+	}
+	value.DescribedObject = ref
+
+	if !metricSelector.Empty() {
+		sel, err := metav1.ParseToLabelSelector(metricSelector.String())
+		if err != nil {
+			return nil, err
+		}
+		value.Metric.Selector = sel
+	}
+	return value, nil
+}
+
+// return metrics described by the objects matching the selector
+func (ep *AlibabaCloudMetricsProvider) GetMetricBySelector(namespace string, selector labels.Selector, info p.CustomMetricInfo, metricSelector labels.Selector) (*custom_metrics.MetricValueList, error) {
+	names, err := helpers.ListObjectNames(ep.mapper, ep.kubeClient, namespace, selector, info)
+	if err != nil {
+		log.Errorf("Failed to list matching resource names, because of %v", err)
+		// don't leak implementation details to the user
+		return nil, apierr.NewInternalError(fmt.Errorf("unable to list matching resources"))
+	}
+
+	matchingMetrics := make([]custom_metrics.MetricValue, 0)
+	for _, name := range names {
+		value, err := ep.GetMetricByName(types.NamespacedName{Namespace: namespace, Name: name}, info, metricSelector)
+		if err != nil {
+			// skip the objects without metric
+			log.Warningf("Failed to get metric %s of %s/%s, because of %v", info.Metric, namespace, name, err)
+			continue
+		}
+		matchingMetrics = append(matchingMetrics, *value)
+	}
+
+	return &custom_metrics.MetricValueList{
+		Items: matchingMetrics,
+	}, nil
+}
+
+// return registered metrics
+func (ep *AlibabaCloudMetricsProvider) ListAllMetrics() []p.CustomMetricInfo {
+	return ep.cManager.GetMetricsInfoList()
+}
+
+// whether the metric is served by alibaba cloud metric sources
+func (ep *AlibabaCloudMetricsProvider) HasCustomMetric(info p.CustomMetricInfo) bool {
+	info, _, err := info.Normalized(ep.mapper)
+	if err != nil {
+		return false
+	}
+	for _, m := range ep.ListAllMetrics() {
+		if m == info {
+			return true
+		}
+	}
+	return false
+}
@@ -28,7 +28,13 @@ type AlibabaCloudMetricsProvider struct {
 	// external metrics manager
 	eManager *metrics.ExternalMetricsManager
 
-	// todo custom metrics manager
+	// custom metrics manager
+	cManager *metrics.CustomMetricsManager
+}
+
+// RunUntil starts the metric sources which watch kubernetes objects until the given channel is closed.
+func (ep *AlibabaCloudMetricsProvider) RunUntil(stopCh <-chan struct{}) {
+	ep.eManager.RunUntil(ep.kubeClient, stopCh)
 }
 
 func NewAlibabaCloudProvider(mapper apimeta.RESTMapper, dynamicClient dynamic.Interface) (*AlibabaCloudMetricsProvider, error) {
@@ -36,5 +42,6 @@ func NewAlibabaCloudProvider(mapper apimeta.RESTMapper, dynamicClient dynamic.In
 		mapper:     mapper,
 		kubeClient: dynamicClient,
 		eManager:   metrics.GetExternalMetricsManager(),
+		cManager:   metrics.GetCustomMetricsManager(),
 	}, nil
 }
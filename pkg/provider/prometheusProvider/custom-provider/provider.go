@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sync"
 	"time"
 
 	pmodel "github.com/prometheus/common/model"
@@ -211,13 +212,30 @@ func (p *prometheusProvider) GetMetricBySelector(ctx context.Context, namespace
 	return p.metricsFor(queryResults, namespace, resourceNames, info, metricSelector)
 }
 
+// NamersSetter replaces the namers of the provider in-process.
+type NamersSetter interface {
+	// SetNamers swaps the namers and refreshes the metrics with them.
+	SetNamers(namers []naming.MetricNamer) error
+}
+
 type cachingMetricsLister struct {
 	SeriesRegistry
 
 	promClient     prom.Client
 	updateInterval time.Duration
 	maxAge         time.Duration
-	namers         []naming.MetricNamer
+
+	// serializes the updates, so the series of old namers never override the new ones
+	lock   sync.Mutex
+	namers []naming.MetricNamer
+}
+
+// SetNamers swaps the namers and updates the series immediately.
+func (l *cachingMetricsLister) SetNamers(namers []naming.MetricNamer) error {
+	l.lock.Lock()
+	l.namers = namers
+	l.lock.Unlock()
+	return l.updateMetrics()
 }
 
 func (l *cachingMetricsLister) Run() {
@@ -238,6 +256,9 @@ type selectorSeries struct {
 }
 
 func (l *cachingMetricsLister) updateMetrics() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
 	startTime := pmodel.Now().Add(-1 * l.maxAge)
 
 	// don't do duplicate queries when it's just the matchers that change
@@ -19,6 +19,7 @@ package provider
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"k8s.io/klog/v2"
@@ -56,10 +57,26 @@ type MetricListerWithNotification interface {
 	UpdateNow()
 }
 
+// NamersSetter replaces the namers of the lister in-process.
+type NamersSetter interface {
+	// SetNamers swaps the namers and refreshes the metrics with them.
+	SetNamers(namers []naming.MetricNamer) error
+}
+
 type basicMetricLister struct {
 	promClient prom.Client
-	namers     []naming.MetricNamer
 	lookback   time.Duration
+
+	lock   sync.RWMutex
+	namers []naming.MetricNamer
+}
+
+// SetNamers swaps the namers used by the following listings.
+func (l *basicMetricLister) SetNamers(namers []naming.MetricNamer) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.namers = namers
+	return nil
 }
 
 // NewBasicMetricLister creates a MetricLister that is capable of interactly directly with Prometheus to list metrics.
@@ -84,14 +101,18 @@ func (l *basicMetricLister) ListAllMetrics() (MetricUpdateResult, error) {
 		namers: make([]naming.MetricNamer, 0),
 	}
 
+	l.lock.RLock()
+	namers := l.namers
+	l.lock.RUnlock()
+
 	startTime := pmodel.Now().Add(-1 * l.lookback)
 
 	// these can take a while on large clusters, so launch in parallel
 	// and don't duplicate
 	selectors := make(map[prom.Selector]struct{})
-	selectorSeriesChan := make(chan selectorSeries, len(l.namers))
-	errs := make(chan error, len(l.namers))
-	for _, converter := range l.namers {
+	selectorSeriesChan := make(chan selectorSeries, len(namers))
+	errs := make(chan error, len(namers))
+	for _, converter := range namers {
 		sel := converter.Selector()
 		if _, ok := selectors[sel]; ok {
 			errs <- nil
@@ -120,7 +141,7 @@ func (l *basicMetricLister) ListAllMetrics() (MetricUpdateResult, error) {
 	// iterate through, blocking until we've got all results
 	// We know that, from above, we should have pushed one item into the channel
 	// for each converter. So here, we'll assume that we should receive one item per converter.
-	for range l.namers {
+	for range namers {
 		if err := <-errs; err != nil {
 			return result, fmt.Errorf("unable to update list of all metrics: %v", err)
 		}
@@ -137,7 +158,7 @@ func (l *basicMetricLister) ListAllMetrics() (MetricUpdateResult, error) {
 	// we can start processing them.
 	newSeries := make([][]prom.Series, 0)
 	newNamers := make([]naming.MetricNamer, 0)
-	for _, namer := range l.namers {
+	for _, namer := range namers {
 		series, cached := seriesCacheByQuery[namer.Selector()]
 		if !cached {
 			klog.Warningf("unable to update external metrics: no metrics retrieved for query %q", namer.Selector())
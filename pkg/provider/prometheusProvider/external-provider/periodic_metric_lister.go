@@ -17,9 +17,13 @@ limitations under the License.
 package provider
 
 import (
-	"k8s.io/klog/v2"
+	"fmt"
+	"sync"
 	"time"
 
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/prometheus-adapter/pkg/naming"
+
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
@@ -29,6 +33,9 @@ type periodicMetricLister struct {
 	updateInterval   time.Duration
 	mostRecentResult MetricUpdateResult
 	callbacks        []MetricUpdateCallback
+
+	// serializes the updates, so the result of old namers never overrides the new one
+	lock sync.Mutex
 }
 
 // NewPeriodicMetricLister creates a MetricLister that periodically pulls the list of available metrics
@@ -63,7 +70,22 @@ func (l *periodicMetricLister) RunUntil(stopChan <-chan struct{}) {
 	}, l.updateInterval, stopChan)
 }
 
+// SetNamers swaps the namers of the real lister and updates the metrics immediately.
+func (l *periodicMetricLister) SetNamers(namers []naming.MetricNamer) error {
+	setter, ok := l.realLister.(NamersSetter)
+	if !ok {
+		return fmt.Errorf("the namers of metric lister can't be replaced")
+	}
+	if err := setter.SetNamers(namers); err != nil {
+		return err
+	}
+	return l.updateMetrics()
+}
+
 func (l *periodicMetricLister) updateMetrics() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
 	result, err := l.realLister.ListAllMetrics()
 
 	if err != nil {
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	prom "sigs.k8s.io/prometheus-adapter/pkg/client"
+	"sigs.k8s.io/prometheus-adapter/pkg/naming"
 
 	"github.com/stretchr/testify/require"
 )
@@ -81,3 +82,30 @@ func TestWhenListingMetricsReturnsCachedValues(t *testing.T) {
 	require.NotEqual(t, 0, len(resultAfterUpdate.series))
 	require.Equal(t, 1, fakeLister.callCount)
 }
+
+type fakeNamersLister struct {
+	fakeLister
+	namers []naming.MetricNamer
+}
+
+func (f *fakeNamersLister) SetNamers(namers []naming.MetricNamer) error {
+	f.namers = namers
+	return nil
+}
+
+func TestWhenNamersAreSetMetricsAreUpdated(t *testing.T) {
+	fakeLister := &fakeNamersLister{}
+	targetLister, _ := NewPeriodicMetricLister(fakeLister, time.Duration(1000))
+	periodicLister := targetLister.(*periodicMetricLister)
+
+	namers := make([]naming.MetricNamer, 0)
+	err := periodicLister.SetNamers(namers)
+	require.NoError(t, err)
+	require.NotNil(t, fakeLister.namers)
+	require.Equal(t, 1, fakeLister.callCount)
+
+	// the namers of a lister which doesn't support swapping can't be set
+	targetLister, _ = NewPeriodicMetricLister(&fakeLister.fakeLister, time.Duration(1000))
+	err = targetLister.(*periodicMetricLister).SetNamers(namers)
+	require.Error(t, err)
+}
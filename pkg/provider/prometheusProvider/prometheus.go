@@ -48,6 +48,22 @@ type AlibabaMetricsAdapterOptions struct {
 	MetricsConfig *cfg.MetricsDiscoveryConfig
 
 	CostWeights string
+	// EnabledMetricSources is the list of alibaba cloud metric sources to enable, * means all
+	EnabledMetricSources []string
+	// CredentialProfilesFile contains the credential profiles selected by the alibabacloud.profile label
+	CredentialProfilesFile string
+	// MetricStoreConfigFile contains the SLS MetricStores and their external rules
+	MetricStoreConfigFile string
+
+	// SideServerAddress is the address of the server for cost, reload and status apis
+	SideServerAddress string
+	// SideServerCertFile and SideServerKeyFile enable https of the side server
+	SideServerCertFile string
+	SideServerKeyFile  string
+	// SideServerAuthentication delegates authentication and authorization of the side server to kubernetes
+	SideServerAuthentication bool
+	// SideServerRequestTimeout is the timeout of requests to the side server
+	SideServerRequestTimeout time.Duration
 }
 
 func (cmd *AlibabaMetricsAdapterOptions) AddFlags() {
@@ -74,22 +90,47 @@ func (cmd *AlibabaMetricsAdapterOptions) AddFlags() {
 		"period for which to query the set of available metrics from Prometheus")
 	cmd.Flags().StringVar(&cmd.CostWeights, "cost-weights", `{"cpu": "1.0", "memory": "0.0", "gpu": "0.0"}`,
 		"Resource weights used to calculate pod costs")
+	cmd.Flags().StringSliceVar(&cmd.EnabledMetricSources, "enabled-metric-sources", []string{"*"},
+		"Alibaba cloud metric sources to enable, such as sls,slb,alb,nlb,kafka,rocketmq,database,redis,metricstore,cms,ahas,cost,costv2,cloudmonitor. * means all")
+	cmd.Flags().StringVar(&cmd.CredentialProfilesFile, "credential-profiles-file", cmd.CredentialProfilesFile,
+		"File of the credential profiles to access the resources of other accounts, which is usually mounted from a Secret")
+	cmd.Flags().StringVar(&cmd.MetricStoreConfigFile, "metricstore-config", cmd.MetricStoreConfigFile,
+		"Optional file of SLS MetricStores whose metrics are exposed as external metrics by the externalRules of prometheus")
+	cmd.Flags().StringVar(&cmd.SideServerAddress, "side-server-address", cmd.SideServerAddress,
+		"Address of the server for cost, reload and metric source status apis")
+	cmd.Flags().StringVar(&cmd.SideServerCertFile, "side-server-tls-cert-file", cmd.SideServerCertFile,
+		"Optional cert file to serve the side server over https")
+	cmd.Flags().StringVar(&cmd.SideServerKeyFile, "side-server-tls-private-key-file", cmd.SideServerKeyFile,
+		"Optional private key file to serve the side server over https")
+	cmd.Flags().BoolVar(&cmd.SideServerAuthentication, "side-server-authentication", cmd.SideServerAuthentication,
+		"Authenticate requests to the side server by TokenReview and authorize them by SubjectAccessReview")
+	cmd.Flags().DurationVar(&cmd.SideServerRequestTimeout, "side-server-request-timeout", cmd.SideServerRequestTimeout,
+		"Timeout of requests to the side server")
 }
 
 func (cmd *AlibabaMetricsAdapterOptions) LoadConfig() error {
+	metricsConfig, err := cmd.ReadConfig()
+	if err != nil {
+		return err
+	}
+
+	cmd.MetricsConfig = metricsConfig
+
+	return nil
+}
+
+// ReadConfig reads the metrics discovery configuration without replacing the loaded one.
+func (cmd *AlibabaMetricsAdapterOptions) ReadConfig() (*cfg.MetricsDiscoveryConfig, error) {
 	// load metrics discovery configuration
 	if cmd.AdapterConfigFile == "" {
-		return fmt.Errorf("no metrics discovery configuration file specified (make sure to use --config)")
+		return nil, fmt.Errorf("no metrics discovery configuration file specified (make sure to use --config)")
 	}
 
 	metricsConfig, err := cfg.FromFile(cmd.AdapterConfigFile)
 	if err != nil {
-		return fmt.Errorf("unable to load metrics discovery configuration: %v", err)
+		return nil, fmt.Errorf("unable to load metrics discovery configuration: %v", err)
 	}
-
-	cmd.MetricsConfig = metricsConfig
-
-	return nil
+	return metricsConfig, nil
 }
 
 func (cmd *AlibabaMetricsAdapterOptions) MakePromClient() (prom.Client, error) {
@@ -218,6 +259,12 @@ func NewAlibabaMetricsAdapterOptions() *AlibabaMetricsAdapterOptions {
 		MetricsRelistInterval: 10 * time.Minute,
 		MetricsMaxAge:         20 * time.Minute,
 		MetricsConfig:         new(cfg.MetricsDiscoveryConfig),
+
+		CredentialProfilesFile: utils.DEFAULT_PROFILES_PATH,
+
+		SideServerAddress:        ":8080",
+		SideServerAuthentication: true,
+		SideServerRequestTimeout: 60 * time.Second,
 	}
 	return opts
 }
@@ -3,12 +3,19 @@ package provider
 import (
 	"context"
 	"fmt"
+	"sync"
+
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/metrics/metricstore"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider/alibabaCloudProvider"
 	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider/prometheusProvider"
 	prometheusCustomMetricsProvider "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider/prometheusProvider/custom-provider"
 	prometheusExternalMetricsProvider "github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/provider/prometheusProvider/external-provider"
+	"github.com/AliyunContainerService/alibaba-cloud-metrics-adapter/pkg/utils"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 	"k8s.io/metrics/pkg/apis/custom_metrics"
 	"k8s.io/metrics/pkg/apis/external_metrics"
@@ -25,13 +32,26 @@ type providerManager struct {
 	alibabaCloudProvider       *alibabaCloudProvider.AlibabaCloudMetricsProvider
 	prometheusCustomProvider   p.CustomMetricsProvider
 	prometheusExternalProvider p.ExternalMetricsProvider
+
+	// used to reload prometheus rules in-process
+	reloadLock     sync.Mutex
+	opts           *prometheusProvider.AlibabaMetricsAdapterOptions
+	mapper         apimeta.RESTMapper
+	customNamers   prometheusCustomMetricsProvider.NamersSetter
+	externalNamers prometheusExternalMetricsProvider.NamersSetter
 }
 
 func (pm *providerManager) GetMetricByName(ctx context.Context, name types.NamespacedName, info p.CustomMetricInfo, metricSelector labels.Selector) (*custom_metrics.MetricValue, error) {
+	if pm.alibabaCloudProvider.HasCustomMetric(info) {
+		return pm.alibabaCloudProvider.GetMetricByName(name, info, metricSelector)
+	}
 	return pm.prometheusCustomProvider.GetMetricByName(ctx, name, info, metricSelector)
 }
 
 func (pm *providerManager) GetMetricBySelector(ctx context.Context, namespace string, selector labels.Selector, info p.CustomMetricInfo, metricSelector labels.Selector) (*custom_metrics.MetricValueList, error) {
+	if pm.alibabaCloudProvider.HasCustomMetric(info) {
+		return pm.alibabaCloudProvider.GetMetricBySelector(namespace, selector, info, metricSelector)
+	}
 	return pm.prometheusCustomProvider.GetMetricBySelector(ctx, namespace, selector, info, metricSelector)
 }
 
@@ -40,7 +60,12 @@ func (pm *providerManager) GetMetricBySelector(ctx context.Context, namespace st
 // an error, so it is reccomended that implementors cache and
 // periodically update this list, instead of querying every time.
 func (pm *providerManager) ListAllMetrics() []p.CustomMetricInfo {
-	return pm.prometheusCustomProvider.ListAllMetrics()
+	metrics := make([]p.CustomMetricInfo, 0)
+	alibabaCloudMetrics := pm.alibabaCloudProvider.ListAllMetrics()
+	prometheusMetrics := pm.prometheusCustomProvider.ListAllMetrics()
+	metrics = append(metrics, alibabaCloudMetrics...)
+	metrics = append(metrics, prometheusMetrics...)
+	return metrics
 }
 
 func (pm *providerManager) GetExternalMetric(ctx context.Context, namespace string, metricSelector labels.Selector, info p.ExternalMetricInfo) (*external_metrics.ExternalMetricValueList, error) {
@@ -72,7 +97,7 @@ func (pm *providerManager) ListAllExternalMetrics() []p.ExternalMetricInfo {
 	return metrics
 }
 
-func NewProviderManager(opts *prometheusProvider.AlibabaMetricsAdapterOptions, stopCh chan struct{}) (provider.MetricsProvider, error) {
+func NewProviderManager(opts *prometheusProvider.AlibabaMetricsAdapterOptions, stopCh <-chan struct{}) (provider.MetricsProvider, error) {
 	var prometheusCustomMetricsProviderInstance p.CustomMetricsProvider
 	var prometheusExternalMetricsProviderInstance p.ExternalMetricsProvider
 	var customRunner prometheusCustomMetricsProvider.Runnable
@@ -92,9 +117,22 @@ func NewProviderManager(opts *prometheusProvider.AlibabaMetricsAdapterOptions, s
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup alibaba-cloud-metircs-adapter provider: %v", err)
 	}
+	if err := metrics.GetExternalMetricsManager().SetEnabledSources(opts.EnabledMetricSources); err != nil {
+		return nil, fmt.Errorf("invalid enabled metric sources: %v", err)
+	}
+	utils.SetProfilesPath(opts.CredentialProfilesFile)
+	metricstore.SetOptions(metricstore.Options{
+		ConfigFile:            opts.MetricStoreConfigFile,
+		Mapper:                mapper,
+		MetricsRelistInterval: opts.MetricsRelistInterval,
+		MetricsMaxAge:         opts.MetricsMaxAge,
+	})
+	alibabaCloudProviderInstance.RunUntil(stopCh)
 
 	pm := &providerManager{
 		alibabaCloudProvider: alibabaCloudProviderInstance,
+		opts:                 opts,
+		mapper:               mapper,
 	}
 
 	if opts.MetricsMaxAge < opts.MetricsRelistInterval {
@@ -126,6 +164,42 @@ func NewProviderManager(opts *prometheusProvider.AlibabaMetricsAdapterOptions, s
 	externalRunner.RunUntil(stopCh)
 	pm.prometheusCustomProvider = prometheusCustomMetricsProviderInstance
 	pm.prometheusExternalProvider = prometheusExternalMetricsProviderInstance
+	pm.customNamers, _ = customRunner.(prometheusCustomMetricsProvider.NamersSetter)
+	pm.externalNamers, _ = externalRunner.(prometheusExternalMetricsProvider.NamersSetter)
 
 	return pm, nil
 }
+
+// Reload loads the prometheus rules from config file and swaps the namers of custom and external
+// prometheus providers. The loaded rules are kept if the new config is invalid.
+func (pm *providerManager) Reload() error {
+	pm.reloadLock.Lock()
+	defer pm.reloadLock.Unlock()
+
+	metricsConfig, err := pm.opts.ReadConfig()
+	if err != nil {
+		return err
+	}
+	namers, err := naming.NamersFromConfig(metricsConfig.Rules, pm.mapper)
+	if err != nil {
+		return fmt.Errorf("unable to construct naming scheme from metrics rules: %v", err)
+	}
+	if pm.customNamers == nil || pm.externalNamers == nil {
+		return fmt.Errorf("prometheus providers don't support reloading rules")
+	}
+	pm.opts.MetricsConfig = metricsConfig
+
+	// the namers are swapped even if prometheus is unavailable, the series are refreshed in the next relist
+	var errs []error
+	if err := pm.customNamers.SetNamers(namers); err != nil {
+		errs = append(errs, fmt.Errorf("failed to refresh custom metrics: %v", err))
+	}
+	if err := pm.externalNamers.SetNamers(namers); err != nil {
+		errs = append(errs, fmt.Errorf("failed to refresh external metrics: %v", err))
+	}
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+	klog.Infof("Reloaded %d prometheus rules from %s", len(metricsConfig.Rules), pm.opts.AdapterConfigFile)
+	return nil
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// Reloader reloads the prometheus rules in-process.
+type Reloader interface {
+	Reload() error
+}
+
+// ReloadHandler reloads the rules on request and reports the error of invalid config to the caller.
+func ReloadHandler(reloader Reloader) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := reloader.Reload(); err != nil {
+			klog.Errorf("Failed to reload prometheus rules,because of %v", err)
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(writer, "reloaded")
+	}
+}
+
+// WatchConfig reloads the rules once the config file is changed. The directory is watched
+// because the file of ConfigMap is replaced by symlink when it's updated.
+func WatchConfig(path string, reloader Reloader, stopCh <-chan struct{}) error {
+	if path == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config %s,because of %v", path, err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config %s,because of %v", path, err)
+	}
+
+	checksum, _ := fileChecksum(path)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// the directory receives several events for one update, skip them if the content isn't changed
+				current, err := fileChecksum(path)
+				if err != nil || current == checksum {
+					continue
+				}
+				klog.V(4).Infof("config %s changed: %v", path, event)
+				if err := reloader.Reload(); err != nil {
+					klog.Errorf("Failed to reload prometheus rules,because of %v", err)
+				}
+				checksum = current
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("error watching config %s: %v", path, err)
+			}
+		}
+	}()
+	return nil
+}
+
+func fileChecksum(path string) ([sha256.Size]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog/v2"
+)
+
+// delegatingAuthorizer authenticates the bearer token by TokenReview and authorizes the
+// non-resource url by SubjectAccessReview, such as
+//
+//	rules:
+//	- nonResourceURLs: ["/cost", "/v2/*"]
+//	  verbs: ["get"]
+type delegatingAuthorizer struct {
+	client kubernetes.Interface
+}
+
+func (a *delegatingAuthorizer) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		user, ok := a.authenticate(request)
+		if !ok {
+			http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !a.authorize(request, user) {
+			http.Error(writer, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+func (a *delegatingAuthorizer) authenticate(request *http.Request) (*authenticationv1.UserInfo, bool) {
+	auth := strings.TrimSpace(request.Header.Get("Authorization"))
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") || strings.TrimSpace(parts[1]) == "" {
+		return nil, false
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: strings.TrimSpace(parts[1]),
+		},
+	}
+	result, err := a.client.AuthenticationV1().TokenReviews().Create(request.Context(), review, metav1.CreateOptions{})
+	if err != nil {
+		log.Errorf("Failed to review token,because of %v", err)
+		return nil, false
+	}
+	if !result.Status.Authenticated {
+		log.V(4).Infof("Token of request %s is not authenticated: %s", request.URL.Path, result.Status.Error)
+		return nil, false
+	}
+	return &result.Status.User, true
+}
+
+func (a *delegatingAuthorizer) authorize(request *http.Request, user *authenticationv1.UserInfo) bool {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: request.URL.Path,
+				Verb: verb(request.Method),
+			},
+		},
+	}
+	result, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(request.Context(), review, metav1.CreateOptions{})
+	if err != nil {
+		log.Errorf("Failed to review access of %s,because of %v", user.Username, err)
+		return false
+	}
+	if !result.Status.Allowed {
+		log.V(4).Infof("User %s is not allowed to %s %s: %s", user.Username, request.Method, request.URL.Path, result.Status.Reason)
+	}
+	return result.Status.Allowed
+}
+
+// the verb of non-resource request is the lower case http method
+func verb(method string) string {
+	if method == http.MethodHead {
+		return "get"
+	}
+	return strings.ToLower(method)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/cert"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	DEFAULT_ADDRESS          = ":8080"
+	DEFAULT_REQUEST_TIMEOUT  = 60 * time.Second
+	DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second
+
+	// the host of the self-signed certificate
+	SELF_SIGNED_HOST = "alibaba-cloud-metrics-adapter"
+)
+
+// Options of the side server which serves the cost, reload and status apis.
+type Options struct {
+	Address string
+	// serve https if both cert and key are provided, a self-signed certificate is generated
+	// if they are not provided but authentication is enabled, so the bearer tokens are never sent in cleartext.
+	CertFile string
+	KeyFile  string
+	// authenticate the bearer token by TokenReview and authorize the request by SubjectAccessReview
+	Authentication bool
+	RequestTimeout time.Duration
+}
+
+// SideServer is the http server beside the metrics apiserver.
+type SideServer struct {
+	options    Options
+	mux        *http.ServeMux
+	authorizer *delegatingAuthorizer
+	// the self-signed certificate, nil if the cert file is provided or authentication is disabled
+	certificate *tls.Certificate
+}
+
+// NewSideServer creates the server, the client is used to delegate authentication and authorization to kubernetes.
+func NewSideServer(options Options, client kubernetes.Interface) (*SideServer, error) {
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return nil, fmt.Errorf("both tls cert file and private key file must be provided")
+	}
+	if options.Address == "" {
+		options.Address = DEFAULT_ADDRESS
+	}
+	if options.RequestTimeout <= 0 {
+		options.RequestTimeout = DEFAULT_REQUEST_TIMEOUT
+	}
+
+	s := &SideServer{
+		options: options,
+		mux:     http.NewServeMux(),
+	}
+	if options.Authentication {
+		if client == nil {
+			return nil, fmt.Errorf("kubernetes client is required by authentication")
+		}
+		s.authorizer = &delegatingAuthorizer{client: client}
+		if options.CertFile == "" {
+			certificate, err := selfSignedCertificate()
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate self-signed certificate,because of %v", err)
+			}
+			s.certificate = certificate
+		}
+	}
+	return s, nil
+}
+
+// HandleFunc registers the handler of the path. The request is authorized against the
+// non-resource url of the path, and is canceled after the request timeout.
+func (s *SideServer) HandleFunc(path string, handler http.HandlerFunc) {
+	var h http.Handler = http.TimeoutHandler(handler, s.options.RequestTimeout, "request timeout")
+	if s.authorizer != nil {
+		h = s.authorizer.wrap(h)
+	}
+	s.mux.Handle(path, h)
+}
+
+// RunUntil serves until the stop channel is closed, then the in-flight requests are drained gracefully.
+func (s *SideServer) RunUntil(stopCh <-chan struct{}) error {
+	server := &http.Server{
+		Addr:              s.options.Address,
+		Handler:           s.mux,
+		ReadHeaderTimeout: s.options.RequestTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if s.options.CertFile != "" {
+			log.Infof("Serving securely on %s", s.options.Address)
+			err = server.ListenAndServeTLS(s.options.CertFile, s.options.KeyFile)
+		} else if s.certificate != nil {
+			log.Infof("Serving securely on %s with self-signed certificate", s.options.Address)
+			server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{*s.certificate}}
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Infof("Serving insecurely on %s", s.options.Address)
+			err = server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve on %s,because of %v", s.options.Address, err)
+	case <-stopCh:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown server gracefully,because of %v", err)
+	}
+	return nil
+}
+
+// selfSignedCertificate generates the certificate in memory like the metrics apiserver without cert files
+func selfSignedCertificate() (*tls.Certificate, error) {
+	certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey(SELF_SIGNED_HOST, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &certificate, nil
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// the token "admin" is allowed to get /cost, the token "guest" is authenticated only
+func fakeClient() *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "admin", "guest":
+			review.Status.Authenticated = true
+			review.Status.User.Username = review.Spec.Token
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := review.Spec.NonResourceAttributes
+		review.Status.Allowed = review.Spec.User == "admin" && attrs.Path == "/cost" && attrs.Verb == "get"
+		return true, review, nil
+	})
+	return client
+}
+
+func TestAuthorization(t *testing.T) {
+	s, err := NewSideServer(Options{Authentication: true}, fakeClient())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	s.HandleFunc("/cost", func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		token    string
+		method   string
+		expected int
+	}{
+		{"", http.MethodGet, http.StatusUnauthorized},
+		{"invalid", http.MethodGet, http.StatusUnauthorized},
+		{"guest", http.MethodGet, http.StatusForbidden},
+		{"admin", http.MethodPost, http.StatusForbidden},
+		{"admin", http.MethodGet, http.StatusOK},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, "/cost", nil)
+		if c.token != "" {
+			request.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		recorder := httptest.NewRecorder()
+		s.mux.ServeHTTP(recorder, request)
+		if recorder.Code != c.expected {
+			t.Fatalf("unexpected status of %s %s: %d", c.method, c.token, recorder.Code)
+		}
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	if _, err := NewSideServer(Options{CertFile: "tls.crt"}, nil); err == nil {
+		t.Fatalf("expected error for missing private key")
+	}
+	if _, err := NewSideServer(Options{Authentication: true}, nil); err == nil {
+		t.Fatalf("expected error for missing kubernetes client")
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	s, err := NewSideServer(Options{Address: "127.0.0.1:0"}, nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	stopCh := make(chan struct{})
+	close(stopCh)
+	if err := s.RunUntil(stopCh); err != nil {
+		t.Fatalf("failed to shutdown server: %v", err)
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	if s, _ := NewSideServer(Options{}, nil); s.certificate != nil {
+		t.Fatalf("unexpected certificate without authentication")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	address := l.Addr().String()
+	l.Close()
+	s, err := NewSideServer(Options{Address: address, Authentication: true}, fakeClient())
+	if err != nil || s.certificate == nil {
+		t.Fatalf("expected self-signed certificate: %v", err)
+	}
+	s.HandleFunc("/cost", func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	})
+	stopCh := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- s.RunUntil(stopCh)
+	}()
+	defer func() {
+		close(stopCh)
+		if err := <-done; err != nil {
+			t.Fatalf("failed to shutdown server: %v", err)
+		}
+	}()
+
+	// the token is never sent in cleartext
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, Timeout: time.Second}
+	request, _ := http.NewRequest(http.MethodGet, "https://"+address+"/cost", nil)
+	request.Header.Set("Authorization", "Bearer admin")
+	var response *http.Response
+	for i := 0; i < 50; i++ {
+		if response, err = client.Do(request); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to request over https: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.TLS == nil {
+		t.Fatalf("unexpected response %d over tls %v", response.StatusCode, response.TLS != nil)
+	}
+}
@@ -4,12 +4,9 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
-	"encoding/json"
-	"github.com/denverdino/aliyungo/metadata"
-	"io/ioutil"
+	"errors"
+
 	"k8s.io/klog/v2"
-	"os"
-	"time"
 )
 
 const (
@@ -23,6 +20,8 @@ type AccessUserInfo struct {
 	Expiration      string `json:"expiration"`
 	Keyring         string `json:"keyring"`
 	Region          string
+	// the credential profile, empty for the default credentials
+	Profile string
 }
 
 func PKCS5UnPadding(origData []byte) []byte {
@@ -43,6 +42,9 @@ func Decrypt(s string, keyring []byte) ([]byte, error) {
 		return nil, err
 	}
 	blockSize := block.BlockSize()
+	if len(cdata) <= blockSize || len(cdata)%blockSize != 0 {
+		return nil, errors.New("invalid length of cipher text")
+	}
 
 	iv := cdata[:blockSize]
 	blockMode := cipher.NewCBCDecrypter(block, iv)
@@ -50,83 +52,9 @@ func Decrypt(s string, keyring []byte) ([]byte, error) {
 
 	blockMode.CryptBlocks(origData, cdata[blockSize:])
 
+	if unpadding := int(origData[len(origData)-1]); unpadding == 0 || unpadding > blockSize {
+		return nil, errors.New("invalid padding of plain text")
+	}
 	origData = PKCS5UnPadding(origData)
 	return origData, nil
 }
-
-func GetAccessUserInfo() (accessUserInfo *AccessUserInfo, err error) {
-	m := metadata.NewMetaData(nil)
-	region, err := GetRegionFromEnv()
-	if err != nil {
-		region, err = m.Region()
-		if err != nil {
-			klog.Errorf("failed to get Region,because of %s", err.Error())
-			return nil, err
-		}
-	}
-
-	var akFromEnv string = os.Getenv("AccessKeyId")
-	var skFromEnv string = os.Getenv("AccessKeySecret")
-	var akInfo AccessUserInfo
-	if akFromEnv != "" && skFromEnv != "" {
-		//使用环境变量ak/sk
-		akInfo.Region = region
-		akInfo.AccessKeyId = akFromEnv
-		akInfo.AccessKeySecret = skFromEnv
-	} else if _, err := os.Stat(ConfigPath); err == nil {
-		//获取token config json
-		encodeTokenCfg, err := ioutil.ReadFile(ConfigPath)
-		if err != nil {
-			klog.Fatalf("failed to read token config, err: %v", err)
-		}
-		err = json.Unmarshal(encodeTokenCfg, &akInfo)
-		if err != nil {
-			klog.Fatalf("error unmarshal token config: %v", err)
-		}
-		keyring := akInfo.Keyring
-		ak, err := Decrypt(akInfo.AccessKeyId, []byte(keyring))
-		if err != nil {
-			klog.Fatalf("failed to decode ak, err: %v", err)
-		}
-
-		sk, err := Decrypt(akInfo.AccessKeySecret, []byte(keyring))
-		if err != nil {
-			klog.Fatalf("failed to decode sk, err: %v", err)
-		}
-
-		token, err := Decrypt(akInfo.Token, []byte(keyring))
-		if err != nil {
-			klog.Fatalf("failed to decode token, err: %v", err)
-		}
-		layout := "2006-01-02T15:04:05Z"
-		t, err := time.Parse(layout, akInfo.Expiration)
-		if err != nil {
-			klog.Errorf(err.Error())
-		}
-		if t.Before(time.Now()) {
-			klog.Errorf("invalid token which is expired")
-		}
-		akInfo.AccessKeyId = string(ak)
-		akInfo.AccessKeySecret = string(sk)
-		akInfo.Token = string(token)
-		akInfo.Region = region
-	} else {
-		//兼容老的metaserver获取形式
-		roleName, err := m.RoleName()
-		if err != nil {
-			klog.Errorf("failed to get RoleName,because of %s", err.Error())
-			return nil, err
-		}
-
-		auth, err := m.RamRoleToken(roleName)
-		if err != nil {
-			klog.Errorf("failed to get RamRoleToken,because of %s", err.Error())
-			return nil, err
-		}
-		akInfo.AccessKeyId = auth.AccessKeyId
-		akInfo.AccessKeySecret = auth.AccessKeySecret
-		akInfo.Token = auth.SecurityToken
-		akInfo.Region = region
-	}
-	return &akInfo, nil
-}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"sync"
+)
+
+// ClientCache shares the sdk clients of a metric source between requests.
+// The client is rebuilt once the credentials are rotated.
+type ClientCache struct {
+	lock    sync.Mutex
+	clients map[string]*cachedClient
+}
+
+type cachedClient struct {
+	fingerprint string
+	client      interface{}
+}
+
+// Get returns the cached client of key(e.g. region or endpoint) which is built with the same credentials,
+// otherwise build a new one. The clients of credential profiles are cached separately.
+func (cc *ClientCache) Get(key string, accessUserInfo *AccessUserInfo, build func() (interface{}, error)) (interface{}, error) {
+	fingerprint := accessUserInfo.AccessKeyId + "/" + accessUserInfo.Token
+	if accessUserInfo.Profile != "" {
+		key = accessUserInfo.Profile + "@" + key
+	}
+
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	if cc.clients == nil {
+		cc.clients = make(map[string]*cachedClient)
+	}
+	if c, ok := cc.clients[key]; ok && c.fingerprint == fingerprint {
+		return c.client, nil
+	}
+
+	client, err := build()
+	if err != nil {
+		return nil, err
+	}
+	cc.clients[key] = &cachedClient{
+		fingerprint: fingerprint,
+		client:      client,
+	}
+	return client, nil
+}
@@ -0,0 +1,402 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/denverdino/aliyungo/metadata"
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// RRSA(RAM Roles for Service Accounts) envs injected by ack-pod-identity-webhook
+	EnvRoleArn         = "ALIBABA_CLOUD_ROLE_ARN"
+	EnvOIDCProviderArn = "ALIBABA_CLOUD_OIDC_PROVIDER_ARN"
+	EnvOIDCTokenFile   = "ALIBABA_CLOUD_OIDC_TOKEN_FILE"
+	EnvRoleSessionName = "ALIBABA_CLOUD_ROLE_SESSION_NAME"
+	EnvSTSEndpoint     = "ALIBABA_CLOUD_STS_ENDPOINT"
+
+	DefaultSTSEndpoint     = "sts.aliyuncs.com"
+	DefaultRoleSessionName = "alibaba-cloud-metrics-adapter"
+
+	// credentials are refreshed in advance before they are expired
+	credentialsRefreshAhead = 5 * time.Minute
+	stsTokenDuration        = time.Hour
+	expirationLayout        = "2006-01-02T15:04:05Z"
+)
+
+var (
+	defaultCredentials *CachedCredentialProvider
+	credentialsOnce    sync.Once
+
+	regionLock sync.Mutex
+	region     string
+)
+
+// CredentialProvider retrieves credentials from somewhere.
+// zero expiration means that the credentials never expire.
+type CredentialProvider interface {
+	Retrieve() (cred *AccessUserInfo, expiration time.Time, err error)
+}
+
+// credentialChainLink is a CredentialProvider which is only used when it is configured.
+type credentialChainLink interface {
+	CredentialProvider
+	Available() bool
+	Name() string
+}
+
+// GetAccessUserInfo returns the shared credentials with the region of the adapter.
+func GetAccessUserInfo() (accessUserInfo *AccessUserInfo, err error) {
+	return GetAccessUserInfoWithOptions(AccessOptions{})
+}
+
+// GetRegion returns the region from env or ecs metadata, only successful result is cached.
+func GetRegion() (string, error) {
+	regionLock.Lock()
+	defer regionLock.Unlock()
+	if region != "" {
+		return region, nil
+	}
+
+	r, err := GetRegionFromEnv()
+	if err != nil {
+		r, err = metadata.NewMetaData(nil).Region()
+		if err != nil {
+			return "", err
+		}
+	}
+	region = r
+	return region, nil
+}
+
+// DefaultCredentialProvider returns the long-lived credentials shared by all metric sources.
+// The chain is: env ak -> RRSA(OIDC) -> addon token config -> ecs ram role.
+func DefaultCredentialProvider() *CachedCredentialProvider {
+	credentialsOnce.Do(func() {
+		tokenConfig := &tokenConfigProvider{path: ConfigPath}
+		defaultCredentials = NewCachedCredentialProvider(&credentialChain{
+			links: []credentialChainLink{
+				&envProvider{},
+				newOIDCProvider(),
+				tokenConfig,
+				&ecsRamRoleProvider{},
+			},
+		})
+		// the token config is rotated by addon, drop the cached one once it changes.
+		tokenConfig.watch(defaultCredentials.Invalidate)
+	})
+	return defaultCredentials
+}
+
+// CachedCredentialProvider caches credentials and refreshes them before expiration.
+type CachedCredentialProvider struct {
+	lock       sync.Mutex
+	provider   CredentialProvider
+	cred       *AccessUserInfo
+	expiration time.Time
+	now        func() time.Time
+}
+
+func NewCachedCredentialProvider(provider CredentialProvider) *CachedCredentialProvider {
+	return &CachedCredentialProvider{
+		provider: provider,
+		now:      time.Now,
+	}
+}
+
+// Get returns a copy of the cached credentials. If refreshing fails,
+// the old credentials are still used until they are actually expired.
+func (c *CachedCredentialProvider) Get() (*AccessUserInfo, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	if c.cred != nil && (c.expiration.IsZero() || now.Add(credentialsRefreshAhead).Before(c.expiration)) {
+		cred := *c.cred
+		return &cred, nil
+	}
+
+	cred, expiration, err := c.provider.Retrieve()
+	if err != nil {
+		if c.cred != nil && now.Before(c.expiration) {
+			klog.Warningf("failed to refresh credentials and use the cached one which expires at %v,because of %v", c.expiration, err)
+			cred := *c.cred
+			return &cred, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve credentials,because of %v", err)
+	}
+	if !expiration.IsZero() && !now.Before(expiration) {
+		return nil, fmt.Errorf("invalid token which is expired at %v", expiration)
+	}
+
+	c.cred = cred
+	c.expiration = expiration
+	result := *cred
+	return &result, nil
+}
+
+// Invalidate drops the cached credentials, they will be retrieved again in next Get.
+func (c *CachedCredentialProvider) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cred = nil
+	c.expiration = time.Time{}
+}
+
+type credentialChain struct {
+	links []credentialChainLink
+}
+
+// the first available link is used.
+func (cc *credentialChain) Retrieve() (*AccessUserInfo, time.Time, error) {
+	for _, link := range cc.links {
+		if !link.Available() {
+			continue
+		}
+		cred, expiration, err := link.Retrieve()
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("%s: %v", link.Name(), err)
+		}
+		klog.V(4).Infof("retrieved credentials from %s which expire at %v", link.Name(), expiration)
+		return cred, expiration, nil
+	}
+	return nil, time.Time{}, errors.New("no credentials provider is available")
+}
+
+// ak/sk from env
+type envProvider struct{}
+
+func (ep *envProvider) Name() string {
+	return "env"
+}
+
+func (ep *envProvider) Available() bool {
+	return os.Getenv("AccessKeyId") != "" && os.Getenv("AccessKeySecret") != ""
+}
+
+func (ep *envProvider) Retrieve() (*AccessUserInfo, time.Time, error) {
+	return &AccessUserInfo{
+		AccessKeyId:     os.Getenv("AccessKeyId"),
+		AccessKeySecret: os.Getenv("AccessKeySecret"),
+	}, time.Time{}, nil
+}
+
+// encrypted sts token maintained by addon token controller
+type tokenConfigProvider struct {
+	path string
+}
+
+func (tp *tokenConfigProvider) Name() string {
+	return "token-config"
+}
+
+func (tp *tokenConfigProvider) Available() bool {
+	_, err := os.Stat(tp.path)
+	return err == nil
+}
+
+func (tp *tokenConfigProvider) Retrieve() (*AccessUserInfo, time.Time, error) {
+	encodeTokenCfg, err := ioutil.ReadFile(tp.path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read token config, err: %v", err)
+	}
+	return parseTokenConfig(encodeTokenCfg)
+}
+
+// watch the directory of token config because the file is replaced by symlink when secret is updated.
+func (tp *tokenConfigProvider) watch(onChange func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Warningf("failed to watch token config %s,because of %v", tp.path, err)
+		return
+	}
+	if err := watcher.Add(filepath.Dir(tp.path)); err != nil {
+		klog.V(4).Infof("skip watching token config %s,because of %v", tp.path, err)
+		watcher.Close()
+		return
+	}
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				klog.V(4).Infof("token config changed: %v", event)
+				onChange()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("error watching token config %s: %v", tp.path, err)
+			}
+		}
+	}()
+}
+
+func parseTokenConfig(encodeTokenCfg []byte) (*AccessUserInfo, time.Time, error) {
+	var akInfo AccessUserInfo
+	if err := json.Unmarshal(encodeTokenCfg, &akInfo); err != nil {
+		return nil, time.Time{}, fmt.Errorf("error unmarshal token config: %v", err)
+	}
+	keyring := []byte(akInfo.Keyring)
+	ak, err := Decrypt(akInfo.AccessKeyId, keyring)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode ak, err: %v", err)
+	}
+	sk, err := Decrypt(akInfo.AccessKeySecret, keyring)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode sk, err: %v", err)
+	}
+	token, err := Decrypt(akInfo.Token, keyring)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode token, err: %v", err)
+	}
+	expiration, err := time.Parse(expirationLayout, akInfo.Expiration)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse expiration %s, err: %v", akInfo.Expiration, err)
+	}
+	return &AccessUserInfo{
+		AccessKeyId:     string(ak),
+		AccessKeySecret: string(sk),
+		Token:           string(token),
+		Expiration:      akInfo.Expiration,
+	}, expiration, nil
+}
+
+// RRSA, assume role with the oidc token of service account
+type oidcProvider struct {
+	roleArn         string
+	providerArn     string
+	tokenFile       string
+	roleSessionName string
+	endpoint        string
+	client          *http.Client
+}
+
+func newOIDCProvider() *oidcProvider {
+	op := &oidcProvider{
+		roleArn:         os.Getenv(EnvRoleArn),
+		providerArn:     os.Getenv(EnvOIDCProviderArn),
+		tokenFile:       os.Getenv(EnvOIDCTokenFile),
+		roleSessionName: os.Getenv(EnvRoleSessionName),
+		endpoint:        os.Getenv(EnvSTSEndpoint),
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+	if op.roleSessionName == "" {
+		op.roleSessionName = DefaultRoleSessionName
+	}
+	if op.endpoint == "" {
+		op.endpoint = DefaultSTSEndpoint
+	}
+	return op
+}
+
+func (op *oidcProvider) Name() string {
+	return "rrsa"
+}
+
+func (op *oidcProvider) Available() bool {
+	return op.roleArn != "" && op.providerArn != "" && op.tokenFile != ""
+}
+
+type assumeRoleResponse struct {
+	Code        string `json:"Code"`
+	Message     string `json:"Message"`
+	Credentials struct {
+		AccessKeyId     string `json:"AccessKeyId"`
+		AccessKeySecret string `json:"AccessKeySecret"`
+		SecurityToken   string `json:"SecurityToken"`
+		Expiration      string `json:"Expiration"`
+	} `json:"Credentials"`
+}
+
+// AssumeRoleWithOIDC is an anonymous api of sts and doesn't need signature.
+func (op *oidcProvider) Retrieve() (*AccessUserInfo, time.Time, error) {
+	token, err := ioutil.ReadFile(op.tokenFile)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read oidc token, err: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("Action", "AssumeRoleWithOIDC")
+	form.Set("Format", "JSON")
+	form.Set("Version", "2015-04-01")
+	form.Set("Timestamp", time.Now().UTC().Format(expirationLayout))
+	form.Set("RoleArn", op.roleArn)
+	form.Set("OIDCProviderArn", op.providerArn)
+	form.Set("OIDCToken", strings.TrimSpace(string(token)))
+	form.Set("RoleSessionName", op.roleSessionName)
+	form.Set("DurationSeconds", fmt.Sprintf("%d", int(stsTokenDuration.Seconds())))
+
+	resp, err := op.client.PostForm(fmt.Sprintf("https://%s/", op.endpoint), form)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to assume role with oidc, err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read response of sts, err: %v", err)
+	}
+
+	var res assumeRoleResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to unmarshal response of sts, err: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("failed to assume role with oidc, code: %s, message: %s", res.Code, res.Message)
+	}
+
+	expiration, err := time.Parse(expirationLayout, res.Credentials.Expiration)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse expiration %s, err: %v", res.Credentials.Expiration, err)
+	}
+	return &AccessUserInfo{
+		AccessKeyId:     res.Credentials.AccessKeyId,
+		AccessKeySecret: res.Credentials.AccessKeySecret,
+		Token:           res.Credentials.SecurityToken,
+		Expiration:      res.Credentials.Expiration,
+	}, expiration, nil
+}
+
+// 兼容老的metaserver获取形式
+type ecsRamRoleProvider struct{}
+
+func (ep *ecsRamRoleProvider) Name() string {
+	return "ecs-ram-role"
+}
+
+func (ep *ecsRamRoleProvider) Available() bool {
+	return true
+}
+
+func (ep *ecsRamRoleProvider) Retrieve() (*AccessUserInfo, time.Time, error) {
+	m := metadata.NewMetaData(nil)
+	roleName, err := m.RoleName()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get RoleName,because of %s", err.Error())
+	}
+
+	auth, err := m.RamRoleToken(roleName)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get RamRoleToken,because of %s", err.Error())
+	}
+	return &AccessUserInfo{
+		AccessKeyId:     auth.AccessKeyId,
+		AccessKeySecret: auth.AccessKeySecret,
+		Token:           auth.SecurityToken,
+		Expiration:      auth.Expiration.UTC().Format(expirationLayout),
+	}, auth.Expiration, nil
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeCredentialProvider struct {
+	calls      int
+	cred       *AccessUserInfo
+	expiration time.Time
+	err        error
+}
+
+func (fp *fakeCredentialProvider) Retrieve() (*AccessUserInfo, time.Time, error) {
+	fp.calls++
+	if fp.err != nil {
+		return nil, time.Time{}, fp.err
+	}
+	cred := *fp.cred
+	return &cred, fp.expiration, nil
+}
+
+func TestCachedCredentialProviderRefreshAhead(t *testing.T) {
+	now := time.Now()
+	fp := &fakeCredentialProvider{
+		cred:       &AccessUserInfo{AccessKeyId: "STS.1"},
+		expiration: now.Add(time.Hour),
+	}
+	cp := NewCachedCredentialProvider(fp)
+	cp.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := cp.Get(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if fp.calls != 1 {
+		t.Fatalf("expected credentials to be cached, retrieved %d times", fp.calls)
+	}
+
+	// refresh before expiration
+	now = now.Add(time.Hour - time.Minute)
+	fp.cred = &AccessUserInfo{AccessKeyId: "STS.2"}
+	fp.expiration = now.Add(time.Hour)
+	cred, err := cp.Get()
+	if err != nil || cred.AccessKeyId != "STS.2" {
+		t.Fatalf("expected credentials to be refreshed, got %v, %v", cred, err)
+	}
+}
+
+func TestCachedCredentialProviderKeepOldOnFailure(t *testing.T) {
+	now := time.Now()
+	fp := &fakeCredentialProvider{
+		cred:       &AccessUserInfo{AccessKeyId: "STS.1"},
+		expiration: now.Add(10 * time.Minute),
+	}
+	cp := NewCachedCredentialProvider(fp)
+	cp.now = func() time.Time { return now }
+	if _, err := cp.Get(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fp.err = errors.New("sts unavailable")
+	now = now.Add(6 * time.Minute)
+	cred, err := cp.Get()
+	if err != nil || cred.AccessKeyId != "STS.1" {
+		t.Fatalf("expected the old credentials to be used, got %v, %v", cred, err)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if _, err := cp.Get(); err == nil {
+		t.Fatalf("expected error when the credentials are expired")
+	}
+}
+
+func TestCredentialChain(t *testing.T) {
+	os.Setenv("AccessKeyId", "ak")
+	os.Setenv("AccessKeySecret", "sk")
+	defer os.Unsetenv("AccessKeyId")
+	defer os.Unsetenv("AccessKeySecret")
+
+	chain := &credentialChain{
+		links: []credentialChainLink{
+			&tokenConfigProvider{path: "/not/exist"},
+			&envProvider{},
+		},
+	}
+	cred, expiration, err := chain.Retrieve()
+	if err != nil || cred.AccessKeyId != "ak" || !expiration.IsZero() {
+		t.Fatalf("unexpected credentials from chain: %v, %v, %v", cred, expiration, err)
+	}
+}
+
+func encrypt(t *testing.T, plain string, keyring []byte) string {
+	block, err := aes.NewCipher(keyring)
+	if err != nil {
+		t.Fatalf("failed to new cipher: %v", err)
+	}
+	padding := block.BlockSize() - len(plain)%block.BlockSize()
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	iv := make([]byte, block.BlockSize())
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return base64.StdEncoding.EncodeToString(append(iv, out...))
+}
+
+func TestTokenConfigProvider(t *testing.T) {
+	keyring := []byte("0123456789abcdef")
+	expiration := time.Now().Add(time.Hour).UTC().Format(expirationLayout)
+	cfg := fmt.Sprintf(`{"access.key.id":"%s","access.key.secret":"%s","security.token":"%s","expiration":"%s","keyring":"%s"}`,
+		encrypt(t, "STS.ak", keyring), encrypt(t, "sk", keyring), encrypt(t, "token", keyring), expiration, keyring)
+
+	dir, err := ioutil.TempDir("", "token-config")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token-config")
+	if err := ioutil.WriteFile(path, []byte(cfg), 0600); err != nil {
+		t.Fatalf("failed to write token config: %v", err)
+	}
+
+	tp := &tokenConfigProvider{path: path}
+	cred, exp, err := tp.Retrieve()
+	if err != nil {
+		t.Fatalf("failed to retrieve token config: %v", err)
+	}
+	if cred.AccessKeyId != "STS.ak" || cred.AccessKeySecret != "sk" || cred.Token != "token" || exp.Format(expirationLayout) != expiration {
+		t.Fatalf("unexpected credentials: %+v, %v", cred, exp)
+	}
+
+	// broken file should return error instead of exiting
+	if err := ioutil.WriteFile(path, []byte(`{"access.key.id":"bad"}`), 0600); err != nil {
+		t.Fatalf("failed to write token config: %v", err)
+	}
+	if _, _, err := tp.Retrieve(); err == nil {
+		t.Fatalf("expected error for broken token config")
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithOIDC" || r.Form.Get("OIDCToken") != "oidc-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"Code":"InvalidParameter","Message":"bad request"}`))
+			return
+		}
+		w.Write([]byte(`{"Credentials":{"AccessKeyId":"STS.oidc","AccessKeySecret":"sk","SecurityToken":"token","Expiration":"2099-01-01T00:00:00Z"}}`))
+	}))
+	defer server.Close()
+
+	tokenFile, err := ioutil.TempFile("", "oidc-token")
+	if err != nil {
+		t.Fatalf("failed to create token file: %v", err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("oidc-token\n")
+	tokenFile.Close()
+
+	op := &oidcProvider{
+		roleArn:         "acs:ram::1:role/test",
+		providerArn:     "acs:ram::1:oidc-provider/test",
+		tokenFile:       tokenFile.Name(),
+		roleSessionName: DefaultRoleSessionName,
+		endpoint:        strings.TrimPrefix(server.URL, "https://"),
+		client:          server.Client(),
+	}
+	if !op.Available() {
+		t.Fatalf("oidc provider should be available")
+	}
+	cred, exp, err := op.Retrieve()
+	if err != nil || cred.AccessKeyId != "STS.oidc" || exp.Year() != 2099 {
+		t.Fatalf("unexpected credentials: %v, %v, %v", cred, exp, err)
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const ingressResyncPeriod = 10 * time.Minute
+
+var IngressResource = schema.GroupVersionResource{
+	Group:    "networking.k8s.io",
+	Version:  "v1",
+	Resource: "ingresses",
+}
+
+var (
+	ingressLister     *IngressLister
+	ingressListerOnce sync.Once
+)
+
+// IngressLister caches the Ingresses of cluster by informer, it's used to resolve the load balancers of Ingresses.
+type IngressLister struct {
+	resourceLister
+}
+
+func GetIngressLister() *IngressLister {
+	ingressListerOnce.Do(func() {
+		ingressLister = &IngressLister{resourceLister{gvr: IngressResource, resyncPeriod: ingressResyncPeriod}}
+	})
+	return ingressLister
+}
+
+// Get returns the Ingress from cache.
+func (il *IngressLister) Get(namespace, name string) (*unstructured.Unstructured, error) {
+	obj, synced, err := il.get(namespace, name)
+	if !synced {
+		return nil, errors.New("ingresses are not synced to resolve load balancer")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingress %s/%s,because of %v", namespace, name, err)
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object of ingress %s/%s", namespace, name)
+	}
+	return u, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	log "k8s.io/klog/v2"
+)
+
+// resourceLister caches the objects of a resource by informer, the informer is started once by Run
+// and the lister may be read concurrently by the metric sources started later.
+type resourceLister struct {
+	gvr          schema.GroupVersionResource
+	resyncPeriod time.Duration
+
+	once   sync.Once
+	lock   sync.RWMutex
+	lister cache.GenericLister
+	synced cache.InformerSynced
+}
+
+// Run starts the informer once, it's safe to be called by multiple metric sources.
+func (rl *resourceLister) Run(kubeClient dynamic.Interface, stopCh <-chan struct{}) {
+	rl.once.Do(func() {
+		factory := dynamicinformer.NewDynamicSharedInformerFactory(kubeClient, rl.resyncPeriod)
+		informer := factory.ForResource(rl.gvr)
+		rl.lock.Lock()
+		rl.lister = informer.Lister()
+		rl.synced = informer.Informer().HasSynced
+		rl.lock.Unlock()
+		factory.Start(stopCh)
+		log.Infof("Start watching %s", rl.gvr.Resource)
+	})
+}
+
+// get returns the object from cache, synced is false if the informer is not started or synced yet.
+func (rl *resourceLister) get(namespace, name string) (obj runtime.Object, synced bool, err error) {
+	rl.lock.RLock()
+	lister, hasSynced := rl.lister, rl.synced
+	rl.lock.RUnlock()
+	if lister == nil || !hasSynced() {
+		return nil, false, nil
+	}
+	obj, err = lister.ByNamespace(namespace).Get(name)
+	return obj, true, err
+}
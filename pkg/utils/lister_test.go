@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedyn "k8s.io/client-go/dynamic/fake"
+)
+
+func TestServiceLister(t *testing.T) {
+	svc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+		"spec":       map[string]interface{}{"type": "LoadBalancer"},
+	}}
+	kubeClient := fakedyn.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{ServiceResource: "ServiceList"}, svc)
+	sl := &ServiceLister{resourceLister{gvr: ServiceResource, resyncPeriod: serviceResyncPeriod}}
+	if _, err := sl.Get("default", "web"); err == nil {
+		t.Fatalf("expected error before the informer is started")
+	}
+
+	// the sources started later read the lister while it's being started
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			sl.Run(kubeClient, stopCh)
+		}()
+		go func() {
+			defer wg.Done()
+			sl.Get("default", "web")
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s, err := sl.Get("default", "web")
+		if err == nil {
+			if s.Name != "web" {
+				t.Fatalf("unexpected service %v", s)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to get service: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := sl.Get("default", "unknown"); err == nil {
+		t.Fatalf("expected error for unknown service")
+	}
+}
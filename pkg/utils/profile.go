@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// labels of metric selector to access the resources of other regions or accounts
+	ALIBABACLOUD_REGION  = "alibabacloud.region"
+	ALIBABACLOUD_PROFILE = "alibabacloud.profile"
+
+	// the credential profiles are mounted from a Secret
+	DEFAULT_PROFILES_PATH = "/etc/alibaba-cloud-metrics-adapter/profiles/profiles.json"
+
+	// modes of credential profile
+	PROFILE_MODE_AK           = "AK"
+	PROFILE_MODE_RAM_ROLE_ARN = "RamRoleArn"
+)
+
+var (
+	profiles = newProfileStore(DEFAULT_PROFILES_PATH)
+
+	regionPattern = regexp.MustCompile(`^[a-z]+(-[a-z0-9]+)+$`)
+)
+
+// AccessOptions selects the region and the credential profile of a request,
+// the region of adapter and the default credentials are used if they are empty.
+// Namespace is the namespace of request, which is checked against the allowed namespaces of profile.
+type AccessOptions struct {
+	Region    string
+	Profile   string
+	Namespace string
+}
+
+// GetAccessOptions returns the access options from the labels of metric selector in the namespace.
+func GetAccessOptions(namespace string, requirements labels.Requirements) AccessOptions {
+	options := AccessOptions{Namespace: namespace}
+	for _, r := range requirements {
+		if len(r.Values().List()) <= 0 {
+			continue
+		}
+		switch r.Key() {
+		case ALIBABACLOUD_REGION:
+			options.Region = r.Values().List()[0]
+		case ALIBABACLOUD_PROFILE:
+			options.Profile = r.Values().List()[0]
+		}
+	}
+	return options
+}
+
+// SetProfilesPath replaces the file of credential profiles, the profiles are reloaded once the file changes.
+func SetProfilesPath(path string) {
+	profiles = newProfileStore(path)
+}
+
+// GetAccessUserInfoWithOptions returns the credentials of the profile with the region of options.
+// The region falls back to the region of profile and then the region of adapter.
+func GetAccessUserInfoWithOptions(options AccessOptions) (accessUserInfo *AccessUserInfo, err error) {
+	if options.Region != "" && !regionPattern.MatchString(options.Region) {
+		return nil, fmt.Errorf("invalid region %s", options.Region)
+	}
+
+	region := options.Region
+	var cred *AccessUserInfo
+	if options.Profile == "" {
+		cred, err = DefaultCredentialProvider().Get()
+	} else {
+		var entry *profileEntry
+		entry, err = profiles.get(options.Profile)
+		if err != nil {
+			return nil, err
+		}
+		if !entry.profile.allows(options.Namespace) {
+			return nil, fmt.Errorf("profile %s is not allowed in namespace %s", options.Profile, options.Namespace)
+		}
+		if region == "" {
+			region = entry.profile.Region
+		}
+		cred, err = entry.credentials.Get()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if region == "" {
+		if region, err = GetRegion(); err != nil {
+			klog.Errorf("failed to get Region,because of %s", err.Error())
+			return nil, err
+		}
+	}
+	cred.Region = region
+	cred.Profile = options.Profile
+	return cred, nil
+}
+
+// Profile is the named credentials to access the resources of other accounts, such as
+//
+//	{"profiles": [{"name": "prod", "mode": "RamRoleArn", "roleArn": "acs:ram::123456:role/metrics", "region": "cn-shanghai"}]}
+//
+// The role is assumed with the access key of profile or the default credentials of adapter.
+// The profile is used by the requests of all namespaces unless allowedNamespaces is provided.
+type Profile struct {
+	Name            string `json:"name"`
+	Mode            string `json:"mode"`
+	Region          string `json:"region,omitempty"`
+	AccessKeyId     string `json:"accessKeyId,omitempty"`
+	AccessKeySecret string `json:"accessKeySecret,omitempty"`
+	RoleArn         string `json:"roleArn,omitempty"`
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// the namespaces whose metric requests are allowed to use the profile
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+type profilesConfig struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+func (p *Profile) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name of profile must be provided")
+	}
+	if p.Region != "" && !regionPattern.MatchString(p.Region) {
+		return fmt.Errorf("invalid region %s of profile %s", p.Region, p.Name)
+	}
+	switch p.Mode {
+	case PROFILE_MODE_AK:
+		if p.AccessKeyId == "" || p.AccessKeySecret == "" {
+			return fmt.Errorf("accessKeyId and accessKeySecret of profile %s must be provided", p.Name)
+		}
+	case PROFILE_MODE_RAM_ROLE_ARN:
+		if p.RoleArn == "" {
+			return fmt.Errorf("roleArn of profile %s must be provided", p.Name)
+		}
+		if (p.AccessKeyId == "") != (p.AccessKeySecret == "") {
+			return fmt.Errorf("both accessKeyId and accessKeySecret of profile %s must be provided", p.Name)
+		}
+	default:
+		return fmt.Errorf("mode %s of profile %s is not supported, valid modes are %s and %s", p.Mode, p.Name, PROFILE_MODE_AK, PROFILE_MODE_RAM_ROLE_ARN)
+	}
+	return nil
+}
+
+func (p *Profile) allows(namespace string) bool {
+	if len(p.AllowedNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range p.AllowedNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+type profileEntry struct {
+	profile     Profile
+	credentials *CachedCredentialProvider
+}
+
+// profileStore loads the profiles lazily and reloads them once the modification time of file changes,
+// the Secret mounted as volume is replaced by symlink when it's updated.
+type profileStore struct {
+	lock     sync.Mutex
+	path     string
+	modTime  time.Time
+	profiles map[string]*profileEntry
+}
+
+func newProfileStore(path string) *profileStore {
+	return &profileStore{
+		path:     path,
+		profiles: make(map[string]*profileEntry),
+	}
+}
+
+func (ps *profileStore) get(name string) (*profileEntry, error) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	info, err := os.Stat(ps.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find profile %s,because of %v", name, err)
+	}
+	if !info.ModTime().Equal(ps.modTime) {
+		// the old profiles are kept if the new ones are invalid
+		ps.modTime = info.ModTime()
+		if err := ps.load(); err != nil {
+			klog.Errorf("Failed to load credential profiles from %s,because of %v", ps.path, err)
+		}
+	}
+
+	entry, ok := ps.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %s is not found in %s", name, ps.path)
+	}
+	return entry, nil
+}
+
+func (ps *profileStore) load() error {
+	content, err := ioutil.ReadFile(ps.path)
+	if err != nil {
+		return err
+	}
+	config := &profilesConfig{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return err
+	}
+
+	entries := make(map[string]*profileEntry)
+	for _, profile := range config.Profiles {
+		if err := profile.validate(); err != nil {
+			return err
+		}
+		if _, ok := entries[profile.Name]; ok {
+			return fmt.Errorf("profile %s is duplicated", profile.Name)
+		}
+		var provider CredentialProvider = &akProfileProvider{profile: profile}
+		if profile.Mode == PROFILE_MODE_RAM_ROLE_ARN {
+			provider = &ramRoleArnProvider{profile: profile, assumeRole: stsAssumeRole}
+		}
+		entries[profile.Name] = &profileEntry{
+			profile:     profile,
+			credentials: NewCachedCredentialProvider(provider),
+		}
+	}
+	ps.profiles = entries
+	klog.Infof("Loaded %d credential profiles from %s", len(entries), ps.path)
+	return nil
+}
+
+// the long-lived access key of profile
+type akProfileProvider struct {
+	profile Profile
+}
+
+func (ap *akProfileProvider) Retrieve() (*AccessUserInfo, time.Time, error) {
+	return &AccessUserInfo{
+		AccessKeyId:     ap.profile.AccessKeyId,
+		AccessKeySecret: ap.profile.AccessKeySecret,
+	}, time.Time{}, nil
+}
+
+// assume the role of profile, which is usually owned by another account
+type ramRoleArnProvider struct {
+	profile    Profile
+	assumeRole func(source *AccessUserInfo, profile Profile) (*AccessUserInfo, time.Time, error)
+}
+
+func (rp *ramRoleArnProvider) Retrieve() (*AccessUserInfo, time.Time, error) {
+	source := &AccessUserInfo{
+		AccessKeyId:     rp.profile.AccessKeyId,
+		AccessKeySecret: rp.profile.AccessKeySecret,
+	}
+	if source.AccessKeyId == "" {
+		cred, err := DefaultCredentialProvider().Get()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		source = cred
+	}
+	return rp.assumeRole(source, rp.profile)
+}
+
+func stsAssumeRole(source *AccessUserInfo, profile Profile) (*AccessUserInfo, time.Time, error) {
+	region := profile.Region
+	if region == "" {
+		r, err := GetRegion()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		region = r
+	}
+
+	var client *sts.Client
+	var err error
+	if source.Token != "" || strings.HasPrefix(source.AccessKeyId, "STS.") {
+		client, err = sts.NewClientWithStsToken(region, source.AccessKeyId, source.AccessKeySecret, source.Token)
+	} else {
+		client, err = sts.NewClientWithAccessKey(region, source.AccessKeyId, source.AccessKeySecret)
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	request := sts.CreateAssumeRoleRequest()
+	request.Scheme = "https"
+	request.RoleArn = profile.RoleArn
+	request.RoleSessionName = profile.RoleSessionName
+	if request.RoleSessionName == "" {
+		request.RoleSessionName = DefaultRoleSessionName
+	}
+	request.DurationSeconds = requests.NewInteger(int(stsTokenDuration.Seconds()))
+	response, err := client.AssumeRole(request)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to assume role %s,because of %v", profile.RoleArn, err)
+	}
+
+	expiration, err := time.Parse(expirationLayout, response.Credentials.Expiration)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse expiration %s, err: %v", response.Credentials.Expiration, err)
+	}
+	return &AccessUserInfo{
+		AccessKeyId:     response.Credentials.AccessKeyId,
+		AccessKeySecret: response.Credentials.AccessKeySecret,
+		Token:           response.Credentials.SecurityToken,
+		Expiration:      response.Credentials.Expiration,
+	}, expiration, nil
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func writeProfiles(t *testing.T, path, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write profiles: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to change modification time of profiles: %v", err)
+	}
+}
+
+func TestGetAccessOptions(t *testing.T) {
+	selector, err := labels.Parse("alibabacloud.region=cn-shanghai,alibabacloud.profile=prod,slb.instance.id=lb-1")
+	if err != nil {
+		t.Fatalf("failed to parse selector: %v", err)
+	}
+	requirements, _ := selector.Requirements()
+	options := GetAccessOptions("default", requirements)
+	if options.Region != "cn-shanghai" || options.Profile != "prod" || options.Namespace != "default" {
+		t.Fatalf("unexpected access options: %+v", options)
+	}
+}
+
+func TestGetAccessUserInfoWithProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.json")
+	now := time.Now()
+	writeProfiles(t, path, `{"profiles": [
+		{"name": "shanghai", "mode": "AK", "region": "cn-shanghai", "accessKeyId": "ak-1", "accessKeySecret": "sk-1"}
+	]}`, now)
+
+	SetProfilesPath(path)
+	defer SetProfilesPath(DEFAULT_PROFILES_PATH)
+
+	cred, err := GetAccessUserInfoWithOptions(AccessOptions{Profile: "shanghai"})
+	if err != nil {
+		t.Fatalf("failed to get credentials of profile: %v", err)
+	}
+	if cred.AccessKeyId != "ak-1" || cred.Region != "cn-shanghai" || cred.Profile != "shanghai" {
+		t.Fatalf("unexpected credentials of profile: %+v", cred)
+	}
+
+	// the region of label overrides the one of profile
+	cred, err = GetAccessUserInfoWithOptions(AccessOptions{Profile: "shanghai", Region: "cn-beijing"})
+	if err != nil || cred.Region != "cn-beijing" {
+		t.Fatalf("unexpected credentials with region: %+v, %v", cred, err)
+	}
+	if _, err := GetAccessUserInfoWithOptions(AccessOptions{Profile: "shanghai", Region: "evil.com/"}); err == nil {
+		t.Fatalf("expected error for invalid region")
+	}
+	if _, err := GetAccessUserInfoWithOptions(AccessOptions{Profile: "unknown"}); err == nil {
+		t.Fatalf("expected error for unknown profile")
+	}
+
+	// the profiles are reloaded once the file changes, and the invalid ones are ignored
+	writeProfiles(t, path, `{"profiles": [{"name": "shanghai", "mode": "AK", "accessKeyId": "ak-2", "accessKeySecret": "sk-2"}]}`, now.Add(time.Second))
+	cred, err = GetAccessUserInfoWithOptions(AccessOptions{Profile: "shanghai", Region: "cn-shanghai"})
+	if err != nil || cred.AccessKeyId != "ak-2" {
+		t.Fatalf("expected profiles to be reloaded: %+v, %v", cred, err)
+	}
+	writeProfiles(t, path, `{"profiles": [{"name": "shanghai", "mode": "Unknown"}]}`, now.Add(2*time.Second))
+	cred, err = GetAccessUserInfoWithOptions(AccessOptions{Profile: "shanghai", Region: "cn-shanghai"})
+	if err != nil || cred.AccessKeyId != "ak-2" {
+		t.Fatalf("expected invalid profiles to be ignored: %+v, %v", cred, err)
+	}
+}
+
+func TestRamRoleArnProvider(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	var source *AccessUserInfo
+	provider := &ramRoleArnProvider{
+		profile: Profile{Name: "other", Mode: PROFILE_MODE_RAM_ROLE_ARN, RoleArn: "acs:ram::123:role/metrics", AccessKeyId: "ak-1", AccessKeySecret: "sk-1"},
+		assumeRole: func(s *AccessUserInfo, profile Profile) (*AccessUserInfo, time.Time, error) {
+			source = s
+			return &AccessUserInfo{AccessKeyId: "STS.1", AccessKeySecret: "sts-sk", Token: "token"}, expiration, nil
+		},
+	}
+
+	cred, e, err := provider.Retrieve()
+	if err != nil {
+		t.Fatalf("failed to assume role: %v", err)
+	}
+	if source.AccessKeyId != "ak-1" || cred.AccessKeyId != "STS.1" || !e.Equal(expiration) {
+		t.Fatalf("unexpected credentials of role: %+v from %+v", cred, source)
+	}
+
+	if err := (&Profile{Name: "other", Mode: PROFILE_MODE_RAM_ROLE_ARN, AccessKeyId: "ak-1"}).validate(); err == nil {
+		t.Fatalf("expected error for missing role arn")
+	}
+}
+
+func TestClientCacheWithProfiles(t *testing.T) {
+	cc := &ClientCache{}
+	builds := 0
+	build := func() (interface{}, error) {
+		builds++
+		return builds, nil
+	}
+	for i := 0; i < 2; i++ {
+		cc.Get("cn-hangzhou", &AccessUserInfo{AccessKeyId: "ak-1"}, build)
+		cc.Get("cn-hangzhou", &AccessUserInfo{AccessKeyId: "ak-2", Profile: "other"}, build)
+	}
+	if builds != 2 {
+		t.Fatalf("expected the clients of profiles to be cached separately, built %d times", builds)
+	}
+}
+
+func TestGetAccessUserInfoWithAllowedNamespaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.json")
+	writeProfiles(t, path, `{"profiles": [
+		{"name": "prod", "mode": "AK", "region": "cn-shanghai", "accessKeyId": "ak-1", "accessKeySecret": "sk-1", "allowedNamespaces": ["prod", "monitoring"]},
+		{"name": "shared", "mode": "AK", "region": "cn-shanghai", "accessKeyId": "ak-2", "accessKeySecret": "sk-2"}
+	]}`, time.Now())
+
+	SetProfilesPath(path)
+	defer SetProfilesPath(DEFAULT_PROFILES_PATH)
+
+	cred, err := GetAccessUserInfoWithOptions(AccessOptions{Profile: "prod", Namespace: "monitoring"})
+	if err != nil || cred.AccessKeyId != "ak-1" {
+		t.Fatalf("expected profile to be allowed in namespace monitoring: %+v, %v", cred, err)
+	}
+	if _, err := GetAccessUserInfoWithOptions(AccessOptions{Profile: "prod", Namespace: "default"}); err == nil {
+		t.Fatalf("expected profile to be rejected in namespace default")
+	}
+	if _, err := GetAccessUserInfoWithOptions(AccessOptions{Profile: "prod"}); err == nil {
+		t.Fatalf("expected profile to be rejected without namespace")
+	}
+	if _, err := GetAccessUserInfoWithOptions(AccessOptions{Profile: "shared", Namespace: "default"}); err != nil {
+		t.Fatalf("expected profile without allowed namespaces to be used by all namespaces: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// units of the raw values returned by cloud products
+const (
+	UNIT_COUNT            = "count"
+	UNIT_CORES            = "cores"
+	UNIT_BYTES            = "bytes"
+	UNIT_COUNT_PER_SECOND = "count/s"
+	UNIT_BYTES_PER_SECOND = "bytes/s"
+	UNIT_BITS_PER_SECOND  = "bits/s"
+	UNIT_MILLISECONDS     = "ms"
+	UNIT_SECONDS          = "s"
+	UNIT_PERCENT          = "%"
+)
+
+// Unit describes the value of a metric, it's served in the status of metric sources.
+type Unit struct {
+	// Name is the unit of the served value
+	Name string
+	// Scale converts the raw value to Name, e.g. 1000 for the latency in seconds served in ms.
+	// The raw value is served as is if it's not set.
+	Scale float64
+}
+
+// MILLISECONDS_PER_SECOND is the Scale of the raw value in seconds which is served in ms
+const MILLISECONDS_PER_SECOND = 1000
+
+// the largest value which can be represented in milli precision
+const maxMilliValue = float64(math.MaxInt64 / 1000)
+
+// ConvertValue converts the raw value of metric to quantity in milli precision,
+// so the fractional values such as 0.5 QPS are not truncated. NaN and Inf are rejected
+// because they can't be compared by HPA.
+func ConvertValue(value float64, unit Unit) (*resource.Quantity, error) {
+	if unit.Scale != 0 {
+		value *= unit.Scale
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("invalid metric value %v", value)
+	}
+	if math.Abs(value) < maxMilliValue {
+		return resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI), nil
+	}
+	if math.Abs(value) < float64(math.MaxInt64) {
+		return resource.NewQuantity(int64(math.Round(value)), resource.DecimalSI), nil
+	}
+	return nil, fmt.Errorf("metric value %v is out of range", value)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertValue(t *testing.T) {
+	cases := []struct {
+		value    float64
+		unit     Unit
+		expected string
+	}{
+		{value: 0.8, expected: "800m"},
+		{value: 0.5, expected: "500m"},
+		{value: 12, expected: "12"},
+		{value: -1.5, expected: "-1500m"},
+		{value: 0.0004, expected: "0"},
+		{value: 50, unit: Unit{Name: UNIT_PERCENT, Scale: 0.01}, expected: "500m"},
+		{value: 1e17, expected: "100P"},
+	}
+	for _, c := range cases {
+		q, err := ConvertValue(c.value, c.unit)
+		if err != nil {
+			t.Fatalf("failed to convert %v: %v", c.value, err)
+		}
+		if q.String() != c.expected {
+			t.Fatalf("expect %v converted to %s, got %s", c.value, c.expected, q.String())
+		}
+	}
+
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e300} {
+		if _, err := ConvertValue(v, Unit{}); err == nil {
+			t.Fatalf("expect error when converting %v", v)
+		}
+	}
+}
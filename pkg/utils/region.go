@@ -12,3 +12,11 @@ func GetRegionFromEnv() (region string, err error) {
 	}
 	return region, nil
 }
+
+func GetClusterIdFromEnv() (clusterId string, err error) {
+	clusterId = os.Getenv("ClusterId")
+	if clusterId == "" {
+		return "", errors.New("not found cluster id in env")
+	}
+	return clusterId, nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	log "k8s.io/klog/v2"
+)
+
+// the backoff to probe the resources which are not available yet, e.g. the CRDs applied after the adapter starts
+var (
+	resourceRetryInitial = 5 * time.Second
+	resourceRetryMax     = 5 * time.Minute
+)
+
+// RunWhenResourceAvailable calls run once the resource can be listed, it returns immediately and
+// probes the resource in background with backoff until it's available or stopCh is closed.
+func RunWhenResourceAvailable(kubeClient dynamic.Interface, gvr schema.GroupVersionResource, stopCh <-chan struct{}, run func()) {
+	go func() {
+		if waitForResource(kubeClient, gvr, stopCh) {
+			run()
+		}
+	}()
+}
+
+// waitForResource returns false if stopCh is closed before the resource is available.
+func waitForResource(kubeClient dynamic.Interface, gvr schema.GroupVersionResource, stopCh <-chan struct{}) bool {
+	delay := resourceRetryInitial
+	for {
+		_, err := kubeClient.Resource(gvr).List(context.Background(), metav1.ListOptions{Limit: 1})
+		if err == nil {
+			return true
+		}
+		if apierrors.IsNotFound(err) {
+			log.Infof("%s is not installed yet and retry in %v", gvr.Resource, delay)
+		} else {
+			log.Errorf("Failed to list %s and retry in %v,because of %v", gvr.Resource, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-stopCh:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		if delay *= 2; delay > resourceRetryMax {
+			delay = resourceRetryMax
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedyn "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRunWhenResourceAvailable(t *testing.T) {
+	resourceRetryInitial, resourceRetryMax = time.Millisecond, 2*time.Millisecond
+	defer func() {
+		resourceRetryInitial, resourceRetryMax = 5*time.Second, 5*time.Minute
+	}()
+
+	gvr := schema.GroupVersionResource{Group: "metrics.alibabacloud.com", Version: "v1alpha1", Resource: "tests"}
+	kubeClient := fakedyn.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "TestList"})
+	// the CRD is applied after the third probe
+	var probes int32
+	kubeClient.PrependReactor("list", "tests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if atomic.AddInt32(&probes, 1) <= 3 {
+			return true, nil, apierrors.NewNotFound(gvr.GroupResource(), "")
+		}
+		return false, nil, nil
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ran := make(chan struct{})
+	RunWhenResourceAvailable(kubeClient, gvr, stopCh, func() { close(ran) })
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("run is not called after the resource is available")
+	}
+	if n := atomic.LoadInt32(&probes); n != 4 {
+		t.Fatalf("unexpected probes %d", n)
+	}
+
+	// never run once stopped
+	stopped := make(chan struct{})
+	close(stopped)
+	atomic.StoreInt32(&probes, 0)
+	if waitForResource(kubeClient, gvr, stopped) {
+		t.Fatalf("expected false once stopped")
+	}
+}
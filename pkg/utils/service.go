@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// labels of metric selector to reference a LoadBalancer Service
+	K8S_SERVICE_NAME      = "k8s.service.name"
+	K8S_SERVICE_NAMESPACE = "k8s.service.namespace"
+	K8S_SERVICE_PORT      = "k8s.service.port"
+
+	// the annotation of CCM to reuse an existing load balancer
+	LOADBALANCER_ID_ANNOTATION = "service.beta.kubernetes.io/alibaba-cloud-loadbalancer-id"
+	// the label added by CCM to the load balancer created for the Service
+	LOADBALANCER_ID_LABEL = "service.k8s.alibaba/loadbalancer-id"
+
+	serviceResyncPeriod = 10 * time.Minute
+)
+
+var ServiceResource = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "services",
+}
+
+var (
+	serviceLister     *ServiceLister
+	serviceListerOnce sync.Once
+)
+
+// ServiceLister caches the Services of cluster by informer, it's shared by the load balancer metric sources.
+type ServiceLister struct {
+	resourceLister
+}
+
+func GetServiceLister() *ServiceLister {
+	serviceListerOnce.Do(func() {
+		serviceLister = &ServiceLister{resourceLister{gvr: ServiceResource, resyncPeriod: serviceResyncPeriod}}
+	})
+	return serviceLister
+}
+
+// Get returns the Service from cache.
+func (sl *ServiceLister) Get(namespace, name string) (*v1.Service, error) {
+	obj, synced, err := sl.get(namespace, name)
+	if !synced {
+		return nil, errors.New("services are not synced to resolve load balancer")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service %s/%s,because of %v", namespace, name, err)
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object of service %s/%s", namespace, name)
+	}
+	return ConvertService(u)
+}
+
+func ConvertService(u *unstructured.Unstructured) (*v1.Service, error) {
+	svc := &v1.Service{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, svc); err != nil {
+		return nil, fmt.Errorf("failed to convert service %s,because of %v", u.GetName(), err)
+	}
+	if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return nil, fmt.Errorf("service %s/%s is not type of LoadBalancer", svc.Namespace, svc.Name)
+	}
+	return svc, nil
+}
+
+// GetLoadBalancerId returns the ID of load balancer from annotation or label of CCM.
+func GetLoadBalancerId(svc *v1.Service) string {
+	if id := svc.Annotations[LOADBALANCER_ID_ANNOTATION]; id != "" {
+		return id
+	}
+	return svc.Labels[LOADBALANCER_ID_LABEL]
+}
+
+// GetServicePort matches the port by name or number, it can be omitted if the service has only one port.
+func GetServicePort(svc *v1.Service, port string) (*v1.ServicePort, error) {
+	if port == "" {
+		if len(svc.Spec.Ports) == 1 {
+			return &svc.Spec.Ports[0], nil
+		}
+		return nil, fmt.Errorf("service %s/%s has multiple ports and %s must be provided", svc.Namespace, svc.Name, K8S_SERVICE_PORT)
+	}
+	for i, p := range svc.Spec.Ports {
+		if p.Name == port || strconv.Itoa(int(p.Port)) == port {
+			return &svc.Spec.Ports[i], nil
+		}
+	}
+	return nil, fmt.Errorf("port %s of service %s/%s is not found", port, svc.Namespace, svc.Name)
+}
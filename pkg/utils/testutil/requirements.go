@@ -0,0 +1,18 @@
+// Package testutil provides the helpers shared by the tests of metric sources.
+package testutil
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ParseRequirements parses the label selector of metric, e.g. "slb.instance.id=lb-1,slb.port=80".
+func ParseRequirements(t *testing.T, selector string) labels.Requirements {
+	s, err := labels.Parse(selector)
+	if err != nil {
+		t.Fatalf("failed to parse selector %s: %v", selector, err)
+	}
+	r, _ := s.Requirements()
+	return r
+}